@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/repositories"
+)
+
+// UriActionBatch uri action of the todos applying several operations at once
+const UriActionBatch = ":batch"
+
+// TodosBatch Handler for the todos batch action
+// POST /todos:batch
+func TodosBatch(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if request.Body == nil {
+		handleError(writer, request, fmt.Errorf("%w: invalid body", errInvalidRequest))
+		return
+	}
+	var batchRequest models.BatchRequest
+	err := models.DecodeBody(request.Body, &batchRequest)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+	if batchRequest.Mode == "" {
+		batchRequest.Mode = models.BatchModeAtomic
+	}
+	if batchRequest.Mode != models.BatchModeAtomic && batchRequest.Mode != models.BatchModeBestEffort {
+		handleError(writer, request,
+			fmt.Errorf("%w: mode must be %q or %q", errInvalidRequest, models.BatchModeAtomic, models.BatchModeBestEffort))
+		return
+	}
+
+	results, err := models.ApplyBatch(request.Context(), batchRequest.Operations, batchRequest.Mode == models.BatchModeAtomic)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	meta := models.BatchMeta{Mode: batchRequest.Mode}
+	operationResults := make([]models.BatchOperationResult, 0, len(results))
+	for index, result := range results {
+		operationResult := batchOperationResult(index, batchRequest.Operations[index].Op, result, request.URL.RequestURI())
+		if operationResult.Error != nil {
+			meta.Failed++
+		} else {
+			meta.Succeeded++
+		}
+		operationResults = append(operationResults, operationResult)
+	}
+
+	statusCode := http.StatusOK
+	if meta.Failed > 0 {
+		statusCode = http.StatusMultiStatus
+	}
+	writer.WriteHeader(statusCode)
+	response := models.JsonExtendedResponse{Meta: meta, Data: operationResults}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// batchOperationResult returns the result of a batch operation with the status and problem it would have as single request
+func batchOperationResult(index int, op string, result repositories.BatchResult, instance string) models.BatchOperationResult {
+	operationResult := models.BatchOperationResult{Index: index, Op: op}
+	if result.Err == nil {
+		operationResult.Status = http.StatusOK
+		if op == models.BatchOperationCreate {
+			operationResult.Status = http.StatusCreated
+		}
+		todoResult := result.Todo
+		operationResult.Todo = &todoResult
+		return operationResult
+	}
+
+	problem := problemOf(result.Err, instance)
+	operationResult.Status = problem.Status
+	operationResult.Error = &problem
+	return operationResult
+}
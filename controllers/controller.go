@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
+	"strconv"
 	"todo-rest-backend/models"
 	"todo-rest-backend/models/configuration"
+	"todo-rest-backend/models/delivery"
+	"todo-rest-backend/models/events"
 	"todo-rest-backend/models/repositories/factory"
 	"todo-rest-backend/models/todo"
 )
@@ -26,6 +31,43 @@ const UriRessourceTodos = "/todos"
 // UriRessourceTodosPathParameterName uri ressource todos path parameter name
 const UriRessourceTodosPathParameterName = "{id}"
 
+// UriRessourceTrash uri sub ressource of todos for the trash
+const UriRessourceTrash = "/trash"
+
+// UriActionRestore uri action restoring a todo from the trash
+const UriActionRestore = "/restore"
+
+// UriRessourceChildren uri sub ressource of a todo for its subtasks
+const UriRessourceChildren = "/children"
+
+// QueryParameterCompleteChildren query parameter of the todo put and patch actions, terminating all subtasks
+// as well when the todo is terminated
+const QueryParameterCompleteChildren = "completeChildren"
+
+// UriRessourceBlockers uri sub ressource of a todo for the todos blocking it
+const UriRessourceBlockers = "/blockers"
+
+// UriRessourceOccurrences uri sub ressource of a recurring todo previewing its next occurrences
+const UriRessourceOccurrences = "/occurrences"
+
+// UriRessourceHistory uri sub ressource of a todo for its stored changes
+const UriRessourceHistory = "/history"
+
+// QueryParameterCount query parameter of the occurrences preview, the number of occurrences to return
+const QueryParameterCount = "count"
+
+// UriRessourceTags uri ressource tags
+const UriRessourceTags = "/tags"
+
+// UriRessourceTagsPathParameterName uri ressource tags path parameter name
+const UriRessourceTagsPathParameterName = "{tag}"
+
+// UriActionRename uri action renaming a tag
+const UriActionRename = "/rename"
+
+// UriActionMerge uri action merging tags
+const UriActionMerge = "/merge"
+
 // GeneralErrorMessage general error message
 const GeneralErrorMessage = "an error has occurred"
 
@@ -43,35 +85,192 @@ func Run() error {
 	if err != nil {
 		return err
 	}
+	listRepositoryInstance, err := factory.GetListRepositoryInstance()
+	if err != nil {
+		return err
+	}
+	err = models.SetListRepository(listRepositoryInstance)
+	if err != nil {
+		return err
+	}
+	err = models.InitializeLists()
+	if err != nil {
+		return err
+	}
+
+	webhookRepositoryInstance, err := factory.GetWebhookRepositoryInstance()
+	if err != nil {
+		return err
+	}
+	err = models.SetWebhookRepository(webhookRepositoryInstance)
+	if err != nil {
+		return err
+	}
+	err = models.InitializeWebhooks()
+	if err != nil {
+		return err
+	}
+
+	revisionRepositoryInstance, err := factory.GetRevisionRepositoryInstance()
+	if err != nil {
+		return err
+	}
+	err = models.SetRevisionRepository(revisionRepositoryInstance)
+	if err != nil {
+		return err
+	}
+	err = models.InitializeRevisions()
+	if err != nil {
+		return err
+	}
+
+	trashEnabled, err := configuration.GetTrashMode()
+	if err != nil {
+		return err
+	}
+	trashRetention, err := configuration.GetTrashRetention()
+	if err != nil {
+		return err
+	}
+	err = models.SetTrashMode(trashEnabled, trashRetention)
+	if err != nil {
+		return err
+	}
+	if trashEnabled {
+		models.StartTrashPurge(nil)
+	}
+
+	eventBufferSize, err := configuration.GetEventBufferSize(events.BufferSizeDefault)
+	if err != nil {
+		return err
+	}
+	err = models.SetEventBufferSize(eventBufferSize)
+	if err != nil {
+		return err
+	}
+
+	webhookMaxAttempts, err := configuration.GetWebhookMaxAttempts(delivery.MaxAttemptsDefault)
+	if err != nil {
+		return err
+	}
+	webhookBackoff, err := configuration.GetWebhookBackoff(delivery.BackoffDefault)
+	if err != nil {
+		return err
+	}
+	webhookTimeout, err := configuration.GetWebhookTimeout(delivery.TimeoutDefault)
+	if err != nil {
+		return err
+	}
+	err = models.SetWebhookDelivery(webhookMaxAttempts, webhookBackoff, webhookTimeout)
+	if err != nil {
+		return err
+	}
+	models.StartWebhookDelivery(nil)
+
+	validationRules, err := readValidationRules()
+	if err != nil {
+		return err
+	}
+	err = models.SetValidationRules(validationRules)
+	if err != nil {
+		return err
+	}
 
 	backendHostUrl, err := configuration.GetBackendHostUrl()
 	if err != nil {
 		return err
 	}
 
+	fmt.Println("Backend running at:", backendHostUrl)
+	err = http.ListenAndServe(backendHostUrl, NewRouter())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NewRouter returns the router of the api, the models have to be set up before it serves requests
+func NewRouter() *mux.Router {
 	// StrictSlash == true: if the route path is "/path/", then a redirect to the path "/path" is done.
 	router := mux.NewRouter().StrictSlash(true)
 
 	api := router.PathPrefix(path.Join(UriBasePath, UriVersion)).Subrouter()
+	api.Use(withOrigin)
 	api.HandleFunc("", Index).Methods("GET")
 	api.HandleFunc(UriRessourceTodos, TodosGet).Methods("GET")
+	api.HandleFunc(UriRessourceTodos+UriActionBatch, TodosBatch).Methods("POST")
+	// registered before the todo id routes, so that "trash" and "events" are not taken as id
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTrash), TrashGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceEvents), TodosEvents).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriActionRestore), TodoRestore).Methods("POST")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceChildren), TodoChildrenGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceBlockers), TodoBlockersGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceOccurrences), TodoOccurrencesGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceHistory), TodoHistoryGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceRevisions), TodoRevisionsGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceRevisions, UriRessourceRevisionsPathParameterName), TodoRevisionGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName, UriRessourceRevisions, UriRessourceRevisionsPathParameterName, UriActionRestore), TodoRevisionRestore).Methods("POST")
 	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName), TodoGetById).Methods("GET")
 	api.HandleFunc(UriRessourceTodos, TodoPost).Methods("POST")
 	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName), TodoPut).Methods("PUT")
+	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName), TodoPatch).Methods("PATCH")
 	api.HandleFunc(path.Join(UriRessourceTodos, UriRessourceTodosPathParameterName), TodoDelete).Methods("DELETE")
+	api.HandleFunc(UriRessourceLists, ListsGet).Methods("GET")
+	api.HandleFunc(UriRessourceLists, ListPost).Methods("POST")
+	api.HandleFunc(path.Join(UriRessourceLists, UriRessourceListsPathParameterName), ListGetById).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceLists, UriRessourceListsPathParameterName), ListPut).Methods("PUT")
+	api.HandleFunc(path.Join(UriRessourceLists, UriRessourceListsPathParameterName), ListDelete).Methods("DELETE")
+	api.HandleFunc(path.Join(UriRessourceLists, UriRessourceListsPathParameterName, UriRessourceTodos), ListTodosGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceLists, UriRessourceListsPathParameterName, UriRessourceTodos), ListTodoPost).Methods("POST")
+	api.HandleFunc(UriRessourceSearch, SearchGet).Methods("GET")
+	api.HandleFunc(UriRessourceTags, TagsGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceTags, UriActionMerge), TagsMerge).Methods("POST")
+	api.HandleFunc(path.Join(UriRessourceTags, UriRessourceTagsPathParameterName, UriActionRename), TagRename).Methods("POST")
+	api.HandleFunc(UriRessourceWebhooks, WebhooksGet).Methods("GET")
+	api.HandleFunc(UriRessourceWebhooks, WebhookPost).Methods("POST")
+	// registered before the webhook id routes, so that "dead-letters" is not taken as id
+	api.HandleFunc(path.Join(UriRessourceWebhooks, UriRessourceDeadLetters), DeadLettersGet).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceWebhooks, UriRessourceDeadLetters, UriRessourceDeadLettersPathParameterName, UriActionRedeliver), DeadLetterRedeliver).Methods("POST")
+	api.HandleFunc(path.Join(UriRessourceWebhooks, UriRessourceDeadLetters, UriRessourceDeadLettersPathParameterName), DeadLetterDelete).Methods("DELETE")
+	api.HandleFunc(path.Join(UriRessourceWebhooks, UriRessourceWebhooksPathParameterName), WebhookGetById).Methods("GET")
+	api.HandleFunc(path.Join(UriRessourceWebhooks, UriRessourceWebhooksPathParameterName), WebhookPut).Methods("PUT")
+	api.HandleFunc(path.Join(UriRessourceWebhooks, UriRessourceWebhooksPathParameterName), WebhookDelete).Methods("DELETE")
+	api.HandleFunc(UriRessourceWebSocket, WebSocket).Methods("GET")
+	api.HandleFunc(UriActionUndo, Undo).Methods("POST")
+	return router
+}
 
-	fmt.Println("Backend running at:", backendHostUrl)
-	err = http.ListenAndServe(backendHostUrl, router)
+// readValidationRules returns the configured validation rules, the defaults of the models for the ones not configured
+func readValidationRules() (models.ValidationRules, error) {
+	rules := models.DefaultValidationRules()
+	var err error
+	rules.TitleMaxLength, err = configuration.GetTitleMaxLength(rules.TitleMaxLength)
 	if err != nil {
-		return err
+		return models.ValidationRules{}, err
 	}
-
-	return nil
+	rules.DescriptionMaxLength, err = configuration.GetDescriptionMaxLength(rules.DescriptionMaxLength)
+	if err != nil {
+		return models.ValidationRules{}, err
+	}
+	rules.TitlePattern, err = configuration.GetTitlePattern()
+	if err != nil {
+		return models.ValidationRules{}, err
+	}
+	rules.MaxBodySize, err = configuration.GetMaxBodySize(rules.MaxBodySize)
+	if err != nil {
+		return models.ValidationRules{}, err
+	}
+	rules.RejectUnknownFields, err = configuration.GetRejectUnknownFields()
+	if err != nil {
+		return models.ValidationRules{}, err
+	}
+	return rules, nil
 }
 
 // Index Handler for the index action
 // GET /
-func Index(writer http.ResponseWriter, _ *http.Request) {
+func Index(writer http.ResponseWriter, request *http.Request) {
 	writer.WriteHeader(http.StatusOK)
 	_, err := fmt.Fprintf(writer, "Welcome to the Todo REST API %s!\n", UriVersion)
 	if err != nil {
@@ -80,47 +279,81 @@ func Index(writer http.ResponseWriter, _ *http.Request) {
 }
 
 // TodosGet Handler for the todos get action
-// GET /todos
-func TodosGet(writer http.ResponseWriter, _ *http.Request) {
+// GET /todos?terminated=true&q=text&sort=-title,id&fields=id,title&limit=20&cursor=...
+// GET /todos?tree=true
+func TodosGet(writer http.ResponseWriter, request *http.Request) {
 	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	todos, err := models.ReadTodos()
+	query, err := models.ParseTodoQuery(request.URL.Query())
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writeTodoPage(writer, request, query)
+}
+
+// writeTodoPage writes the page (or in tree mode the nested todos) selected by the passed query
+func writeTodoPage(writer http.ResponseWriter, request *http.Request, query models.TodoQuery) {
+	if query.Tree {
+		writeTodoTree(writer, request, query)
+		return
+	}
+
+	page, err := models.ReadTodoPage(query)
 	if err != nil {
-		handleErrorAndDiscloseDetails(writer, http.StatusNotFound)
+		handleError(writer, request, err)
 		return
 	}
 
+	var response interface{} = models.JsonDataResponse{Meta: page.Meta, Data: page.Todos}
+	if len(query.Fields) > 0 {
+		selectedTodos, err := models.SelectFields(page.Todos, query.Fields)
+		if err != nil {
+			handleError(writer, request, err)
+			return
+		}
+		response = models.JsonPartialDataResponse{Meta: page.Meta, Data: selectedTodos}
+	}
+
+	setPaginationLinks(writer, request, page.Meta)
 	writer.WriteHeader(http.StatusOK)
-	sortedTodos := models.SortTodosAfterIdAscending(todos)
-	response := models.JsonDataResponse{Data: sortedTodos}
 	err = json.NewEncoder(writer).Encode(response)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func handleErrorAndDiscloseDetails(writer http.ResponseWriter, statusCode int) {
-	errorText := getGeneralErrorTextToAvoidDisclosingDetails()
-	handleError(writer, statusCode, errorText)
-}
-
-func getGeneralErrorTextToAvoidDisclosingDetails() string {
-	return GeneralErrorMessage
-}
-
-func handleError(writer http.ResponseWriter, statusCode int, text string) {
-	writer.WriteHeader(statusCode)
-	response := models.JsonErrorResponse{
-		Error: models.ApiError{
-			Status: statusCode,
-			Title:  text,
-		},
+// writeTodoTree writes the todos selected by the passed query nested below their parents
+func writeTodoTree(writer http.ResponseWriter, request *http.Request, query models.TodoQuery) {
+	nodes, err := models.ReadTodoTree(query)
+	if err != nil {
+		handleError(writer, request, err)
+		return
 	}
-	err := json.NewEncoder(writer).Encode(response)
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: nodes}
+	err = json.NewEncoder(writer).Encode(response)
 	if err != nil {
 		panic(err)
 	}
 }
 
+// setPaginationLinks sets the Link header (RFC 8288) pointing to the next and previous page
+func setPaginationLinks(writer http.ResponseWriter, request *http.Request, meta models.PageMeta) {
+	links := map[string]string{"next": meta.NextCursor, "prev": meta.PrevCursor}
+	for _, relation := range []string{"next", "prev"} {
+		cursor := links[relation]
+		if cursor == "" {
+			continue
+		}
+		values := request.URL.Query()
+		values.Set(models.QueryParameterCursor, cursor)
+		target := url.URL{Path: request.URL.Path, RawQuery: values.Encode()}
+		writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="%s"`, target.String(), relation))
+	}
+}
+
 // TodoGetById Handler for a todo get by id action
 func TodoGetById(writer http.ResponseWriter, request *http.Request) {
 	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -129,7 +362,13 @@ func TodoGetById(writer http.ResponseWriter, request *http.Request) {
 	id := vars["id"]
 	todoRead, err := models.ReadTodoById(id)
 	if err != nil {
-		handleErrorAndDiscloseDetails(writer, http.StatusNotFound)
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("ETag", models.ETag(todoRead))
+	if models.MatchesIfNoneMatch(request.Header.Get("If-None-Match"), todoRead) {
+		writer.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -147,16 +386,17 @@ func TodoPost(writer http.ResponseWriter, request *http.Request) {
 	var todoToCreate todo.Todo
 	err := decodeTodo(request, &todoToCreate)
 	if err != nil {
-		handleError(writer, http.StatusBadRequest, err.Error())
+		handleError(writer, request, err)
 		return
 	}
 
-	todoAdded, err := models.CreateTodo(todoToCreate)
+	todoAdded, err := models.CreateTodo(request.Context(), todoToCreate)
 	if err != nil {
-		handleErrorAndDiscloseDetails(writer, http.StatusBadRequest)
+		handleError(writer, request, err)
 		return
 	}
 
+	writer.Header().Set("ETag", models.ETag(todoAdded))
 	writer.WriteHeader(http.StatusCreated)
 	response := models.JsonExtendedResponse{Data: todoAdded}
 	err = json.NewEncoder(writer).Encode(response)
@@ -165,19 +405,16 @@ func TodoPost(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
-// decodeTodo decodes the json request body into a Todo
+// decodeTodo decodes the json request body into a Todo and validates it
 func decodeTodo(request *http.Request, todo *todo.Todo) error {
 	if request.Body == nil {
-		return errors.New("invalid body")
+		return fmt.Errorf("%w: invalid body", errInvalidRequest)
 	}
-	err := json.NewDecoder(request.Body).Decode(todo)
+	todoDecoded, err := models.DecodeTodo(request.Body)
 	if err != nil {
 		return err
 	}
-	if todo.Title == "" || todo.Description == "" {
-		err = errors.New("body: required fields missing")
-		return err
-	}
+	*todo = todoDecoded
 	return nil
 }
 
@@ -188,20 +425,30 @@ func TodoPut(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	id := vars["id"]
 
+	completeChildren, err := parseCompleteChildren(request)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
 	// Get update todo from request body
 	var todoToUpdate todo.Todo
-	err := decodeTodo(request, &todoToUpdate)
+	err = decodeTodo(request, &todoToUpdate)
 	if err != nil {
-		handleErrorAndDiscloseDetails(writer, http.StatusBadRequest)
+		handleError(writer, request, err)
 		return
 	}
 
-	todoUpdated, err := models.UpdateTodoById(id, todoToUpdate)
+	todoUpdated, err := models.UpdateTodoById(request.Context(), id, todoToUpdate, request.Header.Get("If-Match"))
 	if err != nil {
-		handleErrorAndDiscloseDetails(writer, http.StatusNotFound)
+		handleError(writer, request, err)
 		return
 	}
+	if completeChildren && todoUpdated.Terminated {
+		models.CompleteDescendantsAfterChange(request.Context(), id)
+	}
 
+	writer.Header().Set("ETag", models.ETag(todoUpdated))
 	writer.WriteHeader(http.StatusOK)
 	response := models.JsonExtendedResponse{Data: todoUpdated}
 	err = json.NewEncoder(writer).Encode(response)
@@ -210,6 +457,57 @@ func TodoPut(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
+// TodoPatch Handler for a todo patch by id action, accepting JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// PATCH /todos/{id}
+func TodoPatch(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	completeChildren, err := parseCompleteChildren(request)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		contentType = ""
+	}
+	if request.Body == nil {
+		handleError(writer, request, fmt.Errorf("%w: invalid body", errInvalidRequest))
+		return
+	}
+	patchDocument, err := models.ReadBody(request.Body)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	todoPatched, err := models.PatchTodoById(request.Context(), id, contentType, patchDocument, request.Header.Get("If-Match"))
+	if errors.Is(err, models.ErrUnsupportedPatchType) {
+		writer.Header().Set("Accept-Patch", models.MergePatchContentType+", "+models.JsonPatchContentType)
+		handleError(writer, request, err)
+		return
+	}
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+	if completeChildren && todoPatched.Terminated {
+		models.CompleteDescendantsAfterChange(request.Context(), id)
+	}
+
+	writer.Header().Set("ETag", models.ETag(todoPatched))
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: todoPatched}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // TodoDelete Handler for a todo delete by id action
 func TodoDelete(writer http.ResponseWriter, request *http.Request) {
 	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -220,9 +518,9 @@ func TodoDelete(writer http.ResponseWriter, request *http.Request) {
 
 	// Todo anhand der ID löschen
 	var todoToDelete todo.Todo
-	todoDeleted, err := models.DeleteTodoById(id, todoToDelete)
+	todoDeleted, err := models.DeleteTodoById(request.Context(), id, todoToDelete, request.Header.Get("If-Match"))
 	if err != nil {
-		handleErrorAndDiscloseDetails(writer, http.StatusNotFound)
+		handleError(writer, request, err)
 		return
 	}
 
@@ -232,5 +530,247 @@ func TodoDelete(writer http.ResponseWriter, request *http.Request) {
 	if err != nil {
 		panic(err)
 	}
+}
+
+// TrashGet Handler for the trash get action
+// GET /todos/trash
+func TrashGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	todos, err := models.ReadTrashedTodos()
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	sortedTodos := models.SortTodosAfterIdAscending(todos)
+	response := models.JsonDataResponse{Data: sortedTodos}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TodoRestore Handler for the todo restore from trash action
+// POST /todos/{id}/restore
+func TodoRestore(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	todoRestored, err := models.RestoreTodoById(request.Context(), id)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("ETag", models.ETag(todoRestored))
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: todoRestored}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TagRenameRequest type definition of the tag rename request body with json tags
+type TagRenameRequest struct {
+	Name string `json:"name"`
+}
+
+// TagMergeRequest type definition of the tag merge request body with json tags
+type TagMergeRequest struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+}
+
+// TagsGet Handler for the tags get action, returning the tags with their usage count
+// GET /tags
+func TagsGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	tagUsages, err := models.ReadTagUsage()
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: tagUsages}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TagRename Handler for the tag rename action
+// POST /tags/{tag}/rename
+func TagRename(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get tag from url parameters
+	vars := mux.Vars(request)
+	tag := vars["tag"]
+
+	var renameRequest TagRenameRequest
+	if request.Body == nil {
+		handleError(writer, request, fmt.Errorf("%w: invalid body", errInvalidRequest))
+		return
+	}
+	err := models.DecodeBody(request.Body, &renameRequest)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	updatedCount, err := models.RenameTag(request.Context(), tag, renameRequest.Name)
+	writeTagChangeResponse(writer, request, updatedCount, err)
+}
+
+// TagsMerge Handler for the tags merge action
+// POST /tags/merge
+func TagsMerge(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var mergeRequest TagMergeRequest
+	if request.Body == nil {
+		handleError(writer, request, fmt.Errorf("%w: invalid body", errInvalidRequest))
+		return
+	}
+	err := models.DecodeBody(request.Body, &mergeRequest)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	updatedCount, err := models.MergeTags(request.Context(), mergeRequest.Sources, mergeRequest.Target)
+	writeTagChangeResponse(writer, request, updatedCount, err)
+}
+
+// writeTagChangeResponse writes the response of a tag rename or merge, returning the current tag usage as data
+func writeTagChangeResponse(writer http.ResponseWriter, request *http.Request, updatedCount int, err error) {
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	tagUsages, err := models.ReadTagUsage()
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Meta: models.TagChangeMeta{UpdatedTodos: updatedCount}, Data: tagUsages}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// parseCompleteChildren parses the completeChildren query parameter (false when missing)
+func parseCompleteChildren(request *http.Request) (bool, error) {
+	values := request.URL.Query()
+	if !values.Has(QueryParameterCompleteChildren) {
+		return false, nil
+	}
+	completeChildren, err := strconv.ParseBool(values.Get(QueryParameterCompleteChildren))
+	if err != nil {
+		return false, fmt.Errorf("%w: parameter %q must be true or false", errInvalidRequest, QueryParameterCompleteChildren)
+	}
+	return completeChildren, nil
+}
+
+// TodoChildrenGet Handler for the subtasks of a todo get action
+// GET /todos/{id}/children
+func TodoChildrenGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	children, err := models.ReadChildren(id)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonDataResponse{Data: children}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
 
+// TodoBlockersGet Handler for the blockers of a todo get action
+// GET /todos/{id}/blockers
+func TodoBlockersGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	blockers, err := models.ReadBlockers(id)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonDataResponse{Data: blockers}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TodoOccurrencesGet Handler for the preview of the next occurrences of a recurring todo
+// GET /todos/{id}/occurrences?count=N
+func TodoOccurrencesGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	count := models.OccurrencesDefaultCount
+	values := request.URL.Query()
+	if values.Has(QueryParameterCount) {
+		var err error
+		count, err = strconv.Atoi(values.Get(QueryParameterCount))
+		if err != nil || count < 1 || count > models.OccurrencesMaxCount {
+			handleError(writer, request, fmt.Errorf("%w: parameter %q must be a number between 1 and %d",
+				errInvalidRequest, QueryParameterCount, models.OccurrencesMaxCount))
+			return
+		}
+	}
+
+	occurrences, meta, err := models.ReadOccurrences(id, count)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Meta: meta, Data: occurrences}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TodoHistoryGet Handler for the todo history get action, returning the stored changes of the todo oldest first
+// GET /todos/{id}/history
+func TodoHistoryGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	changes, err := models.ReadTodoHistory(id)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: changes}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/events"
+)
+
+// UriRessourceEvents uri sub ressource of the todos streaming their changes as Server-Sent Events
+const UriRessourceEvents = "/events"
+
+// QueryParameterId query parameter of the event stream, only events of the todo with this id (repeatable)
+const QueryParameterId = "id"
+
+// EventsHeartbeatInterval interval in which a comment is sent on an idle event stream, keeping proxies from closing it
+const EventsHeartbeatInterval = 15 * time.Second
+
+// EventTypeReset type of the event telling the client that events were missed and the todos have to be read again
+const EventTypeReset = "reset"
+
+// TodosEvents Handler for the stream of todo changes (Server-Sent Events)
+// GET /todos/events?id=1&id=2&terminated=false
+func TodosEvents(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		handleError(writer, request, errors.New("streaming not supported by the response writer"))
+		return
+	}
+	filter, err := parseEventFilter(request)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	subscription := models.SubscribeTodoEvents(request.Header.Get("Last-Event-ID"))
+	defer subscription.Close()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+
+	if subscription.Reset {
+		writeServerSentEvent(writer, subscription.LastId, EventTypeReset, struct{}{})
+	}
+	for _, event := range subscription.Replay {
+		if filter.Matches(event) {
+			writeServerSentEvent(writer, event.Id, string(event.Type), event)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(EventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, open := <-subscription.Events:
+			// closed when the client didn't keep up, it resumes by its last event id
+			if !open {
+				return
+			}
+			if !filter.Matches(event) {
+				continue
+			}
+			writeServerSentEvent(writer, event.Id, string(event.Type), event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(writer, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// parseEventFilter parses the filter of the event stream from the query parameters
+func parseEventFilter(request *http.Request) (events.Filter, error) {
+	values := request.URL.Query()
+	var filter events.Filter
+	for _, id := range values[QueryParameterId] {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			filter.Ids = append(filter.Ids, id)
+		}
+	}
+	if values.Has(models.QueryParameterTerminated) {
+		terminated, err := strconv.ParseBool(values.Get(models.QueryParameterTerminated))
+		if err != nil {
+			return events.Filter{}, fmt.Errorf("%w: parameter %q must be true or false", errInvalidRequest, models.QueryParameterTerminated)
+		}
+		filter.Terminated = &terminated
+	}
+	return filter, nil
+}
+
+// writeServerSentEvent writes an event in the text/event-stream format with the json encoded data
+func writeServerSentEvent(writer http.ResponseWriter, id string, eventType string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	// write errors of a closed connection end the stream by the cancelled request context
+	_, _ = fmt.Fprintf(writer, "id: %s\nevent: %s\ndata: %s\n\n", id, eventType, encoded)
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/list"
+	"todo-rest-backend/models/todo"
+)
+
+// UriRessourceLists uri ressource lists
+const UriRessourceLists = "/lists"
+
+// UriRessourceListsPathParameterName uri ressource lists path parameter name
+const UriRessourceListsPathParameterName = "{listId}"
+
+// QueryParameterCascade query parameter of the list delete action, deleting the todos of the list as well
+const QueryParameterCascade = "cascade"
+
+// ListsGet Handler for the lists get action
+// GET /lists
+func ListsGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	lists, err := models.ReadLists()
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: lists}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ListGetById Handler for a list get by id action
+// GET /lists/{listId}
+func ListGetById(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	listId := vars["listId"]
+	listRead, err := models.ReadListById(listId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: listRead}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ListPost Handler for the lists post action
+// POST /lists
+func ListPost(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var listToCreate list.List
+	err := decodeList(request, &listToCreate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	listAdded, err := models.CreateList(listToCreate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusCreated)
+	response := models.JsonExtendedResponse{Data: listAdded}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// decodeList decodes the json request body into a List and validates it
+func decodeList(request *http.Request, listToDecode *list.List) error {
+	if request.Body == nil {
+		return fmt.Errorf("%w: invalid body", errInvalidRequest)
+	}
+	listDecoded, err := models.DecodeList(request.Body)
+	if err != nil {
+		return err
+	}
+	*listToDecode = listDecoded
+	return nil
+}
+
+// ListPut Handler for a list put by id action
+// PUT /lists/{listId}
+func ListPut(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	listId := vars["listId"]
+
+	var listToUpdate list.List
+	err := decodeList(request, &listToUpdate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	listUpdated, err := models.UpdateListById(listId, listToUpdate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: listUpdated}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ListDelete Handler for a list delete by id action. A list containing todos is only deleted with cascade=true.
+// DELETE /lists/{listId}?cascade=true
+func ListDelete(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	listId := vars["listId"]
+
+	cascade := false
+	values := request.URL.Query()
+	if values.Has(QueryParameterCascade) {
+		var err error
+		cascade, err = strconv.ParseBool(values.Get(QueryParameterCascade))
+		if err != nil {
+			handleError(writer, request, fmt.Errorf("%w: parameter %q must be true or false", errInvalidRequest, QueryParameterCascade))
+			return
+		}
+	}
+
+	listDeleted, err := models.DeleteListById(request.Context(), listId, cascade)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: listDeleted}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ListTodosGet Handler for the todos of a list get action, supporting the query parameters of the todo list
+// GET /lists/{listId}/todos
+func ListTodosGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	listId := vars["listId"]
+	_, err := models.ReadListById(listId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	query, err := models.ParseTodoQuery(request.URL.Query())
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+	query.Filter.ListId = listId
+
+	writeTodoPage(writer, request, query)
+}
+
+// ListTodoPost Handler for the todo in a list post action
+// POST /lists/{listId}/todos
+func ListTodoPost(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	listId := vars["listId"]
+	_, err := models.ReadListById(listId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	var todoToCreate todo.Todo
+	err = decodeTodo(request, &todoToCreate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+	todoToCreate.ListId = listId
+
+	todoAdded, err := models.CreateTodo(request.Context(), todoToCreate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("ETag", models.ETag(todoAdded))
+	writer.WriteHeader(http.StatusCreated)
+	response := models.JsonExtendedResponse{Data: todoAdded}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/search"
+)
+
+// ProblemContentType content type of the error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeUriPrefix prefix of the problem type uris, followed by the problem code
+const ProblemTypeUriPrefix = "urn:todo-rest-backend:problem:"
+
+// Codes of the problems
+const (
+	ProblemCodeInvalidRequest       = "invalid-request"
+	ProblemCodeInvalidBody          = "invalid-body"
+	ProblemCodeBodyTooLarge         = "body-too-large"
+	ProblemCodeValidationFailed     = "validation-failed"
+	ProblemCodeInvalidQuery         = "invalid-query"
+	ProblemCodeInvalidSearchQuery   = "invalid-search-query"
+	ProblemCodeInvalidPatch         = "invalid-patch"
+	ProblemCodeInvalidBatch         = "invalid-batch"
+	ProblemCodeInvalidOperation     = "invalid-operation"
+	ProblemCodeInvalidTag           = "invalid-tag"
+	ProblemCodeUnknownList          = "unknown-list"
+	ProblemCodeInvalidParent        = "invalid-parent"
+	ProblemCodeInvalidBlocker       = "invalid-blocker"
+	ProblemCodeNotFound             = "not-found"
+	ProblemCodeConflict             = "conflict"
+	ProblemCodeBlocked              = "blocked"
+	ProblemCodeListNotEmpty         = "list-not-empty"
+	ProblemCodePreconditionFailed   = "precondition-failed"
+	ProblemCodeUnsupportedPatchType = "unsupported-patch-type"
+	ProblemCodeBatchAborted         = "batch-aborted"
+	ProblemCodeHistoryUnsupported   = "history-unsupported"
+	ProblemCodeStorageError         = "storage-error"
+	ProblemCodeInternalError        = "internal-error"
+)
+
+// errInvalidRequest is the cause of problems with the request itself, e.g. a malformed body or query parameter
+var errInvalidRequest = errors.New("invalid request")
+
+// problemMapping maps the errors matching target to a problem
+type problemMapping struct {
+	target error
+	status int
+	code   string
+	title  string
+}
+
+// problemMappings in order of precedence: the errors of the models first, the generic repository errors last.
+// Errors not matching any of them are internal errors.
+var problemMappings = []problemMapping{
+	{models.ErrPreconditionFailed, http.StatusPreconditionFailed, ProblemCodePreconditionFailed, "Precondition failed"},
+	{repositories.ErrVersionMismatch, http.StatusPreconditionFailed, ProblemCodePreconditionFailed, "Precondition failed"},
+	{models.ErrUnsupportedPatchType, http.StatusUnsupportedMediaType, ProblemCodeUnsupportedPatchType, "Unsupported patch type"},
+	{models.ErrInvalidPatch, http.StatusBadRequest, ProblemCodeInvalidPatch, "Invalid patch"},
+	{models.ErrBodyTooLarge, http.StatusRequestEntityTooLarge, ProblemCodeBodyTooLarge, "Body too large"},
+	{models.ErrInvalidBody, http.StatusBadRequest, ProblemCodeInvalidBody, "Invalid body"},
+	{models.ErrInvalidQuery, http.StatusBadRequest, ProblemCodeInvalidQuery, "Invalid query"},
+	{search.ErrInvalidQuery, http.StatusBadRequest, ProblemCodeInvalidSearchQuery, "Invalid search query"},
+	{models.ErrInvalidBatch, http.StatusBadRequest, ProblemCodeInvalidBatch, "Invalid batch"},
+	{models.ErrInvalidOperation, http.StatusBadRequest, ProblemCodeInvalidOperation, "Invalid batch operation"},
+	{models.ErrInvalidTag, http.StatusBadRequest, ProblemCodeInvalidTag, "Invalid tag"},
+	{models.ErrUnknownList, http.StatusBadRequest, ProblemCodeUnknownList, "Unknown list"},
+	{models.ErrInvalidParent, http.StatusBadRequest, ProblemCodeInvalidParent, "Invalid parent"},
+	{models.ErrInvalidBlocker, http.StatusBadRequest, ProblemCodeInvalidBlocker, "Invalid blocker"},
+	{errInvalidRequest, http.StatusBadRequest, ProblemCodeInvalidRequest, "Invalid request"},
+	{repositories.ErrValidation, http.StatusBadRequest, ProblemCodeValidationFailed, "Validation failed"},
+	{models.ErrBlocked, http.StatusConflict, ProblemCodeBlocked, "Todo is blocked"},
+	{models.ErrListNotEmpty, http.StatusConflict, ProblemCodeListNotEmpty, "List contains todos"},
+	{repositories.ErrBatchAborted, http.StatusFailedDependency, ProblemCodeBatchAborted, "Batch aborted"},
+	{repositories.ErrHistoryUnsupported, http.StatusNotImplemented, ProblemCodeHistoryUnsupported, "History not supported"},
+	{repositories.ErrNotFound, http.StatusNotFound, ProblemCodeNotFound, "Not found"},
+	{repositories.ErrConflict, http.StatusConflict, ProblemCodeConflict, "Conflict"},
+	{repositories.ErrStorage, http.StatusInternalServerError, ProblemCodeStorageError, "Storage error"},
+}
+
+// problemOf returns the problem describing the passed error which occurred at the passed instance.
+// The details of server errors are replaced by GeneralErrorMessage to avoid disclosing internals.
+func problemOf(err error, instance string) models.ApiError {
+	problem := models.ApiError{
+		Title:  "Internal server error",
+		Status: http.StatusInternalServerError,
+		Code:   ProblemCodeInternalError,
+	}
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.target) {
+			problem.Title = mapping.title
+			problem.Status = mapping.status
+			problem.Code = mapping.code
+			break
+		}
+	}
+
+	problem.Type = ProblemTypeUriPrefix + problem.Code
+	problem.Instance = instance
+	problem.Detail = err.Error()
+	if problem.Status >= http.StatusInternalServerError {
+		problem.Detail = GeneralErrorMessage
+	}
+	var validationError *repositories.ValidationError
+	if errors.As(err, &validationError) {
+		problem.Errors = validationError.Fields
+	}
+	return problem
+}
+
+// handleError writes the problem describing the passed error as response. Server errors are logged,
+// since their details are not disclosed.
+func handleError(writer http.ResponseWriter, request *http.Request, err error) {
+	problem := problemOf(err, request.URL.RequestURI())
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", request.Method, request.URL.RequestURI(), err)
+	}
+
+	writer.Header().Set("Content-Type", ProblemContentType)
+	writer.WriteHeader(problem.Status)
+	encodeErr := json.NewEncoder(writer).Encode(problem)
+	if encodeErr != nil {
+		panic(encodeErr)
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/revision"
+)
+
+// HeaderActor request header naming the actor the revisions of the changes are attributed to. It isn't authenticated,
+// any client can pass any name: it is an untrusted attribution and grants nothing, undo is keyed by HeaderUndoSession.
+const HeaderActor = "X-Actor"
+
+// HeaderUndoSession request and response header carrying the undo session token. /undo reverts the last change made
+// with the token of its request. A new token is issued when a request has none or a malformed one.
+const HeaderUndoSession = "X-Undo-Session"
+
+// UriRessourceRevisions uri ressource revisions of a todo
+const UriRessourceRevisions = "/revisions"
+
+// UriRessourceRevisionsPathParameterName uri ressource revisions path parameter name
+const UriRessourceRevisionsPathParameterName = "{revision}"
+
+// UriActionUndo uri action undo of the last change of the undo session
+const UriActionUndo = "/undo"
+
+// actorOf returns the actor of the request: the X-Actor header when passed, the client address otherwise
+func actorOf(request *http.Request) string {
+	actor := strings.TrimSpace(request.Header.Get(HeaderActor))
+	if actor != "" {
+		return actor
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// withOrigin passes the origin of the changes made by the request to the handlers in the request context: the actor
+// (actorOf), the undo session of the request and a new change id. The undo session token is returned in the response.
+func withOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		token := request.Header.Get(HeaderUndoSession)
+		if !revision.IsSessionToken(token) {
+			token = revision.NewSessionToken()
+		}
+		writer.Header().Set(HeaderUndoSession, token)
+		origin := revision.Origin{Actor: actorOf(request), Session: revision.SessionKey(token), ChangeId: revision.NewChangeId()}
+		next.ServeHTTP(writer, request.WithContext(revision.NewContext(request.Context(), origin)))
+	})
+}
+
+// revisionNumberOf returns the revision number of the url parameters
+func revisionNumberOf(request *http.Request) (int, error) {
+	number, err := strconv.Atoi(mux.Vars(request)["revision"])
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%w: revision must be a positive number", errInvalidRequest)
+	}
+	return number, nil
+}
+
+// TodoRevisionsGet Handler for the todo revisions get action, returning the revisions of the todo oldest first
+// GET /todos/{id}/revisions
+func TodoRevisionsGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	revisions, err := models.ReadRevisions(id)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: revisions}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TodoRevisionGet Handler for the todo revision get action
+// GET /todos/{id}/revisions/{revision}
+func TodoRevisionGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id and revision from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	number, err := revisionNumberOf(request)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+	revisionRead, err := models.ReadRevision(id, number)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: revisionRead}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TodoRevisionRestore Handler for the todo revision restore action, updating the todo to its state after the revision
+// POST /todos/{id}/revisions/{revision}/restore
+func TodoRevisionRestore(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id and revision from url parameters
+	vars := mux.Vars(request)
+	id := vars["id"]
+	number, err := revisionNumberOf(request)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+	todoRestored, err := models.RestoreRevision(request.Context(), id, number, request.Header.Get("If-Match"))
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.Header().Set("ETag", models.ETag(todoRestored))
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: todoRestored}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Undo Handler for the undo action, reverting the last change of the undo session of the request
+// POST /undo
+func Undo(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	todosUndone, revisionsUndone, err := models.UndoLastChange(request.Context())
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	meta := models.UndoMeta{ChangeId: revisionsUndone[0].ChangeId, UndoneRevisions: revisionsUndone}
+	response := models.JsonExtendedResponse{Meta: meta, Data: todosUndone}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
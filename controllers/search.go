@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo-rest-backend/models"
+)
+
+// UriRessourceSearch uri ressource of the full-text search
+const UriRessourceSearch = "/search"
+
+// SearchGet Handler for the full-text search over the todos (without trash)
+// GET /search?q=...&limit=N
+func SearchGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	values := request.URL.Query()
+	query := values.Get(models.QueryParameterText)
+	if strings.TrimSpace(query) == "" {
+		handleError(writer, request, fmt.Errorf("%w: parameter %q is required", errInvalidRequest, models.QueryParameterText))
+		return
+	}
+	limit := models.SearchDefaultLimit
+	if values.Has(models.QueryParameterLimit) {
+		var err error
+		limit, err = strconv.Atoi(values.Get(models.QueryParameterLimit))
+		if err != nil || limit < 1 || limit > models.SearchMaxLimit {
+			handleError(writer, request, fmt.Errorf("%w: parameter %q must be a number between 1 and %d",
+				errInvalidRequest, models.QueryParameterLimit, models.SearchMaxLimit))
+			return
+		}
+	}
+
+	todos, meta, err := models.SearchTodos(query, limit)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonDataResponse{Meta: meta, Data: todos}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/webhook"
+)
+
+// UriRessourceWebhooks uri ressource webhooks
+const UriRessourceWebhooks = "/webhooks"
+
+// UriRessourceWebhooksPathParameterName uri ressource webhooks path parameter name
+const UriRessourceWebhooksPathParameterName = "{webhookId}"
+
+// UriRessourceDeadLetters uri sub ressource of the webhooks for the deliveries which failed for good
+const UriRessourceDeadLetters = "/dead-letters"
+
+// UriRessourceDeadLettersPathParameterName uri ressource dead letters path parameter name
+const UriRessourceDeadLettersPathParameterName = "{deadLetterId}"
+
+// UriActionRedeliver uri action delivering a dead letter again
+const UriActionRedeliver = "/redeliver"
+
+// WebhooksGet Handler for the webhooks get action
+// GET /webhooks
+func WebhooksGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	webhooks, err := models.ReadWebhooks()
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: webhooks}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// WebhookGetById Handler for a webhook get by id action
+// GET /webhooks/{webhookId}
+func WebhookGetById(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	webhookId := vars["webhookId"]
+	webhookRead, err := models.ReadWebhookById(webhookId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: webhookRead}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// WebhookPost Handler for the webhooks post action, the response contains the secret of the signatures
+// POST /webhooks
+func WebhookPost(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var webhookToCreate webhook.Webhook
+	err := decodeWebhook(request, &webhookToCreate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	webhookAdded, err := models.CreateWebhook(webhookToCreate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusCreated)
+	response := models.JsonExtendedResponse{Data: webhookAdded}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// decodeWebhook decodes the json request body into a Webhook and validates it
+func decodeWebhook(request *http.Request, webhookToDecode *webhook.Webhook) error {
+	if request.Body == nil {
+		return fmt.Errorf("%w: invalid body", errInvalidRequest)
+	}
+	webhookDecoded, err := models.DecodeWebhook(request.Body)
+	if err != nil {
+		return err
+	}
+	*webhookToDecode = webhookDecoded
+	return nil
+}
+
+// WebhookPut Handler for a webhook put by id action, the secret is kept when none is passed
+// PUT /webhooks/{webhookId}
+func WebhookPut(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	webhookId := vars["webhookId"]
+
+	var webhookToUpdate webhook.Webhook
+	err := decodeWebhook(request, &webhookToUpdate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	webhookUpdated, err := models.UpdateWebhookById(webhookId, webhookToUpdate)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: webhookUpdated}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// WebhookDelete Handler for a webhook delete by id action
+// DELETE /webhooks/{webhookId}
+func WebhookDelete(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	webhookId := vars["webhookId"]
+	webhookDeleted, err := models.DeleteWebhookById(webhookId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: webhookDeleted}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DeadLettersGet Handler for the dead letters get action, returning the failed deliveries oldest first
+// GET /webhooks/dead-letters
+func DeadLettersGet(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	deadLetters, err := models.ReadDeadLetters()
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: deadLetters}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DeadLetterRedeliver Handler for the dead letter redeliver action, the delivery is retried in the background
+// POST /webhooks/dead-letters/{deadLetterId}/redeliver
+func DeadLetterRedeliver(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	deadLetterId := vars["deadLetterId"]
+	deadLetter, err := models.RedeliverDeadLetter(deadLetterId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+	response := models.JsonExtendedResponse{Data: deadLetter}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DeadLetterDelete Handler for a dead letter delete by id action, discarding the failed delivery
+// DELETE /webhooks/dead-letters/{deadLetterId}
+func DeadLetterDelete(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	// Get id from url parameters
+	vars := mux.Vars(request)
+	deadLetterId := vars["deadLetterId"]
+	deadLetter, err := models.DiscardDeadLetter(deadLetterId)
+	if err != nil {
+		handleError(writer, request, err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	response := models.JsonExtendedResponse{Data: deadLetter}
+	err = json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		panic(err)
+	}
+}
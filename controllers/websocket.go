@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/todo"
+
+	"github.com/gorilla/websocket"
+)
+
+// UriRessourceWebSocket uri ressource of the bidirectional WebSocket api
+const UriRessourceWebSocket = "/ws"
+
+// Types of the WebSocket commands sent by the clients
+const (
+	WebSocketSubscribe   = "subscribe"
+	WebSocketUnsubscribe = "unsubscribe"
+	WebSocketCreate      = "create"
+	WebSocketUpdate      = "update"
+	WebSocketDelete      = "delete"
+)
+
+// Types of the WebSocket messages sent by the server
+const (
+	WebSocketReply = "reply" // answer to a command
+	WebSocketEvent = "event" // change of a todo by another client
+	WebSocketReset = "reset" // events were missed, the todos have to be read again
+)
+
+// WebSocket connection timing
+const (
+	webSocketWriteWait  = 10 * time.Second
+	webSocketPongWait   = 60 * time.Second
+	webSocketPingPeriod = webSocketPongWait * 9 / 10
+)
+
+// WebSocketQueueSize number of messages queued for a client. Replies wait for free space, so a client not reading
+// its messages isn't served further commands; a client falling behind the events is disconnected.
+const WebSocketQueueSize = 64
+
+// WebSocketCommand command sent by a client with json tags
+type WebSocketCommand struct {
+	RequestId   string          `json:"requestId"` // echoed in the reply
+	Type        string          `json:"type"`
+	Id          string          `json:"id,omitempty"`          // todo to update or delete
+	IfMatch     string          `json:"ifMatch,omitempty"`     // optional precondition like the If-Match header
+	Todo        json.RawMessage `json:"todo,omitempty"`        // todo to create or update
+	Ids         []string        `json:"ids,omitempty"`         // subscribe: only events of these todos
+	Terminated  *bool           `json:"terminated,omitempty"`  // subscribe: only events of todos with this state
+	LastEventId string          `json:"lastEventId,omitempty"` // subscribe: replay the buffered events after it
+}
+
+// WebSocketMessage message sent by the server with json tags
+type WebSocketMessage struct {
+	Type        string           `json:"type"`
+	RequestId   string           `json:"requestId,omitempty"`   // reply: of the command
+	Status      int              `json:"status,omitempty"`      // reply: HTTP status the command would have as request
+	Todo        *todo.Todo       `json:"todo,omitempty"`        // reply: created, updated or deleted todo
+	Error       *models.ApiError `json:"error,omitempty"`       // reply: reason of the failure
+	Event       *events.Event    `json:"event,omitempty"`       // event
+	LastEventId string           `json:"lastEventId,omitempty"` // reset: id to resume from
+}
+
+var webSocketUpgrader = websocket.Upgrader{}
+
+// WebSocket Handler of the WebSocket api, carrying commands with their replies and the change events
+// GET /ws
+func WebSocket(writer http.ResponseWriter, request *http.Request) {
+	// the upgrader answers failed upgrades itself; the changes of the commands belong to the undo session of the upgrade
+	conn, err := webSocketUpgrader.Upgrade(writer, request,
+		http.Header{HeaderUndoSession: writer.Header().Values(HeaderUndoSession)})
+	if err != nil {
+		return
+	}
+	session := &webSocketSession{
+		conn:       conn,
+		instance:   request.URL.RequestURI(),
+		ctx:        request.Context(),
+		outgoing:   make(chan WebSocketMessage, WebSocketQueueSize),
+		done:       make(chan struct{}),
+		ownChanges: map[string]bool{},
+	}
+	go session.writeMessages()
+	session.readCommands()
+	session.close()
+}
+
+// webSocketSession state of a WebSocket connection
+type webSocketSession struct {
+	conn     *websocket.Conn
+	instance string
+	ctx      context.Context // carries the origin of the changes of the commands, see commandContext
+	outgoing chan WebSocketMessage
+	done     chan struct{}
+	closing  sync.Once
+
+	// guards the subscription and the own changes; held while a command changes todos, so that the
+	// change events of the command are recognized as own changes
+	mutex        sync.Mutex
+	subscription *events.Subscription
+	ownChanges   map[string]bool // todo id and version of the changes made by the commands, not sent back as events
+
+	// goroutines forwarding the events of the subscriptions
+	subscribers sync.WaitGroup
+}
+
+// readCommands reads and answers the commands until the connection fails or is closed
+func (s *webSocketSession) readCommands() {
+	s.conn.SetReadLimit(models.MaxBodySize())
+	_ = s.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	})
+	for {
+		_, content, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = s.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+		reply, afterReply := s.handleCommand(content)
+		if !s.enqueue(reply) {
+			return
+		}
+		if afterReply != nil {
+			afterReply()
+		}
+	}
+}
+
+// writeMessages writes the queued messages and pings until the session is closed
+func (s *webSocketSession) writeMessages() {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case message := <-s.outgoing:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			err := s.conn.WriteJSON(message)
+			if err != nil {
+				_ = s.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait))
+			if err != nil {
+				_ = s.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// enqueue queues the message, waiting for free space; returns false when the session is closed
+func (s *webSocketSession) enqueue(message WebSocketMessage) bool {
+	select {
+	case s.outgoing <- message:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// close ends the session after the connection was closed
+func (s *webSocketSession) close() {
+	s.mutex.Lock()
+	if s.subscription != nil {
+		s.subscription.Close()
+		s.subscription = nil
+	}
+	s.mutex.Unlock()
+	s.closing.Do(func() { close(s.done) })
+	_ = s.conn.Close()
+	s.subscribers.Wait()
+}
+
+// closeSlow disconnects a client which doesn't keep up with the events; it can resubscribe with its last event id
+func (s *webSocketSession) closeSlow() {
+	message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow, resubscribe with the last event id")
+	_ = s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(webSocketWriteWait))
+	_ = s.conn.Close()
+}
+
+// handleCommand executes the passed command and returns the reply. afterReply is run once the reply is queued.
+func (s *webSocketSession) handleCommand(content []byte) (reply WebSocketMessage, afterReply func()) {
+	var command WebSocketCommand
+	err := models.DecodeBody(bytes.NewReader(content), &command)
+	if err != nil {
+		return s.failure(command.RequestId, err), nil
+	}
+	if command.RequestId == "" {
+		return s.failure("", fmt.Errorf("%w: requestId is required", errInvalidRequest)), nil
+	}
+
+	switch command.Type {
+	case WebSocketSubscribe:
+		subscription, filter := s.subscribe(command)
+		return WebSocketMessage{Type: WebSocketReply, RequestId: command.RequestId, Status: http.StatusOK}, func() {
+			s.subscribers.Add(1)
+			go s.forwardEvents(subscription, filter)
+		}
+	case WebSocketUnsubscribe:
+		s.unsubscribe()
+		return WebSocketMessage{Type: WebSocketReply, RequestId: command.RequestId, Status: http.StatusOK}, nil
+	case WebSocketCreate, WebSocketUpdate, WebSocketDelete:
+		changedTodo, status, err := s.change(command)
+		if err != nil {
+			return s.failure(command.RequestId, err), nil
+		}
+		return WebSocketMessage{Type: WebSocketReply, RequestId: command.RequestId, Status: status, Todo: &changedTodo}, nil
+	default:
+		return s.failure(command.RequestId, fmt.Errorf("%w: unknown type %q", errInvalidRequest, command.Type)), nil
+	}
+}
+
+// failure returns the reply to a failed command
+func (s *webSocketSession) failure(requestId string, err error) WebSocketMessage {
+	problem := problemOf(err, s.instance)
+	return WebSocketMessage{Type: WebSocketReply, RequestId: requestId, Status: problem.Status, Error: &problem}
+}
+
+// change creates, updates or deletes a todo and returns it with the HTTP status of the corresponding request
+func (s *webSocketSession) change(command WebSocketCommand) (todo.Todo, int, error) {
+	if command.Type != WebSocketCreate && command.Id == "" {
+		return todo.Todo{}, 0, fmt.Errorf("%w: id is required", errInvalidRequest)
+	}
+	var todoChange todo.Todo
+	if command.Type != WebSocketDelete {
+		if len(command.Todo) == 0 {
+			return todo.Todo{}, 0, fmt.Errorf("%w: todo is required", errInvalidRequest)
+		}
+		var err error
+		todoChange, err = models.DecodeTodo(bytes.NewReader(command.Todo))
+		if err != nil {
+			return todo.Todo{}, 0, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ctx := s.commandContext()
+	var changedTodo todo.Todo
+	var err error
+	status := http.StatusOK
+	switch command.Type {
+	case WebSocketCreate:
+		changedTodo, err = models.CreateTodo(ctx, todoChange)
+		status = http.StatusCreated
+	case WebSocketUpdate:
+		changedTodo, err = models.UpdateTodoById(ctx, command.Id, todoChange, command.IfMatch)
+	default:
+		changedTodo, err = models.DeleteTodoById(ctx, command.Id, todo.Todo{}, command.IfMatch)
+	}
+	if err != nil {
+		return todo.Todo{}, 0, err
+	}
+	if s.subscription != nil {
+		s.ownChanges[changeKey(changedTodo)] = true
+	}
+	return changedTodo, status, nil
+}
+
+// commandContext returns the context of the change of a command: the origin of the upgrade request (actor and undo
+// session) with a new change id, so that every command is undone on its own
+func (s *webSocketSession) commandContext() context.Context {
+	origin := revision.FromContext(s.ctx)
+	origin.ChangeId = revision.NewChangeId()
+	return revision.NewContext(s.ctx, origin)
+}
+
+// subscribe replaces the subscription of the session by a new one with the filter of the command
+func (s *webSocketSession) subscribe(command WebSocketCommand) (*events.Subscription, events.Filter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.subscription != nil {
+		s.subscription.Close()
+	}
+	s.subscription = models.SubscribeTodoEvents(command.LastEventId)
+	return s.subscription, events.Filter{Ids: command.Ids, Terminated: command.Terminated}
+}
+
+func (s *webSocketSession) unsubscribe() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.subscription != nil {
+		s.subscription.Close()
+		s.subscription = nil
+	}
+	clear(s.ownChanges)
+}
+
+// forwardEvents queues the events of the subscription matching the filter, except the ones of own changes
+func (s *webSocketSession) forwardEvents(subscription *events.Subscription, filter events.Filter) {
+	defer s.subscribers.Done()
+	// the replay is bounded by the event buffer, so waiting for free space is fine
+	if subscription.Reset && !s.enqueue(WebSocketMessage{Type: WebSocketReset, LastEventId: subscription.LastId}) {
+		return
+	}
+	for _, event := range subscription.Replay {
+		if filter.Matches(event) && !s.enqueue(WebSocketMessage{Type: WebSocketEvent, Event: &event}) {
+			return
+		}
+	}
+
+	for event := range subscription.Events {
+		if s.isOwnChange(event) || !filter.Matches(event) {
+			continue
+		}
+		select {
+		case s.outgoing <- WebSocketMessage{Type: WebSocketEvent, Event: &event}:
+		case <-s.done:
+			return
+		default:
+			s.closeSlow()
+			return
+		}
+	}
+
+	// closed by unsubscribing or by the broker, when the client didn't keep up
+	s.mutex.Lock()
+	dropped := s.subscription == subscription
+	s.mutex.Unlock()
+	if dropped {
+		s.closeSlow()
+	}
+}
+
+// isOwnChange checks whether the event is the change of a command of the session (consuming the recorded change)
+func (s *webSocketSession) isOwnChange(event events.Event) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := changeKey(event.Todo)
+	if !s.ownChanges[key] {
+		return false
+	}
+	delete(s.ownChanges, key)
+	return true
+}
+
+// changeKey identifies a change of a todo by its id and resulting version
+func changeKey(changedTodo todo.Todo) string {
+	return changedTodo.Id + "@" + strconv.FormatInt(changedTodo.Version, 10)
+}
@@ -0,0 +1,263 @@
+// Package wsclient contains a client of the WebSocket api. DialHandler serves the api in-process, so that it can be
+// tested without a running backend (the models have to be set up before, e.g. with a memory repository).
+package wsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo-rest-backend/controllers"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/todo"
+
+	"github.com/gorilla/websocket"
+)
+
+// ReplyTimeout time a command waits for its reply
+const ReplyTimeout = 10 * time.Second
+
+// EventQueueSize number of received events queued until they are taken by NextEvent. When the queue is full,
+// the client stops reading, so that the server sees it as slow consumer.
+const EventQueueSize = 256
+
+// ErrClosed is returned when the connection is closed
+var ErrClosed = errors.New("connection closed")
+
+// ErrTimeout is returned when no reply or event arrived in time
+var ErrTimeout = errors.New("timeout")
+
+// ReplyError failure reply of a command
+type ReplyError struct {
+	Status  int
+	Problem models.ApiError
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Problem.Code, e.Problem.Detail)
+}
+
+// Client connection to the WebSocket api
+type Client struct {
+	conn    *websocket.Conn
+	events  chan controllers.WebSocketMessage
+	done    chan struct{} // closed when the connection is closed
+	closed  chan struct{} // closed by Close, stops waiting for free space in the event queue
+	closing sync.Once
+
+	mutex         sync.Mutex
+	pending       map[string]chan controllers.WebSocketMessage // replies awaited by request id
+	nextRequestId uint64
+	err           error // reason the connection was closed
+
+	writeMutex sync.Mutex
+}
+
+// Dial connects to the WebSocket api at the passed url (ws:// or wss://)
+func Dial(url string) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(conn), nil
+}
+
+// newClient returns a client of the passed connection, reading its messages
+func newClient(conn *websocket.Conn) *Client {
+	client := &Client{
+		conn:    conn,
+		events:  make(chan controllers.WebSocketMessage, EventQueueSize),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+		pending: map[string]chan controllers.WebSocketMessage{},
+	}
+	go client.readMessages()
+	return client
+}
+
+// DialHandler serves the passed handler (usually controllers.NewRouter()) by an in-process server and connects
+// to its WebSocket api. The returned function closes the client and stops the server.
+func DialHandler(handler http.Handler) (*Client, func(), error) {
+	server := httptest.NewServer(handler)
+	client, err := Dial(webSocketUrl(server))
+	if err != nil {
+		server.Close()
+		return nil, nil, err
+	}
+	return client, func() {
+		_ = client.Close()
+		server.Close()
+	}, nil
+}
+
+// webSocketUrl returns the url of the WebSocket api served by the passed server
+func webSocketUrl(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http") +
+		path.Join(controllers.UriBasePath, controllers.UriVersion, controllers.UriRessourceWebSocket)
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	c.closing.Do(func() { close(c.closed) })
+	c.writeMutex.Lock()
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	c.writeMutex.Unlock()
+	return c.conn.Close()
+}
+
+// Err returns the reason the connection was closed, nil while it is open
+func (c *Client) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// Send sends the command and returns its reply. A request id is assigned when the command has none.
+// Failure replies are returned as reply and ReplyError.
+func (c *Client) Send(command controllers.WebSocketCommand) (controllers.WebSocketMessage, error) {
+	replies := make(chan controllers.WebSocketMessage, 1)
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return controllers.WebSocketMessage{}, c.err
+	}
+	if command.RequestId == "" {
+		c.nextRequestId++
+		command.RequestId = strconv.FormatUint(c.nextRequestId, 10)
+	}
+	c.pending[command.RequestId] = replies
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, command.RequestId)
+		c.mutex.Unlock()
+	}()
+
+	c.writeMutex.Lock()
+	err := c.conn.WriteJSON(command)
+	c.writeMutex.Unlock()
+	if err != nil {
+		return controllers.WebSocketMessage{}, err
+	}
+
+	timer := time.NewTimer(ReplyTimeout)
+	defer timer.Stop()
+	select {
+	case reply := <-replies:
+		if reply.Error != nil {
+			return reply, &ReplyError{Status: reply.Status, Problem: *reply.Error}
+		}
+		return reply, nil
+	case <-c.done:
+		return controllers.WebSocketMessage{}, c.Err()
+	case <-timer.C:
+		return controllers.WebSocketMessage{}, fmt.Errorf("%w: no reply to request %s", ErrTimeout, command.RequestId)
+	}
+}
+
+// Subscribe subscribes the events matching the filter, replaying the buffered ones after lastEventId when passed
+func (c *Client) Subscribe(filter events.Filter, lastEventId string) error {
+	_, err := c.Send(controllers.WebSocketCommand{Type: controllers.WebSocketSubscribe, Ids: filter.Ids,
+		Terminated: filter.Terminated, LastEventId: lastEventId})
+	return err
+}
+
+// Unsubscribe ends the subscription of the events
+func (c *Client) Unsubscribe() error {
+	_, err := c.Send(controllers.WebSocketCommand{Type: controllers.WebSocketUnsubscribe})
+	return err
+}
+
+// Create creates the todo and returns the created one
+func (c *Client) Create(todoToCreate todo.Todo) (todo.Todo, error) {
+	return c.change(controllers.WebSocketCommand{Type: controllers.WebSocketCreate}, &todoToCreate)
+}
+
+// Update updates the todo with passed id and returns the updated one; ifMatch is an optional ETag precondition
+func (c *Client) Update(id string, todoUpdate todo.Todo, ifMatch string) (todo.Todo, error) {
+	return c.change(controllers.WebSocketCommand{Type: controllers.WebSocketUpdate, Id: id, IfMatch: ifMatch}, &todoUpdate)
+}
+
+// Delete deletes the todo with passed id and returns the deleted one; ifMatch is an optional ETag precondition
+func (c *Client) Delete(id string, ifMatch string) (todo.Todo, error) {
+	return c.change(controllers.WebSocketCommand{Type: controllers.WebSocketDelete, Id: id, IfMatch: ifMatch}, nil)
+}
+
+func (c *Client) change(command controllers.WebSocketCommand, changedTodo *todo.Todo) (todo.Todo, error) {
+	if changedTodo != nil {
+		encoded, err := json.Marshal(changedTodo)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		command.Todo = encoded
+	}
+	reply, err := c.Send(command)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	if reply.Todo == nil {
+		return todo.Todo{}, fmt.Errorf("reply to request %s without todo", reply.RequestId)
+	}
+	return *reply.Todo, nil
+}
+
+// NextEvent returns the next received event or reset message, waiting up to timeout
+func (c *Client) NextEvent(timeout time.Duration) (controllers.WebSocketMessage, error) {
+	// events received before the connection was closed are returned first
+	select {
+	case message := <-c.events:
+		return message, nil
+	default:
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case message := <-c.events:
+		return message, nil
+	case <-c.done:
+		return controllers.WebSocketMessage{}, c.Err()
+	case <-timer.C:
+		return controllers.WebSocketMessage{}, fmt.Errorf("%w: no event within %s", ErrTimeout, timeout)
+	}
+}
+
+// readMessages dispatches the received messages until the connection is closed
+func (c *Client) readMessages() {
+	var err error
+	for {
+		var message controllers.WebSocketMessage
+		err = c.conn.ReadJSON(&message)
+		if err != nil {
+			break
+		}
+		if message.Type != controllers.WebSocketReply {
+			select {
+			case c.events <- message:
+			case <-c.closed:
+			}
+			continue
+		}
+		c.mutex.Lock()
+		replies, awaited := c.pending[message.RequestId]
+		c.mutex.Unlock()
+		if awaited {
+			select {
+			case replies <- message:
+			default: // a reply to the same request id was received before
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	c.err = fmt.Errorf("%w: %w", ErrClosed, err)
+	c.mutex.Unlock()
+	close(c.done)
+}
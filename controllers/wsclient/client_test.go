@@ -0,0 +1,307 @@
+package wsclient
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo-rest-backend/controllers"
+	"todo-rest-backend/models"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/todo"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventTimeout time the tests wait for an expected event
+const eventTimeout = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	err := models.SetTodoRepository(&memrepo.MemoryTodoRepository{})
+	if err == nil {
+		err = models.Initialize()
+	}
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// dial connects a new client to an in-process server, closed when the test ends
+func dial(t *testing.T) *Client {
+	t.Helper()
+	client, closeClient, err := DialHandler(controllers.NewRouter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(closeClient)
+	return client
+}
+
+// dialWithSmallSocketBuffers connects a new client like dial, but with small socket buffers on both sides, so that
+// a client not reading is noticed by the server after a few messages
+func dialWithSmallSocketBuffers(t *testing.T) *Client {
+	t.Helper()
+	server := httptest.NewUnstartedServer(controllers.NewRouter())
+	server.Listener = smallBufferListener{server.Listener}
+	server.Start()
+	t.Cleanup(server.Close)
+	dialer := websocket.Dialer{NetDial: func(network string, address string) (net.Conn, error) {
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return conn, conn.(*net.TCPConn).SetReadBuffer(socketBufferSize)
+	}}
+	conn, _, err := dialer.Dial(webSocketUrl(server), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(conn)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// socketBufferSize size of the socket buffers of dialWithSmallSocketBuffers
+const socketBufferSize = 16 * 1024
+
+// smallBufferListener listener limiting the send buffer of the accepted connections
+type smallBufferListener struct {
+	net.Listener
+}
+
+func (l smallBufferListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return conn, conn.(*net.TCPConn).SetWriteBuffer(socketBufferSize)
+}
+
+// create creates a todo with the passed title by the passed client
+func create(t *testing.T, client *Client, title string) todo.Todo {
+	t.Helper()
+	created, err := client.Create(todo.Todo{Title: title, Description: "created by the test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// nextEvent returns the next event of the client, failing on reset messages
+func nextEvent(t *testing.T, client *Client) events.Event {
+	t.Helper()
+	message, err := client.NextEvent(eventTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Type != controllers.WebSocketEvent || message.Event == nil {
+		t.Fatalf("got %s message, want an event", message.Type)
+	}
+	return *message.Event
+}
+
+func TestSubscribeReceivesChangesOfOtherClients(t *testing.T) {
+	subscriber := dial(t)
+	changer := dial(t)
+	err := subscriber.Subscribe(events.Filter{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := create(t, changer, "subscribed")
+	created.Terminated = true
+	updated, err := changer.Update(created.Id, created, models.ETag(created))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = changer.Delete(created.Id, models.ETag(updated))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []events.Type{events.TypeCreated, events.TypeUpdated, events.TypeDeleted} {
+		event := nextEvent(t, subscriber)
+		if event.Type != want || event.Todo.Id != created.Id {
+			t.Errorf("got %s event of todo %s, want %s of %s", event.Type, event.Todo.Id, want, created.Id)
+		}
+	}
+}
+
+func TestSubscribeFiltersEvents(t *testing.T) {
+	subscriber := dial(t)
+	changer := dial(t)
+	watched := create(t, changer, "watched")
+	err := subscriber.Subscribe(events.Filter{Ids: []string{watched.Id}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create(t, changer, "unwatched")
+	watched.Title = "still watched"
+	_, err = changer.Update(watched.Id, watched, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextEvent(t, subscriber)
+	if event.Type != events.TypeUpdated || event.Todo.Id != watched.Id {
+		t.Errorf("got %s event of todo %s, want the update of %s", event.Type, event.Todo.Id, watched.Id)
+	}
+}
+
+func TestSubscribeReplaysEventsAfterLastEventId(t *testing.T) {
+	subscriber := dial(t)
+	changer := dial(t)
+	err := subscriber.Subscribe(events.Filter{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create(t, changer, "seen")
+	lastEvent := nextEvent(t, subscriber)
+	err = subscriber.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes while the subscriber was away are replayed in order
+	missed := []todo.Todo{create(t, changer, "missed 1"), create(t, changer, "missed 2")}
+	resumed := dial(t)
+	err = resumed.Subscribe(events.Filter{}, lastEvent.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range missed {
+		event := nextEvent(t, resumed)
+		if event.Type != events.TypeCreated || event.Todo.Id != want.Id {
+			t.Errorf("replayed %s event of todo %s, want the creation of %s", event.Type, event.Todo.Id, want.Id)
+		}
+	}
+	live := create(t, changer, "live")
+	if event := nextEvent(t, resumed); event.Todo.Id != live.Id {
+		t.Errorf("got event of todo %s after the replay, want %s", event.Todo.Id, live.Id)
+	}
+}
+
+func TestSubscribeWithUnknownLastEventIdResets(t *testing.T) {
+	subscriber := dial(t)
+	err := subscriber.Subscribe(events.Filter{}, "unknown-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := subscriber.NextEvent(eventTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Type != controllers.WebSocketReset {
+		t.Errorf("got %s message, want %s", message.Type, controllers.WebSocketReset)
+	}
+}
+
+func TestRepliesMatchRequestIds(t *testing.T) {
+	client := dial(t)
+	const count = 20
+
+	// the replies of concurrent commands arrive in any order and are matched by their request id
+	var wait sync.WaitGroup
+	errs := make(chan error, count)
+	for index := 0; index < count; index++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			requestId := "request-" + strconv.Itoa(index)
+			title := "concurrent " + strconv.Itoa(index)
+			reply, err := client.Send(controllers.WebSocketCommand{RequestId: requestId, Type: controllers.WebSocketCreate,
+				Todo: []byte(`{"title": "` + title + `", "description": "created by the test"}`)})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if reply.RequestId != requestId || reply.Todo == nil || reply.Todo.Title != title {
+				errs <- errors.New("reply " + reply.RequestId + " doesn't answer " + requestId)
+			}
+		}()
+	}
+	wait.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestFailureRepliesCarryTheProblem(t *testing.T) {
+	client := dial(t)
+
+	_, err := client.Update("does-not-exist", todo.Todo{Title: "missing", Description: "missing"}, "")
+	var replyError *ReplyError
+	if !errors.As(err, &replyError) {
+		t.Fatalf("got %v, want a reply error", err)
+	}
+	if replyError.Status != 404 {
+		t.Errorf("status %d, want 404", replyError.Status)
+	}
+}
+
+func TestOwnChangesAreNotEchoed(t *testing.T) {
+	client := dial(t)
+	other := dial(t)
+	err := client.Subscribe(events.Filter{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	own := create(t, client, "own")
+	_, err = client.Delete(own.Id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := create(t, other, "foreign")
+
+	event := nextEvent(t, client)
+	if event.Todo.Id != foreign.Id {
+		t.Errorf("got %s event of todo %s, want only the change %s of the other client", event.Type, event.Todo.Id,
+			foreign.Id)
+	}
+}
+
+func TestSlowConsumerIsClosedWithTryAgainLater(t *testing.T) {
+	slow := dialWithSmallSocketBuffers(t)
+	changer := dial(t)
+	err := slow.Subscribe(events.Filter{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the slow client doesn't take its events: once its queue is full it stops reading, the socket buffers and the
+	// queue of the server fill up and the server gives up on it
+	changed := create(t, changer, "changed often")
+	changed.Description = strings.Repeat("x", models.DescriptionMaxLengthDefault)
+	// rounds after the client stopped reading, the server queue and the small socket buffers take far fewer events
+	remainingRounds := 200
+	for round := 0; round < 5000 && remainingRounds > 0; round++ {
+		changed.Title = "round " + strconv.Itoa(round)
+		_, err = changer.Update(changed.Id, changed, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(slow.events) == cap(slow.events) {
+			remainingRounds--
+		}
+	}
+	// the close message arrives after the events sent before
+	for err == nil {
+		_, err = slow.NextEvent(eventTimeout)
+	}
+
+	var closeError *websocket.CloseError
+	if !errors.As(slow.Err(), &closeError) || closeError.Code != websocket.CloseTryAgainLater {
+		t.Fatalf("slow client closed by %v, want close code %d", slow.Err(), websocket.CloseTryAgainLater)
+	}
+}
@@ -0,0 +1,87 @@
+// Package audit contains the recording of the revisions of the todos: who changed a todo, when and how
+package audit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/todo"
+)
+
+// Recorder records the revisions of the changes of the observed todo repository with the origin of their context
+// (revision.NewContext)
+type Recorder struct {
+	revisions repositories.RevisionRepository
+
+	// guards the revision repository, which may be set while changes are recorded
+	mutex sync.RWMutex
+}
+
+// NewRecorder returns a recorder not recording anything until a revision repository is set
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// SetRevisionRepository sets the repository the revisions are stored in
+func (r *Recorder) SetRevisionRepository(revisionRepository repositories.RevisionRepository) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.revisions = revisionRepository
+}
+
+// TodoChanged records the revision of the change, distinguishing the moves to and from the trash from other updates
+func (r *Recorder) TodoChanged(ctx context.Context, mutation repositories.Mutation) {
+	switch {
+	case mutation.Kind == repositories.MutationCreated:
+		r.record(ctx, revision.ActionCreated, todo.Todo{}, mutation.After)
+	case mutation.Kind == repositories.MutationDeleted:
+		r.record(ctx, revision.ActionDeleted, mutation.Before, mutation.After)
+	case !mutation.Before.IsDeleted() && mutation.After.IsDeleted():
+		r.record(ctx, revision.ActionTrashed, mutation.Before, mutation.After)
+	case mutation.Before.IsDeleted() && !mutation.After.IsDeleted():
+		r.record(ctx, revision.ActionRestored, mutation.Before, mutation.After)
+	case mutation.After.Version == mutation.Before.Version:
+		// patches may leave a todo as it is, e.g. completing an already terminated subtask
+	default:
+		r.record(ctx, revision.ActionUpdated, mutation.Before, mutation.After)
+	}
+}
+
+// record stores the revision of the change from before to after. The change is already applied, so a failure
+// is logged instead of failing the mutation.
+func (r *Recorder) record(ctx context.Context, action revision.Action, before todo.Todo, after todo.Todo) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.revisions == nil {
+		return
+	}
+
+	// deletions keep the last state of the todo, there are no changed fields
+	changes := []revision.FieldChange{}
+	if action != revision.ActionDeleted {
+		var err error
+		changes, err = revision.Diff(before, after)
+		if err != nil {
+			log.Println("recording revision of todo", after.Id, "failed:", err)
+			return
+		}
+	}
+
+	origin := revision.FromContext(ctx)
+	_, err := r.revisions.CreateRevision(revision.Revision{
+		TodoId:   after.Id,
+		Actor:    origin.Actor,
+		Time:     time.Now().UTC(),
+		Action:   action,
+		Changes:  changes,
+		Todo:     after,
+		ChangeId: origin.ChangeId,
+		Session:  origin.Session,
+	})
+	if err != nil {
+		log.Println("recording revision of todo", after.Id, "failed:", err)
+	}
+}
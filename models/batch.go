@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// BatchMaxOperations maximum number of operations of a batch
+const BatchMaxOperations = 1000
+
+// Modes of a batch
+const (
+	BatchModeAtomic     = "atomic"     // all operations are applied or none
+	BatchModeBestEffort = "bestEffort" // the successful operations are applied, independent of the failed ones
+)
+
+// Operations of a batch
+const (
+	BatchOperationCreate = "create"
+	BatchOperationUpdate = "update"
+	BatchOperationDelete = "delete"
+)
+
+// ErrInvalidBatch is returned for batches which can't be applied at all, e.g. without operations
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// ErrInvalidOperation is the result of batch operations which are invalid by themselves
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// BatchRequest list of operations applied in one batch
+type BatchRequest struct {
+	Mode       string           `json:"mode"` // "atomic" (default) or "bestEffort"
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation single operation of a batch: "create" needs the todo, "update" the id and the todo
+// and "delete" the id
+type BatchOperation struct {
+	Op      string     `json:"op"`
+	Id      string     `json:"id,omitempty"`
+	IfMatch string     `json:"ifMatch,omitempty"` // precondition like the If-Match header of update and delete
+	Todo    *todo.Todo `json:"todo,omitempty"`
+}
+
+// BatchMeta meta information of the batch results
+type BatchMeta struct {
+	Mode      string `json:"mode"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// BatchOperationResult result of a batch operation, in the order of the operations
+type BatchOperationResult struct {
+	Index  int        `json:"index"`
+	Op     string     `json:"op"`
+	Status int        `json:"status"`          // http status the operation would have as single request
+	Todo   *todo.Todo `json:"todo,omitempty"`  // created, updated or deleted todo
+	Error  *ApiError  `json:"error,omitempty"` // reason of the failure
+}
+
+// ApplyBatch applies the operations in order (abstracted by repository pattern) and returns the result of every
+// operation. In atomic mode no operation is applied when one fails, the others get repositories.ErrBatchAborted.
+// References (list, parent and blockers) are validated against the todos before the batch, so they can't refer
+// to todos created in the same batch. The follow-up changes of the stored operations (detaching the references to
+// deleted todos and creating next occurrences) are logged when they fail, since the batch can't be taken back then.
+func ApplyBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]repositories.BatchResult, error) {
+	if todoRepository == nil {
+		return nil, errors.New("todo repositories must not be nil")
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("%w: no operations", ErrInvalidBatch)
+	}
+	if len(operations) > BatchMaxOperations {
+		return nil, fmt.Errorf("%w: more than %d operations", ErrInvalidBatch, BatchMaxOperations)
+	}
+	results, series, err := applyBatchValidated(ctx, operations, atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, result := range results {
+		if result.Err == nil {
+			continueSeriesAfterChange(ctx, series[index])
+		}
+	}
+	return results, nil
+}
+
+// applyBatchValidated validates the operations against the todos and applies the valid ones, holding referenceMutex
+// until the references to deleted todos are detached. It also returns the series continued by the operations
+// (see continueSeries).
+func applyBatchValidated(ctx context.Context, operations []BatchOperation, atomic bool) ([]repositories.BatchResult, []todo.Todo, error) {
+	referenceMutex.Lock()
+	defer referenceMutex.Unlock()
+	// the todos are only read when an operation refers to other todos (see requireOtherTodos)
+	var todos []todo.Todo
+	var err error
+	if slices.ContainsFunc(operations, func(operation BatchOperation) bool {
+		return operation.Op != BatchOperationDelete && operation.Todo != nil && hasReferences(*operation.Todo)
+	}) {
+		todos, err = readTodosForValidation()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	results := make([]repositories.BatchResult, len(operations))
+	series := make([]todo.Todo, len(operations))
+	var repositoryOperations []repositories.BatchOperation
+	var positions []int
+	failed := false
+	for index, operation := range operations {
+		repositoryOperation, err := prepareBatchOperation(todos, operation, &series[index])
+		if err != nil {
+			results[index].Err = err
+			failed = true
+			continue
+		}
+		repositoryOperations = append(repositoryOperations, repositoryOperation)
+		positions = append(positions, index)
+	}
+	if atomic && failed {
+		return repositories.AbortBatch(results), series, nil
+	}
+
+	if len(repositoryOperations) > 0 {
+		repositoryResults, err := todoRepository.WithContext(ctx).ApplyBatch(repositoryOperations, atomic)
+		if err != nil {
+			return nil, nil, err
+		}
+		for position, result := range repositoryResults {
+			if errors.Is(result.Err, repositories.ErrVersionMismatch) {
+				result.Err = ErrPreconditionFailed
+			}
+			results[positions[position]] = result
+		}
+	}
+
+	for index, result := range results {
+		if result.Err != nil || operations[index].Op != BatchOperationDelete {
+			continue
+		}
+		err = detachReferences(ctx, operations[index].Id)
+		if err != nil {
+			log.Println("detaching the references to todo", operations[index].Id, "deleted by batch failed:", err)
+		}
+	}
+	return results, series, nil
+}
+
+// prepareBatchOperation validates the batch operation and returns the corresponding repository operation.
+// series is set when an update terminates a recurring todo (see continueSeries).
+func prepareBatchOperation(todos []todo.Todo, operation BatchOperation, series *todo.Todo) (repositories.BatchOperation, error) {
+	if !slices.Contains([]string{BatchOperationCreate, BatchOperationUpdate, BatchOperationDelete}, operation.Op) {
+		return repositories.BatchOperation{}, fmt.Errorf("%w: unknown op %q", ErrInvalidOperation, operation.Op)
+	}
+	if operation.Op != BatchOperationCreate && operation.Id == "" {
+		return repositories.BatchOperation{}, fmt.Errorf("%w: id missing", ErrInvalidOperation)
+	}
+	if operation.Op != BatchOperationDelete && operation.Todo == nil {
+		return repositories.BatchOperation{}, fmt.Errorf("%w: todo missing", ErrInvalidOperation)
+	}
+
+	switch operation.Op {
+	case BatchOperationCreate:
+		err := ValidateTodo(*operation.Todo)
+		if err != nil {
+			return repositories.BatchOperation{}, fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+		}
+		todoToCreate, err := prepareCreation(todos, *operation.Todo)
+		if err != nil {
+			return repositories.BatchOperation{}, err
+		}
+		return repositories.BatchOperation{Kind: repositories.BatchCreate, Todo: todoToCreate}, nil
+	case BatchOperationUpdate:
+		err := ValidateTodo(*operation.Todo)
+		if err != nil {
+			return repositories.BatchOperation{}, fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+		}
+		change := updateChange(todos, operation.Id, *operation.Todo, operation.IfMatch, series)
+		return repositories.BatchOperation{Kind: repositories.BatchPatch, Id: operation.Id, Patch: change}, nil
+	}
+
+	// delete
+	if trashEnabled {
+		return repositories.BatchOperation{Kind: repositories.BatchPatch, Id: operation.Id, Patch: trashChange(operation.IfMatch)}, nil
+	}
+	todoRead, err := ReadTodoById(operation.Id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return repositories.BatchOperation{}, fmt.Errorf("%w: %s", repositories.ErrNotFound, operation.Id)
+	}
+	if err != nil {
+		return repositories.BatchOperation{}, err
+	}
+	var todoDelete todo.Todo
+	if operation.IfMatch != "" {
+		err = CheckIfMatch(operation.IfMatch, todoRead)
+		if err != nil {
+			return repositories.BatchOperation{}, err
+		}
+		// the repository deletes only when the todo wasn't changed in the meantime
+		todoDelete.Version = todoRead.Version
+	}
+	return repositories.BatchOperation{Kind: repositories.BatchDelete, Id: operation.Id, Todo: todoDelete}, nil
+}
@@ -2,8 +2,11 @@
 package configuration
 
 import (
+	"fmt"
 	"github.com/joho/godotenv"
+	"regexp"
 	"strconv"
+	"time"
 )
 
 const EnvFile = ".env"
@@ -11,8 +14,37 @@ const RepositoryModeKeyName = "REPOSITORY_MODE"
 const PortKeyName = "PORT"
 const MemoryRepository = "mem"
 const CsvFileRepository = "csv"
+const SqliteRepository = "sqlite"
+const SqliteDatabasePathKeyName = "SQLITE_DATABASE_PATH"
+const SqliteDatabasePathDefault = "data.db"
+const JournalRepository = "journal"
+const JournalPathKeyName = "JOURNAL_PATH"
+const JournalPathDefault = "journal.log"
+const JournalCompactionSizeKeyName = "JOURNAL_COMPACTION_SIZE"
+const JournalCompactionSizeDefault = 1024 * 1024
+const EventSourcedRepository = "eventsourced"
+const EventLogPathKeyName = "EVENT_LOG_PATH"
+const EventLogPathDefault = "events.log"
+const SnapshotIntervalKeyName = "SNAPSHOT_INTERVAL"
+const SnapshotRetentionKeyName = "SNAPSHOT_RETENTION"
 const RepositoryModeDefault = MemoryRepository
+const IdStrategyKeyName = "ID_STRATEGY"
+const IdStrategyDefault = "sequence"
 const PortDefault = 8080
+const TrashModeKeyName = "TRASH_MODE"
+const TrashModeDefault = false
+const TrashRetentionKeyName = "TRASH_RETENTION"
+const TrashRetentionDefault = 30 * 24 * time.Hour
+const TitleMaxLengthKeyName = "VALIDATION_TITLE_MAX_LENGTH"
+const DescriptionMaxLengthKeyName = "VALIDATION_DESCRIPTION_MAX_LENGTH"
+const TitlePatternKeyName = "VALIDATION_TITLE_PATTERN"
+const MaxBodySizeKeyName = "VALIDATION_MAX_BODY_SIZE"
+const RejectUnknownFieldsKeyName = "VALIDATION_REJECT_UNKNOWN_FIELDS"
+const RejectUnknownFieldsDefault = true
+const EventBufferSizeKeyName = "EVENT_BUFFER_SIZE"
+const WebhookMaxAttemptsKeyName = "WEBHOOK_MAX_ATTEMPTS"
+const WebhookBackoffKeyName = "WEBHOOK_BACKOFF"
+const WebhookTimeoutKeyName = "WEBHOOK_TIMEOUT"
 
 // GetRepositoryMode returns the configured repositories mode
 func GetRepositoryMode() (string, error) {
@@ -29,6 +61,247 @@ func GetRepositoryMode() (string, error) {
 	return repositoryMode, nil
 }
 
+// GetIdStrategy returns the configured id generation strategy
+func GetIdStrategy() (string, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return "", err
+	}
+
+	idStrategy := configMap[IdStrategyKeyName]
+	if idStrategy == "" {
+		idStrategy = IdStrategyDefault
+	}
+
+	return idStrategy, nil
+}
+
+// GetSqliteDatabasePath returns the configured sqlite database file path
+func GetSqliteDatabasePath() (string, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return "", err
+	}
+
+	databasePath := configMap[SqliteDatabasePathKeyName]
+	if databasePath == "" {
+		databasePath = SqliteDatabasePathDefault
+	}
+
+	return databasePath, nil
+}
+
+// GetJournalPath returns the configured journal file path
+func GetJournalPath() (string, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return "", err
+	}
+
+	journalPath := configMap[JournalPathKeyName]
+	if journalPath == "" {
+		journalPath = JournalPathDefault
+	}
+
+	return journalPath, nil
+}
+
+// GetJournalCompactionSize returns the configured journal size in bytes after which the journal gets compacted
+func GetJournalCompactionSize() (int64, error) {
+	return getPositiveInt(JournalCompactionSizeKeyName, JournalCompactionSizeDefault)
+}
+
+// GetEventLogPath returns the configured event log file path of the event-sourced repository
+func GetEventLogPath() (string, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return "", err
+	}
+
+	eventLogPath := configMap[EventLogPathKeyName]
+	if eventLogPath == "" {
+		eventLogPath = EventLogPathDefault
+	}
+
+	return eventLogPath, nil
+}
+
+// GetSnapshotInterval returns the configured number of events between two snapshots of the event-sourced repository,
+// defaultInterval when not configured
+func GetSnapshotInterval(defaultInterval int) (int, error) {
+	interval, err := getPositiveInt(SnapshotIntervalKeyName, int64(defaultInterval))
+	return int(interval), err
+}
+
+// GetSnapshotRetention returns the configured number of snapshots kept by the event-sourced repository,
+// defaultRetention when not configured
+func GetSnapshotRetention(defaultRetention int) (int, error) {
+	retention, err := getPositiveInt(SnapshotRetentionKeyName, int64(defaultRetention))
+	return int(retention), err
+}
+
+// GetTrashMode returns whether deleted todos are kept in the trash instead of being deleted immediately
+func GetTrashMode() (bool, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return false, err
+	}
+
+	trashMode := configMap[TrashModeKeyName]
+	if trashMode == "" {
+		return TrashModeDefault, nil
+	}
+
+	enabled, err := strconv.ParseBool(trashMode)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q", TrashModeKeyName, trashMode)
+	}
+
+	return enabled, nil
+}
+
+// GetTrashRetention returns the configured duration after which trashed todos are purged
+func GetTrashRetention() (time.Duration, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return 0, err
+	}
+
+	trashRetention := configMap[TrashRetentionKeyName]
+	if trashRetention == "" {
+		return TrashRetentionDefault, nil
+	}
+
+	retention, err := time.ParseDuration(trashRetention)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q", TrashRetentionKeyName, trashRetention)
+	}
+
+	return retention, nil
+}
+
+// GetTitleMaxLength returns the configured maximum number of characters of a todo title, defaultLength when not configured
+func GetTitleMaxLength(defaultLength int) (int, error) {
+	length, err := getPositiveInt(TitleMaxLengthKeyName, int64(defaultLength))
+	return int(length), err
+}
+
+// GetDescriptionMaxLength returns the configured maximum number of characters of a todo description,
+// defaultLength when not configured
+func GetDescriptionMaxLength(defaultLength int) (int, error) {
+	length, err := getPositiveInt(DescriptionMaxLengthKeyName, int64(defaultLength))
+	return int(length), err
+}
+
+// GetTitlePattern returns the configured pattern the whole title of a todo has to match, nil when not configured
+func GetTitlePattern() (*regexp.Regexp, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return nil, err
+	}
+
+	titlePattern := configMap[TitlePatternKeyName]
+	if titlePattern == "" {
+		return nil, nil
+	}
+
+	// the whole title has to match, not only a part of it
+	pattern, err := regexp.Compile("^(?:" + titlePattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q: %w", TitlePatternKeyName, titlePattern, err)
+	}
+
+	return pattern, nil
+}
+
+// GetMaxBodySize returns the configured maximum size of a request body in bytes, defaultSize when not configured
+func GetMaxBodySize(defaultSize int64) (int64, error) {
+	return getPositiveInt(MaxBodySizeKeyName, defaultSize)
+}
+
+// GetRejectUnknownFields returns whether unknown fields in request bodies are rejected
+func GetRejectUnknownFields() (bool, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return false, err
+	}
+
+	rejectUnknownFields := configMap[RejectUnknownFieldsKeyName]
+	if rejectUnknownFields == "" {
+		return RejectUnknownFieldsDefault, nil
+	}
+
+	reject, err := strconv.ParseBool(rejectUnknownFields)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q", RejectUnknownFieldsKeyName, rejectUnknownFields)
+	}
+
+	return reject, nil
+}
+
+// GetEventBufferSize returns the configured number of todo change events kept for resuming event streams,
+// defaultSize when not configured
+func GetEventBufferSize(defaultSize int) (int, error) {
+	size, err := getPositiveInt(EventBufferSizeKeyName, int64(defaultSize))
+	return int(size), err
+}
+
+// GetWebhookMaxAttempts returns the configured number of calls of a webhook per event, defaultAttempts when not configured
+func GetWebhookMaxAttempts(defaultAttempts int) (int, error) {
+	attempts, err := getPositiveInt(WebhookMaxAttemptsKeyName, int64(defaultAttempts))
+	return int(attempts), err
+}
+
+// GetWebhookBackoff returns the configured wait time after the first failed webhook call, defaultBackoff when not configured
+func GetWebhookBackoff(defaultBackoff time.Duration) (time.Duration, error) {
+	return getPositiveDuration(WebhookBackoffKeyName, defaultBackoff)
+}
+
+// GetWebhookTimeout returns the configured time a webhook call may take, defaultTimeout when not configured
+func GetWebhookTimeout(defaultTimeout time.Duration) (time.Duration, error) {
+	return getPositiveDuration(WebhookTimeoutKeyName, defaultTimeout)
+}
+
+// getPositiveInt returns the configured positive number of the passed key, defaultValue when not configured
+func getPositiveInt(keyName string, defaultValue int64) (int64, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return 0, err
+	}
+
+	configured := configMap[keyName]
+	if configured == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseInt(configured, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q", keyName, configured)
+	}
+
+	return value, nil
+}
+
+// getPositiveDuration returns the configured positive duration of the passed key (e.g. "30s"), defaultValue when not configured
+func getPositiveDuration(keyName string, defaultValue time.Duration) (time.Duration, error) {
+	configMap, err := GetConfiguration()
+	if err != nil {
+		return 0, err
+	}
+
+	configured := configMap[keyName]
+	if configured == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(configured)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q", keyName, configured)
+	}
+
+	return value, nil
+}
+
 // GetConfiguration returns a map containing the configurations
 func GetConfiguration() (map[string]string, error) {
 	return godotenv.Read(EnvFile)
@@ -0,0 +1,238 @@
+// Package delivery contains the delivery of the todo change events to the webhooks. The events of a webhook are
+// delivered one after the other in their order. Failed calls are retried with exponential backoff, deliveries
+// failing for good are stored as dead letters to be inspected and redelivered.
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/webhook"
+)
+
+// MaxAttemptsDefault default number of calls of a webhook per event before the delivery is a dead letter
+const MaxAttemptsDefault = 5
+
+// BackoffDefault default wait time after the first failed call, doubled after each further one
+const BackoffDefault = time.Second
+
+// MaxBackoff upper bound of the wait time between two calls
+const MaxBackoff = 10 * time.Minute
+
+// TimeoutDefault default time a webhook call may take
+const TimeoutDefault = 10 * time.Second
+
+// QueueSize number of events waiting for the delivery to a webhook, further events are dead letters right away
+const QueueSize = 1000
+
+// Headers of the webhook calls
+const (
+	HeaderEvent     = "X-Webhook-Event"     // type of the event
+	HeaderDelivery  = "X-Webhook-Delivery"  // id of the event, the same for all attempts and redeliveries
+	HeaderTimestamp = "X-Webhook-Timestamp" // unix seconds of the call, part of the signature
+	HeaderSignature = "X-Webhook-Signature" // see webhook.Sign
+)
+
+// responseReadLimit number of bytes of a response body read, so that the connection can be reused
+const responseReadLimit = 64 * 1024
+
+// Store keeps the webhooks and their dead letters, usually the webhook repository
+type Store interface {
+	ReadWebhookById(string) (webhook.Webhook, error)
+	CreateDeadLetter(webhook.DeadLetter) (webhook.DeadLetter, error)
+}
+
+// Dispatcher delivers events to webhooks in the background and stores the failed deliveries
+type Dispatcher struct {
+	mutex       sync.Mutex
+	store       Store
+	maxAttempts int
+	backoff     time.Duration
+	client      *http.Client
+	queues      map[string][]events.Event // waiting events by webhook id, present while its worker runs
+
+	deliveries sync.WaitGroup
+}
+
+// NewDispatcher returns a dispatcher calling a webhook up to maxAttempts times per event, waiting backoff after
+// the first failed call (doubled after each further one) and giving each call up to timeout
+func NewDispatcher(maxAttempts int, backoff time.Duration, timeout time.Duration) *Dispatcher {
+	dispatcher := &Dispatcher{queues: map[string][]events.Event{}}
+	dispatcher.SetPolicy(maxAttempts, backoff, timeout)
+	return dispatcher
+}
+
+// SetPolicy changes the retry policy and call timeout of the deliveries started from now on
+func (d *Dispatcher) SetPolicy(maxAttempts int, backoff time.Duration, timeout time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.maxAttempts = max(maxAttempts, 1)
+	d.backoff = max(backoff, 0)
+	d.client = &http.Client{Timeout: timeout}
+}
+
+// SetStore sets the store the webhooks are read from before each call and the dead letters are stored in.
+// Without store the webhooks are called as passed to Deliver and the dead letters are only logged.
+func (d *Dispatcher) SetStore(store Store) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.store = store
+}
+
+// Deliver queues the event for the delivery to the webhook in the background, after the events queued before
+func (d *Dispatcher) Deliver(hook webhook.Webhook, event events.Event) {
+	d.mutex.Lock()
+	queue, running := d.queues[hook.Id]
+	if len(queue) >= QueueSize {
+		d.mutex.Unlock()
+		d.addDeadLetter(hook, event, 0, 0, errors.New("too many events waiting for the delivery"))
+		return
+	}
+	d.queues[hook.Id] = append(queue, event)
+	d.deliveries.Add(1)
+	d.mutex.Unlock()
+
+	if !running {
+		go d.work(hook)
+	}
+}
+
+// Wait waits until the queued deliveries are done (delivered, skipped or dead letters)
+func (d *Dispatcher) Wait() {
+	d.deliveries.Wait()
+}
+
+// work delivers the queued events of the webhook until its queue is empty
+func (d *Dispatcher) work(hook webhook.Webhook) {
+	for {
+		d.mutex.Lock()
+		queue := d.queues[hook.Id]
+		if len(queue) == 0 {
+			delete(d.queues, hook.Id)
+			d.mutex.Unlock()
+			return
+		}
+		event := queue[0]
+		d.queues[hook.Id] = queue[1:]
+		d.mutex.Unlock()
+
+		d.deliver(hook, event)
+		d.deliveries.Done()
+	}
+}
+
+// deliver calls the webhook until it accepts the event, the attempts are exhausted, the failure isn't transient
+// or the webhook is deleted. Each call takes the current url and secret of the webhook.
+func (d *Dispatcher) deliver(hook webhook.Webhook, event events.Event) {
+	d.mutex.Lock()
+	maxAttempts, backoff, client := d.maxAttempts, d.backoff, d.client
+	d.mutex.Unlock()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.addDeadLetter(hook, event, 0, 0, err)
+		return
+	}
+	wait := backoff
+	for attempt := 1; ; attempt++ {
+		var deleted bool
+		hook, deleted = d.currentWebhook(hook)
+		if deleted {
+			return
+		}
+		status, err := call(client, hook, event, body)
+		if err == nil {
+			return
+		}
+		if attempt >= maxAttempts || !isTransient(status) {
+			d.addDeadLetter(hook, event, attempt, status, err)
+			return
+		}
+		time.Sleep(wait)
+		wait = min(wait*2, MaxBackoff)
+	}
+}
+
+// currentWebhook returns the stored state of the webhook, the passed one when it can't be read
+func (d *Dispatcher) currentWebhook(hook webhook.Webhook) (current webhook.Webhook, deleted bool) {
+	d.mutex.Lock()
+	store := d.store
+	d.mutex.Unlock()
+	if store == nil {
+		return hook, false
+	}
+	current, err := store.ReadWebhookById(hook.Id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return webhook.Webhook{}, true
+	}
+	if err != nil {
+		log.Println("reading webhook", hook.Id, "failed, calling it as before:", err)
+		return hook, false
+	}
+	return current, false
+}
+
+// call posts the signed event to the webhook and returns the response status (0 without response)
+func call(client *http.Client, hook webhook.Webhook, event events.Event, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set(HeaderEvent, string(event.Type))
+	request.Header.Set(HeaderDelivery, event.Id)
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	request.Header.Set(HeaderSignature, webhook.Sign(hook.Secret, now, body))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, responseReadLimit))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// isTransient checks whether a call failing with passed status (0 without response) is worth retrying
+func isTransient(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
+}
+
+// addDeadLetter stores the failed delivery as dead letter
+func (d *Dispatcher) addDeadLetter(hook webhook.Webhook, event events.Event, attempts int, status int, err error) {
+	d.mutex.Lock()
+	store := d.store
+	d.mutex.Unlock()
+
+	body, marshalErr := json.Marshal(event)
+	if store == nil || marshalErr != nil {
+		log.Println("delivery of event", event.Id, "to webhook", hook.Id, "failed for good:", errors.Join(err, marshalErr))
+		return
+	}
+	_, err = store.CreateDeadLetter(webhook.DeadLetter{
+		WebhookId:  hook.Id,
+		Url:        hook.Url,
+		Event:      body,
+		Attempts:   attempts,
+		LastStatus: status,
+		LastError:  err.Error(),
+		FailedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		log.Println("storing the dead letter of event", event.Id, "to webhook", hook.Id, "failed:", err)
+	}
+}
@@ -0,0 +1,258 @@
+package delivery
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/webhook"
+)
+
+// testBackoff wait time after the first failed call in the tests
+const testBackoff = 20 * time.Millisecond
+
+// receivedCall call of a test receiver
+type receivedCall struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+// receiver webhook receiver recording its calls and answering the n-th call (counted from 1) with respond(n)
+type receiver struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	calls   []receivedCall
+	respond func(n int) int
+}
+
+func newReceiver(t *testing.T, respond func(n int) int) *receiver {
+	t.Helper()
+	r := &receiver{respond: respond}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		r.mutex.Lock()
+		r.calls = append(r.calls, receivedCall{at: time.Now(), header: request.Header.Clone(), body: body})
+		n := len(r.calls)
+		r.mutex.Unlock()
+		writer.WriteHeader(r.respond(n))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) receivedCalls() []receivedCall {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]receivedCall(nil), r.calls...)
+}
+
+// answer returns a respond function answering all calls with the passed status
+func answer(status int) func(int) int {
+	return func(int) int { return status }
+}
+
+// newTestDispatcher returns a dispatcher storing its webhooks and dead letters in a memory repository
+func newTestDispatcher(t *testing.T, maxAttempts int) (*Dispatcher, *memrepo.MemoryWebhookRepository) {
+	t.Helper()
+	store := &memrepo.MemoryWebhookRepository{}
+	err := store.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher := NewDispatcher(maxAttempts, testBackoff, time.Second)
+	dispatcher.SetStore(store)
+	return dispatcher, store
+}
+
+func createWebhook(t *testing.T, store *memrepo.MemoryWebhookRepository, url string, secret string) webhook.Webhook {
+	t.Helper()
+	hook, err := store.CreateWebhook(webhook.Webhook{Url: url, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+func testEvent(id int) events.Event {
+	return events.Event{Id: "test-" + strconv.Itoa(id), Type: events.TypeCreated, Time: time.Now().UTC(),
+		Todo: todo.Todo{Id: strconv.Itoa(id), Title: "todo " + strconv.Itoa(id)}}
+}
+
+func deadLetters(t *testing.T, store *memrepo.MemoryWebhookRepository) []webhook.DeadLetter {
+	t.Helper()
+	stored, err := store.ReadDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	dispatcher, store := newTestDispatcher(t, 1)
+	target := newReceiver(t, answer(http.StatusNoContent))
+	hook := createWebhook(t, store, target.URL, "secret")
+	event := testEvent(1)
+
+	dispatcher.Deliver(hook, event)
+	dispatcher.Wait()
+
+	calls := target.receivedCalls()
+	if len(calls) != 1 {
+		t.Fatalf("%d calls, want 1", len(calls))
+	}
+	header := calls[0].header
+	if !webhook.VerifySignature("secret", header.Get(HeaderSignature), header.Get(HeaderTimestamp), calls[0].body) {
+		t.Errorf("signature %q doesn't verify", header.Get(HeaderSignature))
+	}
+	if webhook.VerifySignature("other secret", header.Get(HeaderSignature), header.Get(HeaderTimestamp), calls[0].body) {
+		t.Error("signature verifies with another secret")
+	}
+	if header.Get(HeaderEvent) != string(event.Type) || header.Get(HeaderDelivery) != event.Id {
+		t.Errorf("event headers %q %q, want %q %q", header.Get(HeaderEvent), header.Get(HeaderDelivery), event.Type, event.Id)
+	}
+	var received events.Event
+	err := json.Unmarshal(calls[0].body, &received)
+	if err != nil || received.Id != event.Id || received.Todo.Id != event.Todo.Id {
+		t.Errorf("received event %+v (%v), want %+v", received, err, event)
+	}
+}
+
+func TestTransientFailuresAreRetriedWithDoublingBackoff(t *testing.T) {
+	dispatcher, store := newTestDispatcher(t, 4)
+	target := newReceiver(t, answer(http.StatusServiceUnavailable))
+	hook := createWebhook(t, store, target.URL, "secret")
+
+	dispatcher.Deliver(hook, testEvent(1))
+	dispatcher.Wait()
+
+	calls := target.receivedCalls()
+	if len(calls) != 4 {
+		t.Fatalf("%d calls, want 4", len(calls))
+	}
+	wait := testBackoff
+	for index := 1; index < len(calls); index++ {
+		if gap := calls[index].at.Sub(calls[index-1].at); gap < wait {
+			t.Errorf("call %d after %s, want at least %s", index+1, gap, wait)
+		}
+		wait *= 2
+	}
+	stored := deadLetters(t, store)
+	if len(stored) != 1 || stored[0].Attempts != 4 || stored[0].LastStatus != http.StatusServiceUnavailable {
+		t.Errorf("dead letters %+v, want one after 4 attempts with status 503", stored)
+	}
+}
+
+func TestNonTransientFailureIsDeadLetterRightAway(t *testing.T) {
+	dispatcher, store := newTestDispatcher(t, 5)
+	target := newReceiver(t, answer(http.StatusBadRequest))
+	hook := createWebhook(t, store, target.URL, "secret")
+	event := testEvent(1)
+
+	dispatcher.Deliver(hook, event)
+	dispatcher.Wait()
+
+	if calls := target.receivedCalls(); len(calls) != 1 {
+		t.Errorf("%d calls, want 1", len(calls))
+	}
+	stored := deadLetters(t, store)
+	if len(stored) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(stored))
+	}
+	deadLetter := stored[0]
+	if deadLetter.WebhookId != hook.Id || deadLetter.Url != hook.Url || deadLetter.Attempts != 1 ||
+		deadLetter.LastStatus != http.StatusBadRequest {
+		t.Errorf("dead letter %+v, want one of webhook %s after 1 attempt with status 400", deadLetter, hook.Id)
+	}
+	var storedEvent events.Event
+	err := json.Unmarshal(deadLetter.Event, &storedEvent)
+	if err != nil || storedEvent.Id != event.Id {
+		t.Errorf("dead letter event %s (%v), want %s", deadLetter.Event, err, event.Id)
+	}
+}
+
+func TestEventsOfWebhookAreDeliveredInOrder(t *testing.T) {
+	dispatcher, store := newTestDispatcher(t, 3)
+	// the first event fails once, the following ones wait for its retry
+	target := newReceiver(t, func(n int) int {
+		if n == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	hook := createWebhook(t, store, target.URL, "secret")
+
+	for id := 1; id <= 5; id++ {
+		dispatcher.Deliver(hook, testEvent(id))
+	}
+	dispatcher.Wait()
+
+	calls := target.receivedCalls()
+	want := []string{"test-1", "test-1", "test-2", "test-3", "test-4", "test-5"}
+	if len(calls) != len(want) {
+		t.Fatalf("%d calls, want %d", len(calls), len(want))
+	}
+	for index, call := range calls {
+		if delivery := call.header.Get(HeaderDelivery); delivery != want[index] {
+			t.Errorf("call %d delivered %s, want %s", index+1, delivery, want[index])
+		}
+	}
+}
+
+func TestWebhookDeletedDuringRetriesIsSkipped(t *testing.T) {
+	dispatcher, store := newTestDispatcher(t, 5)
+	var hook webhook.Webhook
+	target := newReceiver(t, func(int) int {
+		_, err := store.DeleteWebhookById(hook.Id)
+		if err != nil {
+			t.Error(err)
+		}
+		return http.StatusServiceUnavailable
+	})
+	hook = createWebhook(t, store, target.URL, "secret")
+
+	dispatcher.Deliver(hook, testEvent(1))
+	dispatcher.Deliver(hook, testEvent(2))
+	dispatcher.Wait()
+
+	if calls := target.receivedCalls(); len(calls) != 1 {
+		t.Errorf("%d calls, want 1 before the webhook was deleted", len(calls))
+	}
+	if stored := deadLetters(t, store); len(stored) != 0 {
+		t.Errorf("dead letters %+v of a deleted webhook", stored)
+	}
+}
+
+func TestRetryTakesCurrentUrlAndSecret(t *testing.T) {
+	dispatcher, store := newTestDispatcher(t, 3)
+	moved := newReceiver(t, answer(http.StatusOK))
+	var hook webhook.Webhook
+	original := newReceiver(t, func(int) int {
+		_, err := store.UpdateWebhookById(hook.Id, webhook.Webhook{Url: moved.URL, Secret: "new secret"})
+		if err != nil {
+			t.Error(err)
+		}
+		return http.StatusServiceUnavailable
+	})
+	hook = createWebhook(t, store, original.URL, "old secret")
+
+	dispatcher.Deliver(hook, testEvent(1))
+	dispatcher.Wait()
+
+	calls := moved.receivedCalls()
+	if len(original.receivedCalls()) != 1 || len(calls) != 1 {
+		t.Fatalf("%d calls of the original and %d of the moved url, want 1 each", len(original.receivedCalls()),
+			len(calls))
+	}
+	header := calls[0].header
+	if !webhook.VerifySignature("new secret", header.Get(HeaderSignature), header.Get(HeaderTimestamp), calls[0].body) {
+		t.Error("retry not signed with the current secret")
+	}
+}
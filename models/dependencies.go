@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"todo-rest-backend/models/todo"
+)
+
+// OrderDependencies value of the order query parameter, ordering blockers before the todos they block
+const OrderDependencies = "dependencies"
+
+// ErrInvalidBlocker is returned when a blocker doesn't exist or the blockedBy relations would form a cycle
+var ErrInvalidBlocker = errors.New("invalid blocker")
+
+// ErrBlocked is returned when terminating a todo while one of its blockers is still open
+var ErrBlocked = errors.New("todo is blocked")
+
+// normalizeIds trims the ids and removes duplicates, keeping the order of the first occurrences
+func normalizeIds(ids []string) []string {
+	return normalizeTags(ids)
+}
+
+// validateBlockers checks against the passed todos that all blockers exist (and aren't in the trash) and
+// that the todo with passed id (empty for new todos) doesn't block itself, directly or through other todos
+func validateBlockers(todos []todo.Todo, id string, blockedBy []string) error {
+	todosById := map[string]todo.Todo{}
+	for _, currentTodo := range todos {
+		todosById[currentTodo.Id] = currentTodo
+	}
+
+	for _, blockerId := range blockedBy {
+		if blockerId == "" {
+			return fmt.Errorf("%w: blocker ids must not be blank", ErrInvalidBlocker)
+		}
+		if blockerId == id {
+			return fmt.Errorf("%w: todo %q can't block itself", ErrInvalidBlocker, id)
+		}
+		blocker, ok := todosById[blockerId]
+		if !ok || blocker.IsDeleted() {
+			return fmt.Errorf("%w: blocker %q not found", ErrInvalidBlocker, blockerId)
+		}
+	}
+	if id == "" {
+		// nothing can be blocked by a new todo yet
+		return nil
+	}
+
+	// depth first search along the blockers, reaching the todo itself means a cycle
+	visited := map[string]bool{}
+	pending := slices.Clone(blockedBy)
+	for len(pending) > 0 {
+		blockerId := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if blockerId == id {
+			return fmt.Errorf("%w: todo %q would block itself through other todos", ErrInvalidBlocker, id)
+		}
+		if visited[blockerId] {
+			continue
+		}
+		visited[blockerId] = true
+		pending = append(pending, todosById[blockerId].BlockedBy...)
+	}
+	return nil
+}
+
+// checkBlockersTerminated checks against the passed todos that all blockers (outside the trash) are terminated
+func checkBlockersTerminated(todos []todo.Todo, blockedBy []string) error {
+	var openBlockers []string
+	for _, currentTodo := range todos {
+		if slices.Contains(blockedBy, currentTodo.Id) && !currentTodo.Terminated && !currentTodo.IsDeleted() {
+			openBlockers = append(openBlockers, currentTodo.Id)
+		}
+	}
+	if len(openBlockers) > 0 {
+		slices.SortFunc(openBlockers, todo.CompareIds)
+		return fmt.Errorf("%w: it can't be terminated before its blockers %s are terminated", ErrBlocked, strings.Join(openBlockers, ", "))
+	}
+	return nil
+}
+
+// validateDependencies checks the blockers of the changed todo against the passed todos: blockers have to be valid
+// when they changed, and they have to be terminated when the todo gets terminated
+func validateDependencies(todos []todo.Todo, currentTodo todo.Todo, changedTodo todo.Todo) error {
+	if !slices.Equal(currentTodo.BlockedBy, changedTodo.BlockedBy) {
+		err := validateBlockers(todos, currentTodo.Id, changedTodo.BlockedBy)
+		if err != nil {
+			return err
+		}
+	}
+	if changedTodo.Terminated && !currentTodo.Terminated {
+		return checkBlockersTerminated(todos, changedTodo.BlockedBy)
+	}
+	return nil
+}
+
+// ReadBlockers returns the blockers (not in the trash) of the todo with passed id sorted by id
+func ReadBlockers(id string) ([]todo.Todo, error) {
+	todoRead, err := ReadTodoById(id)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := ReadTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	blockers := []todo.Todo{}
+	for _, currentTodo := range todos {
+		if slices.Contains(todoRead.BlockedBy, currentTodo.Id) {
+			blockers = append(blockers, currentTodo)
+		}
+	}
+	return SortTodosAfterIdAscending(blockers), nil
+}
+
+// sortByDependencies orders the todos topologically, so that blockers come before the todos they block.
+// Otherwise the passed order is kept. Blockers not contained in the passed todos are ignored.
+func sortByDependencies(todos []todo.Todo) []todo.Todo {
+	positions := map[string]int{}
+	for position, currentTodo := range todos {
+		positions[currentTodo.Id] = position
+	}
+
+	openBlockerCounts := make([]int, len(todos))
+	blocked := make([][]int, len(todos))
+	for position, currentTodo := range todos {
+		for _, blockerId := range currentTodo.BlockedBy {
+			blockerPosition, ok := positions[blockerId]
+			if !ok {
+				continue
+			}
+			openBlockerCounts[position]++
+			blocked[blockerPosition] = append(blocked[blockerPosition], position)
+		}
+	}
+
+	// Kahn's algorithm, always taking the ready todo which comes first in the passed order
+	var ready []int
+	for position, count := range openBlockerCounts {
+		if count == 0 {
+			ready = append(ready, position)
+		}
+	}
+	sortedTodos := make([]todo.Todo, 0, len(todos))
+	done := make([]bool, len(todos))
+	for len(ready) > 0 {
+		slices.Sort(ready)
+		position := ready[0]
+		ready = ready[1:]
+		sortedTodos = append(sortedTodos, todos[position])
+		done[position] = true
+		for _, blockedPosition := range blocked[position] {
+			openBlockerCounts[blockedPosition]--
+			if openBlockerCounts[blockedPosition] == 0 {
+				ready = append(ready, blockedPosition)
+			}
+		}
+	}
+	// todos on a cycle (only possible with data written by other means) keep the passed order
+	for position, currentTodo := range todos {
+		if !done[position] {
+			sortedTodos = append(sortedTodos, currentTodo)
+		}
+	}
+	return sortedTodos
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/todo"
+)
+
+// createTodo creates a todo with passed title, parent and blockers
+func createTodo(t *testing.T, title string, parentId string, blockedBy ...string) todo.Todo {
+	t.Helper()
+	created, err := CreateTodo(context.Background(), todo.Todo{Title: title, Description: "referring",
+		ParentId: parentId, BlockedBy: blockedBy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// changeFunc changes the todo with passed id to the passed todo through one of the ways to change a todo
+type changeFunc func(id string, todoChanged todo.Todo) error
+
+// changeFuncs returns the ways to change a todo: put, merge patch and batch update
+func changeFuncs() map[string]changeFunc {
+	ctx := context.Background()
+	return map[string]changeFunc{
+		"put": func(id string, todoChanged todo.Todo) error {
+			_, err := UpdateTodoById(ctx, id, todoChanged, "")
+			return err
+		},
+		"patch": func(id string, todoChanged todo.Todo) error {
+			patchDocument, err := json.Marshal(map[string]any{"parentId": todoChanged.ParentId,
+				"blockedBy": todoChanged.BlockedBy, "terminated": todoChanged.Terminated})
+			if err != nil {
+				return err
+			}
+			_, err = PatchTodoById(ctx, id, MergePatchContentType, patchDocument, "")
+			return err
+		},
+		"batch": func(id string, todoChanged todo.Todo) error {
+			results, err := ApplyBatch(ctx, []BatchOperation{{Op: BatchOperationUpdate, Id: id, Todo: &todoChanged}}, true)
+			if err != nil {
+				return err
+			}
+			return results[0].Err
+		},
+	}
+}
+
+func TestInvalidBlockersAreRefused(t *testing.T) {
+	for name, change := range changeFuncs() {
+		setUpRevisions(t)
+		first := createTodo(t, "first", "")
+		second := createTodo(t, "second", "", first.Id)
+		third := createTodo(t, "third", "", second.Id)
+		trashed := createTodo(t, "trashed", "")
+		_, err := DeleteTodoById(context.Background(), trashed.Id, todo.Todo{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			reason    string
+			blockedBy []string
+		}{
+			{"itself", []string{first.Id}},
+			{"a cycle through other todos", []string{third.Id}},
+			{"an unknown todo", []string{"99"}},
+			{"a todo in the trash", []string{trashed.Id}},
+			{"a blank id", []string{" "}},
+		}
+		for _, test := range tests {
+			first.BlockedBy = test.blockedBy
+			err = change(first.Id, first)
+			if !errors.Is(err, ErrInvalidBlocker) {
+				t.Errorf("%s: got %v for blockers %v (%s), want an invalid blocker", name, err, test.blockedBy, test.reason)
+			}
+		}
+		if blockedBy := readTodo(t, first.Id).BlockedBy; len(blockedBy) != 0 {
+			t.Errorf("%s: first todo blocked by %v, want unchanged", name, blockedBy)
+		}
+	}
+}
+
+func TestTerminatingWithOpenBlockerIsRefused(t *testing.T) {
+	for name, change := range changeFuncs() {
+		setUpRevisions(t)
+		blocker := createTodo(t, "blocker", "")
+		blocked := createTodo(t, "blocked", "", blocker.Id)
+
+		blocked.Terminated = true
+		err := change(blocked.Id, blocked)
+		if !errors.Is(err, ErrBlocked) {
+			t.Errorf("%s: got %v terminating a blocked todo, want blocked", name, err)
+		}
+		if readTodo(t, blocked.Id).Terminated {
+			t.Errorf("%s: blocked todo terminated", name)
+		}
+
+		blocker.Terminated = true
+		err = change(blocker.Id, blocker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = change(blocked.Id, blocked)
+		if err != nil {
+			t.Errorf("%s: got %v terminating after the blocker, want it terminated", name, err)
+		}
+	}
+}
+
+// countingRepository memory repository counting the reads of all todos
+type countingRepository struct {
+	memrepo.MemoryTodoRepository
+	reads int
+}
+
+func (r *countingRepository) ReadTodos() ([]todo.Todo, error) {
+	r.reads++
+	return r.MemoryTodoRepository.ReadTodos()
+}
+
+func TestChangesLeavingReferencesAloneDontReadAllTodos(t *testing.T) {
+	setUpRevisions(t)
+	repository := &countingRepository{}
+	err := SetTodoRepository(repository)
+	if err == nil {
+		err = Initialize()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := createTodo(t, "parent", "")
+	child := createTodo(t, "child", "")
+	repository.reads = 0
+
+	child.Title = "renamed"
+	for name, change := range changeFuncs() {
+		err = change(child.Id, child)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if repository.reads != 0 {
+		t.Errorf("%d reads of all todos for changes without references, want none", repository.reads)
+	}
+
+	child.ParentId = parent.Id
+	err = changeFuncs()["put"](child.Id, child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.reads != 1 {
+		t.Errorf("%d reads of all todos for a new parent, want 1", repository.reads)
+	}
+}
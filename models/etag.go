@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"todo-rest-backend/models/todo"
+)
+
+// ErrPreconditionFailed is returned when an If-Match precondition doesn't match the current todo version
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ETag returns the strong entity tag of the passed todo, derived from its version
+func ETag(todoTagged todo.Todo) string {
+	return `"` + strconv.FormatInt(todoTagged.Version, 10) + `"`
+}
+
+// CheckIfMatch checks the passed If-Match header value against the todo using the strong comparison (RFC 9110).
+// An empty value is no precondition.
+func CheckIfMatch(ifMatch string, currentTodo todo.Todo) error {
+	if ifMatch == "" {
+		return nil
+	}
+	if !matchesETag(ifMatch, currentTodo, false) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// MatchesIfNoneMatch checks whether the passed If-None-Match header value matches the todo using the weak comparison (RFC 9110)
+func MatchesIfNoneMatch(ifNoneMatch string, currentTodo todo.Todo) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	return matchesETag(ifNoneMatch, currentTodo, true)
+}
+
+// matchesETag checks whether one of the entity tags of the comma separated list (or "*") matches the todo
+func matchesETag(entityTags string, currentTodo todo.Todo, weakComparison bool) bool {
+	currentETag := ETag(currentTodo)
+	for _, entityTag := range strings.Split(entityTags, ",") {
+		entityTag = strings.TrimSpace(entityTag)
+		if entityTag == "*" {
+			return true
+		}
+		if strings.HasPrefix(entityTag, "W/") {
+			if !weakComparison {
+				continue
+			}
+			entityTag = strings.TrimPrefix(entityTag, "W/")
+		}
+		if entityTag == currentETag {
+			return true
+		}
+	}
+	return false
+}
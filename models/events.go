@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"todo-rest-backend/models/events"
+)
+
+// eventBroker broker of the change events of the todos, fed by the decorated todo repository
+var eventBroker = events.NewBroker(events.BufferSizeDefault)
+
+// SetEventBufferSize sets the number of change events kept for resuming subscriptions
+func SetEventBufferSize(bufferSize int) error {
+	if bufferSize <= 0 {
+		return errors.New("event buffer size must be positive")
+	}
+	eventBroker.SetBufferSize(bufferSize)
+	return nil
+}
+
+// SubscribeTodoEvents returns a subscription of the change events of the todos. When lastEventId is passed,
+// the buffered events after it are replayed. The subscription has to be closed by the caller.
+func SubscribeTodoEvents(lastEventId string) *events.Subscription {
+	return eventBroker.Subscribe(lastEventId)
+}
@@ -0,0 +1,155 @@
+// Package events contains the change events of the todos, kept in a bounded buffer and fanned out to subscribers
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// BufferSizeDefault default number of events kept for resuming subscriptions
+const BufferSizeDefault = 1000
+
+// subscriberQueueSize number of events queued for a subscriber before it is dropped as too slow
+const subscriberQueueSize = 256
+
+// Type of a change event
+type Type string
+
+// Types of change events
+const (
+	TypeCreated Type = "created"
+	TypeUpdated Type = "updated"
+	TypeDeleted Type = "deleted" // deleted or moved to the trash
+)
+
+// Event change of a todo with json tags
+type Event struct {
+	Id   string    `json:"id"` // unique within the broker, see Broker.Subscribe
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	Todo todo.Todo `json:"todo"` // todo after the change, for deletions the deleted todo
+}
+
+// Subscription of the events published after subscribing
+type Subscription struct {
+	Events <-chan Event // closed when the subscription is closed or the subscriber was too slow
+	Replay []Event      // buffered events after the last event id passed on subscribing
+	Reset  bool         // set when the last event id isn't buffered anymore, so events may have been missed
+	LastId string       // id of the last event published before subscribing, to resume from after a reset
+
+	broker *Broker
+	queue  chan Event
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker buffers the published events and passes them to the subscribers
+type Broker struct {
+	mutex       sync.Mutex
+	epoch       string // distinguishes the event ids of different broker instances, e.g. after a restart
+	sequence    uint64
+	buffer      []Event // oldest first
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBroker returns a broker keeping the last bufferSize events
+func NewBroker(bufferSize int) *Broker {
+	return &Broker{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		bufferSize:  max(bufferSize, 1),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// SetBufferSize changes the number of buffered events, dropping the oldest ones when it shrinks
+func (b *Broker) SetBufferSize(bufferSize int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.bufferSize = max(bufferSize, 1)
+	b.trimBuffer()
+}
+
+// Publish buffers an event of the passed type and todo and passes it to the subscribers.
+// Subscribers not keeping up are dropped, they can resume by the id of their last event.
+func (b *Broker) Publish(eventType Type, changedTodo todo.Todo) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sequence++
+	event := Event{
+		Id:   b.eventId(b.sequence),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Todo: changedTodo,
+	}
+	b.buffer = append(b.buffer, event)
+	b.trimBuffer()
+
+	for subscription := range b.subscribers {
+		select {
+		case subscription.queue <- event:
+		default:
+			delete(b.subscribers, subscription)
+			close(subscription.queue)
+		}
+	}
+}
+
+// Subscribe returns a subscription of the events published from now on. When lastEventId is passed,
+// the buffered events after it are replayed; Reset is set when it isn't buffered (anymore).
+func (b *Broker) Subscribe(lastEventId string) *Subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	queue := make(chan Event, subscriberQueueSize)
+	subscription := &Subscription{Events: queue, broker: b, queue: queue}
+	subscription.LastId = b.eventId(b.sequence)
+	if lastEventId != "" {
+		subscription.Replay, subscription.Reset = b.eventsAfter(lastEventId)
+	}
+	b.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+func (b *Broker) unsubscribe(subscription *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	_, subscribed := b.subscribers[subscription]
+	if subscribed {
+		delete(b.subscribers, subscription)
+		close(subscription.queue)
+	}
+}
+
+// eventsAfter returns the buffered events after the event with passed id, reset is set when the id is unknown
+func (b *Broker) eventsAfter(lastEventId string) (events []Event, reset bool) {
+	epoch, sequenceText, found := strings.Cut(lastEventId, "-")
+	sequence, err := strconv.ParseUint(sequenceText, 10, 64)
+	if !found || err != nil || epoch != b.epoch || sequence > b.sequence {
+		return nil, true
+	}
+	// the event following the last one has to be buffered, otherwise events were dropped
+	oldestSequence := b.sequence - uint64(len(b.buffer)) + 1
+	if sequence+1 < oldestSequence {
+		return nil, true
+	}
+	skipped := sequence + 1 - oldestSequence
+	return append([]Event(nil), b.buffer[skipped:]...), false
+}
+
+// eventId returns the id of the event with passed sequence number
+func (b *Broker) eventId(sequence uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(sequence, 10)
+}
+
+func (b *Broker) trimBuffer() {
+	if len(b.buffer) > b.bufferSize {
+		// appending reallocates the buffer from time to time, releasing the dropped events
+		b.buffer = b.buffer[len(b.buffer)-b.bufferSize:]
+	}
+}
@@ -0,0 +1,20 @@
+package events
+
+import "slices"
+
+// Filter selects the events of a subscriber, the zero value selects all events
+type Filter struct {
+	Ids        []string // only events of these todos, all todos when empty
+	Terminated *bool    // only events of todos with this terminated state (after the change)
+}
+
+// Matches checks whether the event is selected by the filter
+func (f Filter) Matches(event Event) bool {
+	if len(f.Ids) > 0 && !slices.Contains(f.Ids, event.Todo.Id) {
+		return false
+	}
+	if f.Terminated != nil && event.Todo.Terminated != *f.Terminated {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package events
+
+import (
+	"context"
+	"todo-rest-backend/models/repositories"
+)
+
+// TodoChanged publishes the changes of the observed todo repository. Moving a todo to the trash is published as
+// deletion and restoring it as creation, changes within the trash and purging a todo from it aren't published.
+func (b *Broker) TodoChanged(_ context.Context, mutation repositories.Mutation) {
+	switch {
+	case mutation.Kind == repositories.MutationCreated:
+		b.Publish(TypeCreated, mutation.After)
+	case mutation.Kind == repositories.MutationDeleted:
+		// the move of a purged todo to the trash was published already
+		if !mutation.After.IsDeleted() {
+			b.Publish(TypeDeleted, mutation.After)
+		}
+	case !mutation.Before.IsDeleted() && mutation.After.IsDeleted():
+		b.Publish(TypeDeleted, mutation.After)
+	case mutation.Before.IsDeleted() && !mutation.After.IsDeleted():
+		b.Publish(TypeCreated, mutation.After)
+	case !mutation.After.IsDeleted():
+		b.Publish(TypeUpdated, mutation.After)
+	}
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// ErrInvalidParent is returned when the parent of a todo doesn't exist or would make the todo its own ancestor
+var ErrInvalidParent = errors.New("invalid parent")
+
+// TodoNode todo with its subtasks, used for the nested output of the todo list
+type TodoNode struct {
+	todo.Todo
+	Children []TodoNode `json:"children"`
+}
+
+// validateParent checks against the passed todos that the parent exists (and isn't in the trash) and that
+// it isn't the todo with passed id (empty for new todos) or one of its descendants
+func validateParent(todos []todo.Todo, id string, parentId string) error {
+	if parentId == "" {
+		return nil
+	}
+
+	todosById := map[string]todo.Todo{}
+	for _, currentTodo := range todos {
+		todosById[currentTodo.Id] = currentTodo
+	}
+
+	// walk up from the new parent, reaching the todo itself means a cycle
+	visited := map[string]bool{}
+	for ancestorId := parentId; ancestorId != ""; {
+		if ancestorId == id {
+			return fmt.Errorf("%w: todo %q can't be a subtask of itself or of one of its subtasks", ErrInvalidParent, id)
+		}
+		ancestor, ok := todosById[ancestorId]
+		if !ok || ancestor.IsDeleted() {
+			if ancestorId == parentId {
+				return fmt.Errorf("%w: parent %q not found", ErrInvalidParent, parentId)
+			}
+			break
+		}
+		if visited[ancestorId] {
+			break
+		}
+		visited[ancestorId] = true
+		ancestorId = ancestor.ParentId
+	}
+	return nil
+}
+
+// ReadChildren returns the subtasks (not in the trash) of the todo with passed id sorted by id
+func ReadChildren(id string) ([]todo.Todo, error) {
+	_, err := ReadTodoById(id)
+	if err != nil {
+		return nil, err
+	}
+	children, err := readTodosFiltered(repositories.TodoFilter{ParentId: id})
+	if err != nil {
+		return nil, err
+	}
+	if children == nil {
+		children = []todo.Todo{}
+	}
+	return SortTodosAfterIdAscending(children), nil
+}
+
+// ReadTodoTree returns the todo's matching the filter of the query nested below their parents.
+// Todos whose parent doesn't match the filter are returned at the top level.
+func ReadTodoTree(query TodoQuery) ([]TodoNode, error) {
+	todos, err := ReadTodosByQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := map[string]bool{}
+	for _, currentTodo := range todos {
+		matching[currentTodo.Id] = true
+	}
+	// children keep the order of the query
+	childrenByParent := map[string][]todo.Todo{}
+	var roots []todo.Todo
+	for _, currentTodo := range todos {
+		if currentTodo.ParentId != "" && matching[currentTodo.ParentId] {
+			childrenByParent[currentTodo.ParentId] = append(childrenByParent[currentTodo.ParentId], currentTodo)
+			continue
+		}
+		roots = append(roots, currentTodo)
+	}
+
+	var buildNodes func([]todo.Todo) []TodoNode
+	buildNodes = func(level []todo.Todo) []TodoNode {
+		nodes := []TodoNode{}
+		for _, currentTodo := range level {
+			nodes = append(nodes, TodoNode{Todo: currentTodo, Children: buildNodes(childrenByParent[currentTodo.Id])})
+		}
+		return nodes
+	}
+	return buildNodes(roots), nil
+}
+
+// CompleteDescendants terminates all subtasks (recursively) of the todo with passed id and returns their count.
+// Subtasks with open blockers stay open.
+func CompleteDescendants(ctx context.Context, id string) (int, error) {
+	if todoRepository == nil {
+		return 0, errors.New("todo repositories must not be nil")
+	}
+	todos, err := readTodosFiltered(repositories.TodoFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	todosById := map[string]todo.Todo{}
+	childrenByParent := map[string][]string{}
+	for _, currentTodo := range todos {
+		todosById[currentTodo.Id] = currentTodo
+		if currentTodo.ParentId != "" {
+			childrenByParent[currentTodo.ParentId] = append(childrenByParent[currentTodo.ParentId], currentTodo.Id)
+		}
+	}
+
+	completedCount := 0
+	visited := map[string]bool{id: true}
+	pending := childrenByParent[id]
+	for len(pending) > 0 {
+		childId := pending[0]
+		pending = pending[1:]
+		if visited[childId] {
+			continue
+		}
+		visited[childId] = true
+		pending = append(pending, childrenByParent[childId]...)
+
+		blocked := slices.ContainsFunc(todosById[childId].BlockedBy, func(blockerId string) bool {
+			blocker, ok := todosById[blockerId]
+			return ok && !blocker.Terminated
+		})
+		if blocked {
+			continue
+		}
+		completed := false
+		_, err = todoRepository.WithContext(ctx).PatchTodoById(childId, func(currentTodo todo.Todo) (todo.Todo, error) {
+			if currentTodo.Terminated || currentTodo.IsDeleted() {
+				return currentTodo, nil
+			}
+			completed = true
+			todoCompleted := currentTodo
+			todoCompleted.Terminated = true
+			return applyChange(currentTodo, todoCompleted), nil
+		})
+		if err != nil {
+			return completedCount, err
+		}
+		if completed {
+			completedCount++
+			// subtasks blocked by this one can be completed now as well
+			child := todosById[childId]
+			child.Terminated = true
+			todosById[childId] = child
+		}
+	}
+	return completedCount, nil
+}
+
+// CompleteDescendantsAfterChange completes the subtasks (see CompleteDescendants) after a stored change terminated the
+// todo with passed id. The change can't be taken back anymore, so a failure is logged instead of failing it.
+func CompleteDescendantsAfterChange(ctx context.Context, id string) {
+	_, err := CompleteDescendants(ctx, id)
+	if err != nil {
+		log.Println("completing the subtasks of todo", id, "failed:", err)
+	}
+}
+
+// detachReferences removes the references to the deleted todo with passed id: its subtasks are moved to the
+// top level and it is removed from the blockers of other todos
+func detachReferences(ctx context.Context, id string) error {
+	todos, err := todoRepository.ReadTodos()
+	if err != nil {
+		return err
+	}
+	for _, currentTodo := range todos {
+		if currentTodo.ParentId != id && !slices.Contains(currentTodo.BlockedBy, id) {
+			continue
+		}
+		_, err = todoRepository.WithContext(ctx).PatchTodoById(currentTodo.Id, func(storedTodo todo.Todo) (todo.Todo, error) {
+			todoDetached := storedTodo
+			if todoDetached.ParentId == id {
+				todoDetached.ParentId = ""
+			}
+			todoDetached.BlockedBy = slices.DeleteFunc(slices.Clone(storedTodo.BlockedBy), func(blockerId string) bool {
+				return blockerId == id
+			})
+			return applyChange(storedTodo, todoDetached), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
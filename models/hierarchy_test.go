@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todo-rest-backend/models/todo"
+)
+
+func TestInvalidParentsAreRefused(t *testing.T) {
+	for name, change := range changeFuncs() {
+		setUpRevisions(t)
+		top := createTodo(t, "top", "")
+		middle := createTodo(t, "middle", top.Id)
+		bottom := createTodo(t, "bottom", middle.Id)
+		trashed := createTodo(t, "trashed", "")
+		_, err := DeleteTodoById(context.Background(), trashed.Id, todo.Todo{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			reason   string
+			parentId string
+		}{
+			{"itself", top.Id},
+			{"its subtask", middle.Id},
+			{"a subtask of its subtask", bottom.Id},
+			{"an unknown todo", "99"},
+			{"a todo in the trash", trashed.Id},
+		}
+		for _, test := range tests {
+			top.ParentId = test.parentId
+			err = change(top.Id, top)
+			if !errors.Is(err, ErrInvalidParent) {
+				t.Errorf("%s: got %v for parent %s (%s), want an invalid parent", name, err, test.parentId, test.reason)
+			}
+		}
+		if parentId := readTodo(t, top.Id).ParentId; parentId != "" {
+			t.Errorf("%s: top todo has parent %s, want none", name, parentId)
+		}
+	}
+}
+
+func TestCreatingWithInvalidReferencesIsRefused(t *testing.T) {
+	setUpRevisions(t)
+	ctx := context.Background()
+	open := createTodo(t, "open", "")
+
+	_, err := CreateTodo(ctx, todo.Todo{Title: "child", Description: "of nothing", ParentId: "99"})
+	if !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("got %v for an unknown parent, want an invalid parent", err)
+	}
+	_, err = CreateTodo(ctx, todo.Todo{Title: "blocked", Description: "by nothing", BlockedBy: []string{"99"}})
+	if !errors.Is(err, ErrInvalidBlocker) {
+		t.Errorf("got %v for an unknown blocker, want an invalid blocker", err)
+	}
+	results, err := ApplyBatch(ctx, []BatchOperation{{Op: BatchOperationCreate, Todo: &todo.Todo{Title: "done",
+		Description: "too early", Terminated: true, BlockedBy: []string{open.Id}}}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(results[0].Err, ErrBlocked) {
+		t.Errorf("got %v creating a todo terminated before its blocker, want blocked", results[0].Err)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"errors"
+	"todo-rest-backend/models/repositories"
+)
+
+// ReadTodoHistory returns the changes of the todo with passed id, oldest first, including the ones in the trash and
+// of deleted todos, from repository when it keeps the history (abstracted by repository pattern)
+func ReadTodoHistory(id string) ([]repositories.TodoChange, error) {
+	if todoRepository == nil {
+		return nil, errors.New("todo repositories must not be nil")
+	}
+	return todoRepository.ReadTodoHistory(id)
+}
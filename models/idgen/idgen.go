@@ -0,0 +1,114 @@
+// Package idgen contains the id generation strategies used by the todo repositories
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// Supported id generation strategies
+const (
+	SequenceStrategy = "sequence"
+	UuidV4Strategy   = "uuidv4"
+	UuidV7Strategy   = "uuidv7"
+	UlidStrategy     = "ulid"
+)
+
+// Generator interface for id generation strategies
+type Generator interface {
+	// NewId returns a new unique id. nextSequence returns the next value of the monotonic sequence
+	// kept in the storage of the calling repository; it is only called by strategies based on it.
+	NewId(nextSequence func() (uint64, error)) (string, error)
+}
+
+// New returns the generator for the passed strategy
+func New(strategy string) (Generator, error) {
+	switch strategy {
+	case SequenceStrategy:
+		return SequenceGenerator{}, nil
+	case UuidV4Strategy:
+		return UuidV4Generator{}, nil
+	case UuidV7Strategy:
+		return UuidV7Generator{}, nil
+	case UlidStrategy:
+		return NewUlidGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy %q", strategy)
+	}
+}
+
+// OrDefault returns the passed generator or the sequence generator when nil
+func OrDefault(generator Generator) Generator {
+	if generator == nil {
+		return SequenceGenerator{}
+	}
+	return generator
+}
+
+// SequenceGenerator generates numeric ids from the storage sequence
+type SequenceGenerator struct {
+}
+
+// NewId returns the next sequence value as id
+func (g SequenceGenerator) NewId(nextSequence func() (uint64, error)) (string, error) {
+	sequence, err := nextSequence()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(sequence, 10), nil
+}
+
+// UuidV4Generator generates random UUIDs (RFC 9562 version 4)
+type UuidV4Generator struct {
+}
+
+// NewId returns a new random UUID
+func (g UuidV4Generator) NewId(_ func() (uint64, error)) (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// UuidV7Generator generates time-ordered UUIDs (RFC 9562 version 7)
+type UuidV7Generator struct {
+}
+
+// NewId returns a new time-ordered UUID
+func (g UuidV7Generator) NewId(_ func() (uint64, error)) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// UlidGenerator generates ULIDs, monotonically increasing within the same millisecond
+type UlidGenerator struct {
+	mutex   *sync.Mutex
+	entropy *ulid.MonotonicEntropy
+}
+
+// NewUlidGenerator returns a new ULID generator
+func NewUlidGenerator() UlidGenerator {
+	return UlidGenerator{mutex: &sync.Mutex{}, entropy: ulid.Monotonic(rand.Reader, 0)}
+}
+
+// NewId returns a new ULID
+func (g UlidGenerator) NewId(_ func() (uint64, error)) (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	id, err := ulid.New(ulid.Timestamp(time.Now()), g.entropy)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
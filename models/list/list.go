@@ -0,0 +1,22 @@
+// Package list contains the todo list model parts
+package list
+
+import (
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// List type definition with json tags. A list groups todos, e.g. of a project.
+type List struct {
+	Id          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"` // Set by the models layer
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"` // Set by the models layer
+}
+
+// Serialize serializes the passed list into a slice form
+func (l List) Serialize() []string {
+	listSerialized := []string{l.Id, l.Name, l.Description, todo.FormatTime(l.CreatedAt), todo.FormatTime(l.UpdatedAt)}
+	return listSerialized
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"todo-rest-backend/models/list"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// ErrListNotEmpty is returned when deleting a list still containing todos without cascading
+var ErrListNotEmpty = errors.New("list contains todos")
+
+// ErrUnknownList is returned when a todo refers to a list which doesn't exist
+var ErrUnknownList = errors.New("unknown list")
+
+var listRepository repositories.ListRepository
+
+// SetListRepository allows to set the list repositories type
+func SetListRepository(listRepositoryNew repositories.ListRepository) error {
+	if listRepositoryNew == nil {
+		return errors.New("list repositories must not be nil")
+	}
+	listRepository = listRepositoryNew
+	return nil
+}
+
+// InitializeLists initializes the list repository (abstracted by repository pattern)
+func InitializeLists() error {
+	if listRepository == nil {
+		return errors.New("list repositories must not be nil")
+	}
+	return listRepository.Initialize()
+}
+
+// ValidateList checks the passed list against the business rules
+func ValidateList(listToValidate list.List) error {
+	if strings.TrimSpace(listToValidate.Name) == "" {
+		return repositories.NewValidationError([]repositories.FieldError{{Field: "name", Message: "required"}})
+	}
+	return nil
+}
+
+// DecodeList decodes the passed request body into a list and validates it, reporting the violations of both steps at once
+func DecodeList(body io.Reader) (list.List, error) {
+	var decodedList list.List
+	err := DecodeBody(body, &decodedList)
+	if err != nil && !errors.Is(err, repositories.ErrValidation) {
+		return list.List{}, err
+	}
+	err = joinValidationErrors(err, ValidateList(decodedList))
+	if err != nil {
+		return list.List{}, err
+	}
+	return decodedList, nil
+}
+
+// ReadLists returns the lists sorted by id from repository (abstracted by repository pattern)
+func ReadLists() ([]list.List, error) {
+	if listRepository == nil {
+		return nil, errors.New("list repositories must not be nil")
+	}
+	lists, err := listRepository.ReadLists()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(lists, func(left list.List, right list.List) int {
+		return todo.CompareIds(left.Id, right.Id)
+	})
+	return lists, nil
+}
+
+// ReadListById returns list with passed id when existing from repository (abstracted by repository pattern)
+func ReadListById(id string) (list.List, error) {
+	if listRepository == nil {
+		return list.List{}, errors.New("list repositories must not be nil")
+	}
+	return listRepository.ReadListById(id)
+}
+
+// CreateList stores the passed list in the repository and returns the stored list (abstracted by repository pattern)
+func CreateList(listToCreate list.List) (list.List, error) {
+	if listRepository == nil {
+		return list.List{}, errors.New("list repositories must not be nil")
+	}
+	now := time.Now().UTC()
+	listToCreate.CreatedAt = &now
+	listToCreate.UpdatedAt = &now
+	return listRepository.CreateList(listToCreate)
+}
+
+// UpdateListById returns updated list from repository (abstracted by repository pattern)
+func UpdateListById(id string, listUpdate list.List) (list.List, error) {
+	if listRepository == nil {
+		return list.List{}, errors.New("list repositories must not be nil")
+	}
+	currentList, err := listRepository.ReadListById(id)
+	if err != nil {
+		return list.List{}, err
+	}
+	now := time.Now().UTC()
+	listUpdate.CreatedAt = currentList.CreatedAt
+	listUpdate.UpdatedAt = &now
+	return listRepository.UpdateListById(id, listUpdate)
+}
+
+// DeleteListById deletes the list from repository (abstracted by repository pattern).
+// A list containing todos is only deleted when cascade is set, its todos are deleted then as well
+// (moved to the trash in trash mode).
+func DeleteListById(ctx context.Context, id string, cascade bool) (list.List, error) {
+	if listRepository == nil {
+		return list.List{}, errors.New("list repositories must not be nil")
+	}
+	_, err := listRepository.ReadListById(id)
+	if err != nil {
+		return list.List{}, err
+	}
+
+	listTodos, err := readTodosFiltered(repositories.TodoFilter{ListId: id})
+	if err != nil {
+		return list.List{}, err
+	}
+	if len(listTodos) > 0 && !cascade {
+		return list.List{}, fmt.Errorf("%w: %d todos, delete them first or pass cascade=true", ErrListNotEmpty, len(listTodos))
+	}
+	for _, listTodo := range listTodos {
+		_, err = DeleteTodoById(ctx, listTodo.Id, todo.Todo{}, "")
+		if err != nil {
+			return list.List{}, err
+		}
+	}
+
+	return listRepository.DeleteListById(id)
+}
+
+// validateListReference checks that the passed list id is empty or refers to an existing list
+func validateListReference(listId string) error {
+	if listId == "" {
+		return nil
+	}
+	if listRepository == nil {
+		return fmt.Errorf("%w %q", ErrUnknownList, listId)
+	}
+	_, err := listRepository.ReadListById(listId)
+	if err != nil {
+		return fmt.Errorf("%w %q", ErrUnknownList, listId)
+	}
+	return nil
+}
+
+// listExists checks whether the list with passed id exists (or the id is empty)
+func listExists(listId string) bool {
+	return validateListReference(listId) == nil
+}
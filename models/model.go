@@ -2,10 +2,17 @@
 package models
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"slices"
 	"sort"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/search"
 	"todo-rest-backend/models/todo"
 )
 
@@ -17,85 +24,352 @@ type JsonExtendedResponse struct {
 
 // JsonDataResponse type definition with json tags
 type JsonDataResponse struct {
+	Meta interface{} `json:"meta,omitempty"` // Field to add some meta information to the API response
 	Data []todo.Todo `json:"data"`
 }
 
-// JsonErrorResponse type definition with json tags
-type JsonErrorResponse struct {
-	Error ApiError `json:"error"`
+// JsonPartialDataResponse type definition with json tags (todos reduced to selected fields)
+type JsonPartialDataResponse struct {
+	Meta interface{}              `json:"meta,omitempty"` // Field to add some meta information to the API response
+	Data []map[string]interface{} `json:"data"`
 }
 
-// ApiError type definition with json tags
+// ApiError problem details of an error response (RFC 7807, media type application/problem+json) with json tags
 type ApiError struct {
-	Status int    `json:"status"`
-	Title  string `json:"title"`
+	Type     string                    `json:"type"` // uri identifying the problem type, ends with the code
+	Title    string                    `json:"title"`
+	Status   int                       `json:"status"`
+	Detail   string                    `json:"detail,omitempty"`
+	Instance string                    `json:"instance,omitempty"` // request uri the problem occurred at
+	Code     string                    `json:"code"`               // machine-readable problem code
+	Errors   []repositories.FieldError `json:"errors,omitempty"`   // violated business rules of a validation problem
 }
 
-var todoRepository repositories.TodoRepository
+// todoRepository the set repository, reporting its changes to the search index, the event broker and the revision
+// recorder
+var todoRepository *repositories.ObservingTodoRepository
 
-// SetTodoRepository allows to set the repositories type
+// referenceMutex serializes the changes validated against the other todos (parents and blockers) from reading the
+// todos to storing the change, so that concurrent changes can't form a cycle or refer to a deleted todo together.
+// It is process-local: it doesn't serialize the changes of several processes sharing the files in csv mode.
+var referenceMutex sync.Mutex
+
+// errReferencesNeeded is returned by a change which has to be validated against the other todos, but didn't get
+// them (see withReferencesLocked)
+var errReferencesNeeded = errors.New("references have to be validated against the other todos")
+
+// SetTodoRepository allows to set the repositories type.
+// The repository is decorated to keep the search index up to date, to publish the change events and to record the
+// revisions.
 func SetTodoRepository(todoRepositoryNew repositories.TodoRepository) error {
 	if todoRepositoryNew == nil {
 		return errors.New("todo repositories must not be nil")
 	}
-	todoRepository = todoRepositoryNew
+	searchIndex = search.NewIndex()
+	todoRepository = repositories.NewObservingTodoRepository(todoRepositoryNew, searchIndex, eventBroker, revisionRecorder)
 	return nil
 }
 
-// Initialize initializes the repository (abstracted by repository pattern)
+// Initialize initializes the repository (abstracted by repository pattern) and builds the search index from its todos
 func Initialize() error {
 	if todoRepository == nil {
 		return errors.New("todo repositories must not be nil")
 	}
-	return todoRepository.Initialize()
+	err := todoRepository.Initialize()
+	if err != nil {
+		return err
+	}
+	todos, err := todoRepository.ReadTodos()
+	if err != nil {
+		return err
+	}
+	searchIndex.Rebuild(todos)
+	return nil
 }
 
-// ReadTodos returns todo's from repository (abstracted by repository pattern)
+// ReadTodos returns todo's (without trashed ones) from repository (abstracted by repository pattern)
 func ReadTodos() ([]todo.Todo, error) {
 	if todoRepository == nil {
 		return nil, errors.New("todo repositories must not be nil")
 	}
-	return todoRepository.ReadTodos()
+	return readTodosFiltered(repositories.TodoFilter{})
 }
 
-// ReadTodoById returns todo with passed id when existing from repository (abstracted by repository pattern)
+// ReadTodoById returns todo with passed id when existing and not trashed from repository (abstracted by repository pattern)
 func ReadTodoById(id string) (todo.Todo, error) {
 	if todoRepository == nil {
 		return todo.Todo{}, errors.New("todo repositories must not be nil")
 	}
-	return todoRepository.ReadTodoById(id)
+	todoRead, err := todoRepository.ReadTodoById(id)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	if todoRead.IsDeleted() {
+		return todo.Todo{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+	return todoRead, nil
 }
 
 // CreateTodo stores the passed todo in the repository and returns the stored todo (abstracted by repository pattern)
-func CreateTodo(todoToCreate todo.Todo) (todo.Todo, error) {
+func CreateTodo(ctx context.Context, todoToCreate todo.Todo) (todo.Todo, error) {
 	if todoRepository == nil {
 		return todo.Todo{}, errors.New("todoToCreate repositories must not be nil")
 	}
-	return todoRepository.CreateTodo(todoToCreate)
+	return withReferencesLocked(func(todos []todo.Todo) (todo.Todo, error) {
+		todoCreated, err := prepareCreation(todos, todoToCreate)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		return todoRepository.WithContext(ctx).CreateTodo(todoCreated)
+	})
+}
+
+// prepareCreation validates the references of the todo to create against the passed todos and sets the fields
+// managed by the models layer
+func prepareCreation(todos []todo.Todo, todoToCreate todo.Todo) (todo.Todo, error) {
+	err := validateListReference(todoToCreate.ListId)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	todoToCreate.BlockedBy = normalizeIds(todoToCreate.BlockedBy)
+	err = requireOtherTodos(todos, todo.Todo{}, todoToCreate)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	err = validateParent(todos, "", todoToCreate.ParentId)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	err = validateDependencies(todos, todo.Todo{}, todoToCreate)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	now := time.Now().UTC()
+	todoToCreate.DeletedAt = nil
+	todoToCreate.Version = 1
+	todoToCreate.CreatedAt = &now
+	todoToCreate.UpdatedAt = &now
+	todoToCreate.CompletedAt = nil
+	if todoToCreate.Terminated {
+		todoToCreate.CompletedAt = &now
+	}
+	if todoToCreate.Priority == "" {
+		todoToCreate.Priority = todo.PriorityNormal
+	}
+	todoToCreate.Title = strings.TrimSpace(todoToCreate.Title)
+	todoToCreate.Description = strings.TrimSpace(todoToCreate.Description)
+	todoToCreate.Tags = normalizeTags(todoToCreate.Tags)
+	todoToCreate.Recurrence = normalizeRecurrence(todoToCreate.Recurrence)
+	return todoToCreate, nil
+}
+
+// applyChange takes over the fields managed by the models layer from the current into the changed todo:
+// id, creation time, incremented version, update time and completion time. It also applies defaults and normalizations.
+func applyChange(currentTodo todo.Todo, changedTodo todo.Todo) todo.Todo {
+	now := time.Now().UTC()
+	changedTodo.Id = currentTodo.Id
+	changedTodo.Version = currentTodo.Version + 1
+	changedTodo.CreatedAt = currentTodo.CreatedAt
+	changedTodo.UpdatedAt = &now
+
+	switch {
+	case !changedTodo.Terminated:
+		changedTodo.CompletedAt = nil
+	case currentTodo.Terminated && currentTodo.CompletedAt != nil:
+		changedTodo.CompletedAt = currentTodo.CompletedAt
+	default:
+		changedTodo.CompletedAt = &now
+	}
+
+	if changedTodo.Priority == "" {
+		changedTodo.Priority = todo.PriorityNormal
+	}
+	changedTodo.Title = strings.TrimSpace(changedTodo.Title)
+	changedTodo.Description = strings.TrimSpace(changedTodo.Description)
+	changedTodo.Tags = normalizeTags(changedTodo.Tags)
+	changedTodo.BlockedBy = normalizeIds(changedTodo.BlockedBy)
+	changedTodo.Recurrence = normalizeRecurrence(changedTodo.Recurrence)
+	return changedTodo
+}
+
+// readTodosForValidation returns the todos to validate references between todos against, never nil.
+// They are read before changing a todo, since the repository can't be read while it applies a change.
+// The caller must hold referenceMutex until the change is stored.
+func readTodosForValidation() ([]todo.Todo, error) {
+	todos, err := todoRepository.ReadTodos()
+	if err != nil {
+		return nil, err
+	}
+	if todos == nil {
+		todos = []todo.Todo{}
+	}
+	return todos, nil
+}
+
+// requireOtherTodos returns errReferencesNeeded when the todos to validate against weren't read (nil), but the change
+// from the current to the changed todo needs them: a new parent or new blockers have to exist without forming a cycle,
+// and the blockers have to be terminated when the todo gets terminated
+func requireOtherTodos(todos []todo.Todo, currentTodo todo.Todo, changedTodo todo.Todo) error {
+	if todos != nil {
+		return nil
+	}
+	parentChanged := changedTodo.ParentId != "" && changedTodo.ParentId != currentTodo.ParentId
+	blockersChecked := len(changedTodo.BlockedBy) > 0 && (!slices.Equal(currentTodo.BlockedBy, changedTodo.BlockedBy) ||
+		changedTodo.Terminated && !currentTodo.Terminated)
+	if parentChanged || blockersChecked {
+		return errReferencesNeeded
+	}
+	return nil
 }
 
-// SortTodosAfterIdAscending sorts the todos ascending after the id and returns sorted todos
+// hasReferences reports whether the todo refers to other todos as parent or blockers
+func hasReferences(referringTodo todo.Todo) bool {
+	return referringTodo.ParentId != "" || len(referringTodo.BlockedBy) > 0
+}
+
+// SortTodosAfterIdAscending sorts the todos ascending after the id and returns sorted todos.
+// Numeric ids are compared as numbers and sorted before non-numeric ids (e.g. UUIDs or ULIDs),
+// which are compared lexicographically.
 func SortTodosAfterIdAscending(todos []todo.Todo) []todo.Todo {
-	sort.Slice(todos, func(i, j int) bool {
-		leftValueAsInt, _ := strconv.Atoi(todos[i].Id)
-		rightValueAsInt, _ := strconv.Atoi(todos[j].Id)
-		return leftValueAsInt < rightValueAsInt
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todo.CompareIds(todos[i].Id, todos[j].Id) < 0
 	})
 	return todos
 }
 
-// UpdateTodoById returns updated todo from repository (abstracted by repository pattern)
-func UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
+// UpdateTodoById returns updated todo from repository (abstracted by repository pattern).
+// Terminating a recurring todo creates the todo for its next occurrence.
+// ifMatch is the value of an If-Match precondition, empty for unconditional updates.
+func UpdateTodoById(ctx context.Context, id string, todoUpdate todo.Todo, ifMatch string) (todo.Todo, error) {
 	if todoRepository == nil {
 		return todo.Todo{}, errors.New("todo repositories must not be nil")
 	}
-	return todoRepository.UpdateTodoById(id, todoUpdate)
+	var series todo.Todo
+	todoUpdated, err := withReferencesLocked(func(todos []todo.Todo) (todo.Todo, error) {
+		return todoRepository.WithContext(ctx).PatchTodoById(id, updateChange(todos, id, todoUpdate, ifMatch, &series))
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	continueSeriesAfterChange(ctx, series)
+	return todoUpdated, nil
+}
+
+// updateChange returns the change of the todo with passed id by the update, validating references against the
+// passed todos. series is set when the change terminates a recurring todo (see continueSeries).
+func updateChange(todos []todo.Todo, id string, todoUpdate todo.Todo, ifMatch string, series *todo.Todo) func(todo.Todo) (todo.Todo, error) {
+	return func(currentTodo todo.Todo) (todo.Todo, error) {
+		// trashed todos have to be restored before they can be updated
+		if currentTodo.IsDeleted() {
+			return todo.Todo{}, repositories.ErrNotFound
+		}
+		err := CheckIfMatch(ifMatch, currentTodo)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		if todoUpdate.ListId != currentTodo.ListId {
+			err = validateListReference(todoUpdate.ListId)
+			if err != nil {
+				return todo.Todo{}, err
+			}
+		}
+		todoUpdate.DeletedAt = nil
+		todoUpdated := applyChange(currentTodo, todoUpdate)
+		err = requireOtherTodos(todos, currentTodo, todoUpdated)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		if todoUpdated.ParentId != currentTodo.ParentId {
+			err = validateParent(todos, id, todoUpdated.ParentId)
+			if err != nil {
+				return todo.Todo{}, err
+			}
+		}
+		err = validateDependencies(todos, currentTodo, todoUpdated)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		continueSeries(currentTodo, &todoUpdated, series)
+		return todoUpdated, nil
+	}
 }
 
-// DeleteTodoById delete todo from repository (abstracted by repository pattern)
-func DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
+// DeleteTodoById delete todo from repository (abstracted by repository pattern).
+// In trash mode the todo is moved to the trash instead. Subtasks of the todo are moved to the top level
+// and it is removed from the blockers of other todos, also when it is moved to the trash: restoring it from the trash
+// doesn't restore these references.
+// ifMatch is the value of an If-Match precondition, empty for unconditional deletes.
+func DeleteTodoById(ctx context.Context, id string, todoDelete todo.Todo, ifMatch string) (todo.Todo, error) {
 	if todoRepository == nil {
 		return todo.Todo{}, errors.New("todoToDelete repositories must not be nil")
 	}
-	return todoRepository.DeleteTodoById(id, todoDelete)
+	referenceMutex.Lock()
+	defer referenceMutex.Unlock()
+	todoDeleted, err := deleteTodo(ctx, id, todoDelete, ifMatch)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	// the todo is deleted already, a failed detach leaves references to it behind but doesn't fail the delete
+	err = detachReferences(ctx, id)
+	if err != nil {
+		log.Println("detaching the references to deleted todo", id, "failed:", err)
+	}
+	return todoDeleted, nil
+}
+
+// withReferencesLocked passes the todos to validate references against to the passed change and returns its result,
+// holding referenceMutex until the change is stored. The change gets no todos (nil) first, they are only read when
+// it returns errReferencesNeeded, so that changes leaving the references alone don't read all todos.
+func withReferencesLocked(change func([]todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	referenceMutex.Lock()
+	defer referenceMutex.Unlock()
+	todoChanged, err := change(nil)
+	if !errors.Is(err, errReferencesNeeded) {
+		return todoChanged, err
+	}
+	todos, err := readTodosForValidation()
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	return change(todos)
+}
+
+func deleteTodo(ctx context.Context, id string, todoDelete todo.Todo, ifMatch string) (todo.Todo, error) {
+	if trashEnabled {
+		return todoRepository.WithContext(ctx).PatchTodoById(id, trashChange(ifMatch))
+	}
+
+	todoRead, err := ReadTodoById(id)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	if ifMatch != "" {
+		err = CheckIfMatch(ifMatch, todoRead)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		// the repository deletes only when the todo wasn't changed in the meantime
+		todoDelete.Version = todoRead.Version
+	}
+	todoDeleted, err := todoRepository.WithContext(ctx).DeleteTodoById(id, todoDelete)
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		return todo.Todo{}, ErrPreconditionFailed
+	}
+	return todoDeleted, err
+}
+
+// trashChange returns the change moving a todo to the trash
+func trashChange(ifMatch string) func(todo.Todo) (todo.Todo, error) {
+	return func(currentTodo todo.Todo) (todo.Todo, error) {
+		if currentTodo.IsDeleted() {
+			return todo.Todo{}, repositories.ErrNotFound
+		}
+		err := CheckIfMatch(ifMatch, currentTodo)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		todoTrashed := applyChange(currentTodo, currentTodo)
+		todoTrashed.DeletedAt = todoTrashed.UpdatedAt
+		return todoTrashed, nil
+	}
 }
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// Query parameter names of the todo list pagination
+const (
+	QueryParameterLimit  = "limit"
+	QueryParameterCursor = "cursor"
+)
+
+// DefaultPageLimit page size used when a cursor is passed without limit
+const DefaultPageLimit = 50
+
+// MaxPageLimit maximum page size
+const MaxPageLimit = 1000
+
+// Cursor directions
+const (
+	cursorDirectionNext = "next"
+	cursorDirectionPrev = "prev"
+)
+
+// PageMeta meta information of a todo list page
+type PageMeta struct {
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	TotalCount int    `json:"totalCount"`
+}
+
+// TodoPage page of the todo list
+type TodoPage struct {
+	Todos []todo.Todo
+	Meta  PageMeta
+}
+
+// pageCursor position in the todo list. Instead of an offset it keeps the sort values of the todo at the
+// page boundary, so that pages stay stable while todos are created or deleted between requests.
+type pageCursor struct {
+	Direction string                 `json:"d"`
+	Boundary  map[string]interface{} `json:"b"` // sort field values (json names) of the boundary todo
+	Query     string                 `json:"q"` // signature of filter and sort the cursor was created for
+}
+
+// parsePagination parses limit and cursor of the passed query parameters into the query
+func parsePagination(values url.Values, query *TodoQuery) error {
+	if values.Has(QueryParameterLimit) {
+		limit, err := strconv.Atoi(values.Get(QueryParameterLimit))
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return fmt.Errorf("%w: parameter %q must be a number between 1 and %d", ErrInvalidQuery, QueryParameterLimit, MaxPageLimit)
+		}
+		query.Limit = limit
+	}
+
+	if values.Has(QueryParameterCursor) {
+		cursor, err := decodeCursor(values.Get(QueryParameterCursor))
+		if err != nil || (cursor.Direction != cursorDirectionNext && cursor.Direction != cursorDirectionPrev) {
+			return fmt.Errorf("%w: parameter %q is malformed", ErrInvalidQuery, QueryParameterCursor)
+		}
+		if cursor.Query != querySignature(*query) {
+			return fmt.Errorf("%w: parameter %q belongs to a list with other filter or sort parameters", ErrInvalidQuery, QueryParameterCursor)
+		}
+		query.cursor = &cursor
+		if query.Limit == 0 {
+			query.Limit = DefaultPageLimit
+		}
+	}
+
+	return nil
+}
+
+// ReadTodoPage returns the page of the todo list selected by the query.
+// Without limit the page contains all todo's matching the filter.
+func ReadTodoPage(query TodoQuery) (TodoPage, error) {
+	todos, err := ReadTodosByQuery(query)
+	if err != nil {
+		return TodoPage{}, err
+	}
+	page := TodoPage{Todos: todos, Meta: PageMeta{TotalCount: len(todos)}}
+	if query.Limit == 0 {
+		return page, nil
+	}
+
+	sortFields := withIdTieBreaker(query.Sort)
+	start, end := 0, len(todos)
+	if query.cursor != nil {
+		boundary, err := boundaryTodo(query.cursor.Boundary)
+		if err != nil {
+			return TodoPage{}, fmt.Errorf("%w: parameter %q is malformed", ErrInvalidQuery, QueryParameterCursor)
+		}
+		position, _ := slices.BinarySearchFunc(todos, boundary, func(element todo.Todo, target todo.Todo) int {
+			return compareTodos(element, target, sortFields)
+		})
+		if query.cursor.Direction == cursorDirectionNext {
+			// skip the boundary todo itself when it still exists
+			start = position
+			if start < len(todos) && compareTodos(todos[start], boundary, sortFields) == 0 {
+				start++
+			}
+		} else {
+			end = position
+		}
+	}
+	if query.cursor != nil && query.cursor.Direction == cursorDirectionPrev {
+		start = max(end-query.Limit, 0)
+	} else {
+		end = min(start+query.Limit, len(todos))
+	}
+
+	page.Todos = todos[start:end]
+	signature := querySignature(query)
+	if end < len(todos) && end > start {
+		page.Meta.NextCursor, err = encodeCursor(cursorDirectionNext, todos[end-1], sortFields, signature)
+		if err != nil {
+			return TodoPage{}, err
+		}
+	}
+	if start > 0 && start < len(todos) {
+		page.Meta.PrevCursor, err = encodeCursor(cursorDirectionPrev, todos[start], sortFields, signature)
+		if err != nil {
+			return TodoPage{}, err
+		}
+	}
+	return page, nil
+}
+
+// withIdTieBreaker appends the id to the sort fields, making the order total
+func withIdTieBreaker(sortFields []SortField) []SortField {
+	for _, sortField := range sortFields {
+		if sortField.Name == "id" {
+			return sortFields
+		}
+	}
+	return append(slices.Clone(sortFields), SortField{Name: "id"})
+}
+
+// compareTodos compares two todos by the passed sort fields
+func compareTodos(left todo.Todo, right todo.Todo, sortFields []SortField) int {
+	for _, sortField := range sortFields {
+		result := todoComparators[sortField.Name](left, right)
+		if sortField.Descending {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// querySignature identifies filter and sort of the query, so that a cursor isn't used for another list
+func querySignature(query TodoQuery) string {
+	filter, _ := json.Marshal(query.Filter)
+	sort, _ := json.Marshal(query.Sort)
+	content := append(filter, sort...)
+	if query.AsOf != nil {
+		// only added when set, so that the cursors of the current list stay valid
+		content = append(content, query.AsOf.UTC().Format(time.RFC3339Nano)...)
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:8])
+}
+
+func encodeCursor(direction string, boundary todo.Todo, sortFields []SortField, signature string) (string, error) {
+	var names []string
+	for _, sortField := range sortFields {
+		names = append(names, sortField.Name)
+	}
+	boundaryFields, err := SelectFields([]todo.Todo{boundary}, names)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := json.Marshal(pageCursor{Direction: direction, Boundary: boundaryFields[0], Query: signature})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+func decodeCursor(encoded string) (pageCursor, error) {
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	var cursor pageCursor
+	err = json.Unmarshal(content, &cursor)
+	return cursor, err
+}
+
+// boundaryTodo converts the boundary sort values of a cursor back into a todo for comparison
+func boundaryTodo(boundary map[string]interface{}) (todo.Todo, error) {
+	content, err := json.Marshal(boundary)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	var boundaryTodo todo.Todo
+	err = json.Unmarshal(content, &boundaryTodo)
+	return boundaryTodo, err
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"todo-rest-backend/models/recurrence"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// MergePatchContentType content type of a JSON Merge Patch document (RFC 7396)
+const MergePatchContentType = "application/merge-patch+json"
+
+// JsonPatchContentType content type of a JSON Patch document (RFC 6902)
+const JsonPatchContentType = "application/json-patch+json"
+
+// ErrInvalidPatch is returned when the patch document can't be applied or the patched todo is invalid
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ErrUnsupportedPatchType is returned for patch document content types other than the supported ones
+var ErrUnsupportedPatchType = errors.New("unsupported patch content type")
+
+// ValidateTodo checks the passed todo against the business rules and returns all violations as
+// repositories.ValidationError
+func ValidateTodo(todoToValidate todo.Todo) error {
+	fieldErrors := validateFields(todoFieldRules, todoToValidate)
+	if todoToValidate.Priority != "" && !todoToValidate.Priority.IsValid() {
+		fieldErrors = append(fieldErrors, repositories.FieldError{Field: "priority",
+			Message: fmt.Sprintf("invalid priority %q", todoToValidate.Priority)})
+	}
+	for index, tag := range todoToValidate.Tags {
+		err := validateTag(strings.TrimSpace(tag))
+		if err != nil {
+			fieldErrors = append(fieldErrors, repositories.FieldError{Field: fmt.Sprintf("tags[%d]", index), Message: err.Error()})
+		}
+	}
+	if todoToValidate.Recurrence != "" {
+		_, err := recurrence.Parse(todoToValidate.Recurrence)
+		if err != nil {
+			fieldErrors = append(fieldErrors, repositories.FieldError{Field: "recurrence", Message: err.Error()})
+		}
+	}
+	return repositories.NewValidationError(fieldErrors)
+}
+
+// PatchTodoById applies the passed patch document of the passed content type to the todo with passed id
+// and returns the patched todo (abstracted by repository pattern).
+// Terminating a recurring todo creates the todo for its next occurrence.
+// ifMatch is the value of an If-Match precondition, empty for unconditional patches.
+func PatchTodoById(ctx context.Context, id string, contentType string, patchDocument []byte, ifMatch string) (todo.Todo, error) {
+	if todoRepository == nil {
+		return todo.Todo{}, errors.New("todo repositories must not be nil")
+	}
+	if contentType != MergePatchContentType && contentType != JsonPatchContentType {
+		return todo.Todo{}, ErrUnsupportedPatchType
+	}
+	var series todo.Todo
+	todoPatched, err := withReferencesLocked(func(todos []todo.Todo) (todo.Todo, error) {
+		return todoRepository.WithContext(ctx).PatchTodoById(id, patchChange(todos, id, contentType, patchDocument, ifMatch, &series))
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	continueSeriesAfterChange(ctx, series)
+	return todoPatched, nil
+}
+
+// patchChange returns the change of the todo with passed id by the patch document, validating references against the
+// passed todos. series is set when the change terminates a recurring todo (see continueSeries).
+func patchChange(todos []todo.Todo, id string, contentType string, patchDocument []byte, ifMatch string, series *todo.Todo) func(todo.Todo) (todo.Todo, error) {
+	return func(currentTodo todo.Todo) (todo.Todo, error) {
+		// trashed todos have to be restored before they can be patched
+		if currentTodo.IsDeleted() {
+			return todo.Todo{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+		}
+		err := CheckIfMatch(ifMatch, currentTodo)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+
+		// violations of the patched document are reported together with the ones of the patched todo
+		todoPatched, decodeErr := applyPatch(currentTodo, contentType, patchDocument)
+		if decodeErr != nil && !errors.Is(decodeErr, repositories.ErrValidation) {
+			return todo.Todo{}, fmt.Errorf("%w: %w", ErrInvalidPatch, decodeErr)
+		}
+
+		// the trash state and the fields managed by the models layer can't be changed by a patch
+		todoPatched.DeletedAt = currentTodo.DeletedAt
+		todoPatched = applyChange(currentTodo, todoPatched)
+
+		err = joinValidationErrors(decodeErr, ValidateTodo(todoPatched))
+		if err != nil {
+			return todo.Todo{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		if todoPatched.ListId != currentTodo.ListId {
+			err = validateListReference(todoPatched.ListId)
+			if err != nil {
+				return todo.Todo{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+			}
+		}
+		err = requireOtherTodos(todos, currentTodo, todoPatched)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		if todoPatched.ParentId != currentTodo.ParentId {
+			err = validateParent(todos, id, todoPatched.ParentId)
+			if err != nil {
+				return todo.Todo{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+			}
+		}
+		err = validateDependencies(todos, currentTodo, todoPatched)
+		if errors.Is(err, ErrInvalidBlocker) {
+			return todo.Todo{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		continueSeries(currentTodo, &todoPatched, series)
+		return todoPatched, nil
+	}
+}
+
+func applyPatch(currentTodo todo.Todo, contentType string, patchDocument []byte) (todo.Todo, error) {
+	currentDocument, err := json.Marshal(currentTodo)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	var patchedDocument []byte
+	switch contentType {
+	case MergePatchContentType:
+		patchedDocument, err = jsonpatch.MergePatch(currentDocument, patchDocument)
+	case JsonPatchContentType:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(patchDocument)
+		if err == nil {
+			patchedDocument, err = patch.Apply(currentDocument)
+		}
+	}
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	var todoPatched todo.Todo
+	err = DecodeBody(bytes.NewReader(patchedDocument), &todoPatched)
+	return todoPatched, err
+}
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// Query parameter names of the todo list
+const (
+	QueryParameterTerminated    = "terminated"
+	QueryParameterText          = "q"
+	QueryParameterSort          = "sort"
+	QueryParameterFields        = "fields"
+	QueryParameterPriority      = "priority"
+	QueryParameterDueBefore     = "dueBefore"
+	QueryParameterDueAfter      = "dueAfter"
+	QueryParameterCreatedBefore = "createdBefore"
+	QueryParameterCreatedAfter  = "createdAfter"
+	QueryParameterTag           = "tag"
+	QueryParameterTagMatch      = "tagMatch"
+	QueryParameterListId        = "listId"
+	QueryParameterParentId      = "parentId"
+	QueryParameterTree          = "tree"
+	QueryParameterOrder         = "order"
+	QueryParameterAsOf          = "asOf"
+)
+
+// Values of the tagMatch query parameter
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+// ErrInvalidQuery is returned for invalid query parameters of the todo list
+var ErrInvalidQuery = errors.New("invalid query")
+
+// TodoQuery options for reading the todo list
+type TodoQuery struct {
+	Filter repositories.TodoFilter
+	Sort   []SortField
+	Fields []string   // empty selects all fields
+	Limit  int        // page size, 0 for all todos
+	Tree   bool       // nests subtasks below their parents, can't be combined with pagination or field selection
+	Order  string     // OrderDependencies orders blockers before the todos they block (within Sort), can't be paginated
+	AsOf   *time.Time // reads the todos as they were at this point in time instead of the current ones
+
+	cursor *pageCursor
+}
+
+// SortField field to sort by, prefixed with "-" in the query parameter for descending order
+type SortField struct {
+	Name       string
+	Descending bool
+}
+
+// todoComparators comparison functions of the sortable fields (json names)
+var todoComparators = map[string]func(todo.Todo, todo.Todo) int{
+	"id":          func(left todo.Todo, right todo.Todo) int { return todo.CompareIds(left.Id, right.Id) },
+	"title":       func(left todo.Todo, right todo.Todo) int { return strings.Compare(left.Title, right.Title) },
+	"description": func(left todo.Todo, right todo.Todo) int { return strings.Compare(left.Description, right.Description) },
+	"terminated": func(left todo.Todo, right todo.Todo) int {
+		return compareBools(left.Terminated, right.Terminated)
+	},
+	"version": func(left todo.Todo, right todo.Todo) int { return cmp.Compare(left.Version, right.Version) },
+	"priority": func(left todo.Todo, right todo.Todo) int {
+		return cmp.Compare(left.Priority.Rank(), right.Priority.Rank())
+	},
+	"dueAt":       func(left todo.Todo, right todo.Todo) int { return compareTimes(left.DueAt, right.DueAt) },
+	"createdAt":   func(left todo.Todo, right todo.Todo) int { return compareTimes(left.CreatedAt, right.CreatedAt) },
+	"updatedAt":   func(left todo.Todo, right todo.Todo) int { return compareTimes(left.UpdatedAt, right.UpdatedAt) },
+	"completedAt": func(left todo.Todo, right todo.Todo) int { return compareTimes(left.CompletedAt, right.CompletedAt) },
+	"listId":      func(left todo.Todo, right todo.Todo) int { return todo.CompareIds(left.ListId, right.ListId) },
+	"parentId":    func(left todo.Todo, right todo.Todo) int { return todo.CompareIds(left.ParentId, right.ParentId) },
+}
+
+// selectableFields json names of the fields which can be selected
+var selectableFields = []string{"id", "title", "description", "terminated", "deletedAt", "version", "dueAt", "priority",
+	"createdAt", "updatedAt", "completedAt", "tags", "listId", "parentId", "blockedBy", "recurrence"}
+
+// ParseTodoQuery parses the query parameters of the todo list
+func ParseTodoQuery(values url.Values) (TodoQuery, error) {
+	query := TodoQuery{Sort: []SortField{{Name: "id"}}}
+
+	for name := range values {
+		if !slices.Contains(todoQueryParameters, name) {
+			return TodoQuery{}, fmt.Errorf("%w: unknown parameter %q", ErrInvalidQuery, name)
+		}
+	}
+
+	if values.Has(QueryParameterTerminated) {
+		terminated, err := strconv.ParseBool(values.Get(QueryParameterTerminated))
+		if err != nil {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q must be true or false", ErrInvalidQuery, QueryParameterTerminated)
+		}
+		query.Filter.Terminated = &terminated
+	}
+
+	query.Filter.Text = values.Get(QueryParameterText)
+	query.Filter.ListId = values.Get(QueryParameterListId)
+	query.Filter.ParentId = values.Get(QueryParameterParentId)
+
+	if values.Has(QueryParameterPriority) {
+		for _, name := range splitList(values.Get(QueryParameterPriority)) {
+			priority := todo.Priority(name)
+			if !priority.IsValid() {
+				return TodoQuery{}, fmt.Errorf("%w: invalid priority %q, allowed priorities are %s",
+					ErrInvalidQuery, name, joinPriorities(todo.Priorities))
+			}
+			query.Filter.Priorities = append(query.Filter.Priorities, priority)
+		}
+	}
+
+	if values.Has(QueryParameterTag) {
+		query.Filter.Tags = normalizeTags(values[QueryParameterTag])
+		for _, tag := range query.Filter.Tags {
+			err := validateTag(tag)
+			if err != nil {
+				return TodoQuery{}, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+			}
+		}
+	}
+	switch values.Get(QueryParameterTagMatch) {
+	case "", TagMatchAny:
+		query.Filter.AllTags = false
+	case TagMatchAll:
+		query.Filter.AllTags = true
+	default:
+		return TodoQuery{}, fmt.Errorf("%w: parameter %q must be %q or %q", ErrInvalidQuery, QueryParameterTagMatch, TagMatchAny, TagMatchAll)
+	}
+
+	timeParameters := []struct {
+		name   string
+		target **time.Time
+	}{
+		{QueryParameterDueBefore, &query.Filter.DueBefore}, {QueryParameterDueAfter, &query.Filter.DueAfter},
+		{QueryParameterCreatedBefore, &query.Filter.CreatedBefore}, {QueryParameterCreatedAfter, &query.Filter.CreatedAfter},
+		{QueryParameterAsOf, &query.AsOf},
+	}
+	for _, timeParameter := range timeParameters {
+		if !values.Has(timeParameter.name) {
+			continue
+		}
+		limit, err := time.Parse(time.RFC3339, values.Get(timeParameter.name))
+		if err != nil {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q must be a RFC 3339 timestamp", ErrInvalidQuery, timeParameter.name)
+		}
+		*timeParameter.target = &limit
+	}
+
+	if values.Has(QueryParameterSort) {
+		query.Sort = nil
+		for _, name := range splitList(values.Get(QueryParameterSort)) {
+			sortField := SortField{Name: strings.TrimPrefix(name, "-"), Descending: strings.HasPrefix(name, "-")}
+			if _, ok := todoComparators[sortField.Name]; !ok {
+				return TodoQuery{}, fmt.Errorf("%w: can't sort by %q, allowed fields are %s",
+					ErrInvalidQuery, sortField.Name, strings.Join(sortedKeys(todoComparators), ", "))
+			}
+			query.Sort = append(query.Sort, sortField)
+		}
+		if len(query.Sort) == 0 {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q must not be empty", ErrInvalidQuery, QueryParameterSort)
+		}
+	}
+
+	if values.Has(QueryParameterFields) {
+		for _, name := range splitList(values.Get(QueryParameterFields)) {
+			if !slices.Contains(selectableFields, name) {
+				return TodoQuery{}, fmt.Errorf("%w: can't select field %q, allowed fields are %s",
+					ErrInvalidQuery, name, strings.Join(selectableFields, ", "))
+			}
+			query.Fields = append(query.Fields, name)
+		}
+		if len(query.Fields) == 0 {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q must not be empty", ErrInvalidQuery, QueryParameterFields)
+		}
+	}
+
+	err := parsePagination(values, &query)
+	if err != nil {
+		return TodoQuery{}, err
+	}
+
+	if values.Has(QueryParameterOrder) {
+		query.Order = values.Get(QueryParameterOrder)
+		if query.Order != OrderDependencies {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q must be %q", ErrInvalidQuery, QueryParameterOrder, OrderDependencies)
+		}
+		if query.Limit > 0 {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q can't be combined with %q or %q",
+				ErrInvalidQuery, QueryParameterOrder, QueryParameterLimit, QueryParameterCursor)
+		}
+	}
+
+	if values.Has(QueryParameterTree) {
+		query.Tree, err = strconv.ParseBool(values.Get(QueryParameterTree))
+		if err != nil {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q must be true or false", ErrInvalidQuery, QueryParameterTree)
+		}
+		if query.Tree && (query.Limit > 0 || len(query.Fields) > 0) {
+			return TodoQuery{}, fmt.Errorf("%w: parameter %q can't be combined with %q, %q or %q",
+				ErrInvalidQuery, QueryParameterTree, QueryParameterLimit, QueryParameterCursor, QueryParameterFields)
+		}
+	}
+
+	return query, nil
+}
+
+// todoQueryParameters names of all query parameters of the todo list
+var todoQueryParameters = []string{QueryParameterTerminated, QueryParameterText, QueryParameterSort, QueryParameterFields,
+	QueryParameterLimit, QueryParameterCursor, QueryParameterPriority, QueryParameterDueBefore, QueryParameterDueAfter,
+	QueryParameterCreatedBefore, QueryParameterCreatedAfter, QueryParameterTag, QueryParameterTagMatch,
+	QueryParameterListId, QueryParameterParentId, QueryParameterTree, QueryParameterOrder, QueryParameterAsOf}
+
+// ReadTodosByQuery returns the todo's matching the filter of the query in the order of the query
+func ReadTodosByQuery(query TodoQuery) ([]todo.Todo, error) {
+	if todoRepository == nil {
+		return nil, errors.New("todo repositories must not be nil")
+	}
+	var todos []todo.Todo
+	var err error
+	if query.AsOf != nil {
+		todos, err = readTodosAsOf(*query.AsOf, query.Filter)
+	} else {
+		todos, err = readTodosFiltered(query.Filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	todos = SortTodos(todos, withIdTieBreaker(query.Sort))
+	if query.Order == OrderDependencies {
+		todos = sortByDependencies(todos)
+	}
+	return todos, nil
+}
+
+// SortTodos sorts the todos by the passed fields (later fields break ties of earlier ones) and returns sorted todos
+func SortTodos(todos []todo.Todo, sortFields []SortField) []todo.Todo {
+	slices.SortStableFunc(todos, func(left todo.Todo, right todo.Todo) int {
+		return compareTodos(left, right, sortFields)
+	})
+	return todos
+}
+
+// SelectFields returns the todos reduced to the passed fields (json names)
+func SelectFields(todos []todo.Todo, fields []string) ([]map[string]interface{}, error) {
+	selectedTodos := []map[string]interface{}{}
+	for _, currentTodo := range todos {
+		content, err := json.Marshal(currentTodo)
+		if err != nil {
+			return nil, err
+		}
+		var allFields map[string]interface{}
+		err = json.Unmarshal(content, &allFields)
+		if err != nil {
+			return nil, err
+		}
+
+		selectedTodo := map[string]interface{}{}
+		for _, field := range fields {
+			value, ok := allFields[field]
+			if ok {
+				selectedTodo[field] = value
+			}
+		}
+		selectedTodos = append(selectedTodos, selectedTodo)
+	}
+	return selectedTodos, nil
+}
+
+// readTodosFiltered reads the todos matching the filter, letting the repository filter when it is able to
+func readTodosFiltered(filter repositories.TodoFilter) ([]todo.Todo, error) {
+	return todoRepository.ReadTodosFiltered(filter)
+}
+
+// readTodosAsOf reads the todos as they were at the passed point in time matching the filter, when the repository
+// keeps the history
+func readTodosAsOf(asOf time.Time, filter repositories.TodoFilter) ([]todo.Todo, error) {
+	todos, err := todoRepository.ReadTodosAsOf(asOf)
+	if err != nil {
+		return nil, err
+	}
+	var filteredTodos []todo.Todo
+	for _, currentTodo := range todos {
+		if filter.Matches(currentTodo) {
+			filteredTodos = append(filteredTodos, currentTodo)
+		}
+	}
+	return filteredTodos, nil
+}
+
+// splitList splits a comma separated list, ignoring empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func compareBools(left bool, right bool) int {
+	switch {
+	case left == right:
+		return 0
+	case left:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// compareTimes compares optional points in time, sorting missing ones last
+func compareTimes(left *time.Time, right *time.Time) int {
+	switch {
+	case left == nil && right == nil:
+		return 0
+	case left == nil:
+		return 1
+	case right == nil:
+		return -1
+	default:
+		return left.Compare(*right)
+	}
+}
+
+func joinPriorities(priorities []todo.Priority) string {
+	var names []string
+	for _, priority := range priorities {
+		names = append(names, string(priority))
+	}
+	return strings.Join(names, ", ")
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,291 @@
+// Package recurrence contains a subset of the iCalendar recurrence rules (RFC 5545 RRULE):
+// FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, BYDAY, COUNT and UNTIL
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported frequencies
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+)
+
+// SearchHorizon how far the search for the next occurrence reaches into the future
+const SearchHorizon = 50 * 366 * 24 * time.Hour
+
+// untilLayouts accepted formats of UNTIL (date-time in UTC or date)
+var untilLayouts = []string{"20060102T150405Z", "20060102"}
+
+// weekdayNames iCalendar names of the weekdays
+var weekdayNames = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// ErrInvalidRule is returned for rules which can't be parsed or use unsupported parts
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Weekday entry of BYDAY, e.g. "MO" or with ordinal "-1FR" (last friday of the month, only MONTHLY)
+type Weekday struct {
+	Ordinal int // 0 matches every such weekday
+	Day     time.Weekday
+}
+
+// Rule parsed recurrence rule
+type Rule struct {
+	Frequency string
+	Interval  int
+	ByDay     []Weekday
+	Count     int        // remaining occurrences including the first one, 0 for unlimited
+	Until     *time.Time // last possible occurrence, nil for unlimited
+}
+
+// Parse parses a recurrence rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10" (an "RRULE:" prefix is allowed)
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	if value == "" {
+		return Rule{}, fmt.Errorf("%w: rule is empty", ErrInvalidRule)
+	}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		name, partValue, found := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		partValue = strings.TrimSpace(partValue)
+		if !found || partValue == "" {
+			return Rule{}, fmt.Errorf("%w: part %q must have the form NAME=VALUE", ErrInvalidRule, part)
+		}
+		if seen[name] {
+			return Rule{}, fmt.Errorf("%w: part %s is given twice", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			if partValue != FrequencyDaily && partValue != FrequencyWeekly && partValue != FrequencyMonthly {
+				return Rule{}, fmt.Errorf("%w: FREQ must be %s, %s or %s", ErrInvalidRule, FrequencyDaily, FrequencyWeekly, FrequencyMonthly)
+			}
+			rule.Frequency = partValue
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(partValue)
+			if err != nil || rule.Interval < 1 {
+				return Rule{}, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalidRule)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(partValue)
+			if err != nil || rule.Count < 1 {
+				return Rule{}, fmt.Errorf("%w: COUNT must be a positive number", ErrInvalidRule)
+			}
+		case "UNTIL":
+			rule.Until, err = parseUntil(partValue)
+			if err != nil {
+				return Rule{}, err
+			}
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(partValue)
+			if err != nil {
+				return Rule{}, err
+			}
+		default:
+			return Rule{}, fmt.Errorf("%w: part %s is not supported", ErrInvalidRule, name)
+		}
+	}
+
+	if rule.Frequency == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL must not be combined", ErrInvalidRule)
+	}
+	if rule.Frequency != FrequencyMonthly {
+		for _, weekday := range rule.ByDay {
+			if weekday.Ordinal != 0 {
+				return Rule{}, fmt.Errorf("%w: BYDAY ordinals are only supported with FREQ=%s", ErrInvalidRule, FrequencyMonthly)
+			}
+		}
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (*time.Time, error) {
+	for _, layout := range untilLayouts {
+		until, err := time.Parse(layout, value)
+		if err == nil {
+			if layout == "20060102" {
+				// a date includes the whole day
+				until = until.Add(24*time.Hour - time.Nanosecond)
+			}
+			return &until, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: UNTIL must have the form YYYYMMDD or YYYYMMDDTHHMMSSZ", ErrInvalidRule)
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var weekdays []Weekday
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("%w: invalid BYDAY entry %q", ErrInvalidRule, entry)
+		}
+		day, ok := weekdayNames[entry[len(entry)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid BYDAY entry %q", ErrInvalidRule, entry)
+		}
+		weekday := Weekday{Day: day}
+		if ordinal := entry[:len(entry)-2]; ordinal != "" {
+			var err error
+			weekday.Ordinal, err = strconv.Atoi(ordinal)
+			if err != nil || weekday.Ordinal == 0 || weekday.Ordinal < -5 || weekday.Ordinal > 5 {
+				return nil, fmt.Errorf("%w: invalid BYDAY entry %q", ErrInvalidRule, entry)
+			}
+		}
+		if !slices.Contains(weekdays, weekday) {
+			weekdays = append(weekdays, weekday)
+		}
+	}
+	return weekdays, nil
+}
+
+// String formats the rule in its canonical form
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Frequency}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var entries []string
+		for _, weekday := range r.ByDay {
+			entry := weekdayName(weekday.Day)
+			if weekday.Ordinal != 0 {
+				entry = strconv.Itoa(weekday.Ordinal) + entry
+			}
+			entries = append(entries, entry)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(entries, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayouts[0]))
+	}
+	return strings.Join(parts, ";")
+}
+
+func weekdayName(day time.Weekday) string {
+	for name, weekday := range weekdayNames {
+		if weekday == day {
+			return name
+		}
+	}
+	return ""
+}
+
+// Occurrences returns up to limit occurrences of the series starting at start (the first occurrence, which isn't
+// returned). The remaining count of the rule includes the first occurrence.
+func (r Rule) Occurrences(start time.Time, limit int) []time.Time {
+	var occurrences []time.Time
+	remaining := r.Count - 1
+	startDate := dateOf(start)
+	horizon := start.Add(SearchHorizon)
+	for day := startDate.AddDate(0, 0, 1); len(occurrences) < limit; day = day.AddDate(0, 0, 1) {
+		if r.Count > 0 && remaining <= 0 {
+			break
+		}
+		occurrence := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(),
+			start.Nanosecond(), start.Location())
+		if occurrence.After(horizon) || (r.Until != nil && occurrence.After(*r.Until)) {
+			break
+		}
+		if !r.matches(startDate, day) {
+			continue
+		}
+		occurrences = append(occurrences, occurrence)
+		remaining--
+	}
+	return occurrences
+}
+
+// Next returns the occurrence following the first one at start, false when the series ends with start
+func (r Rule) Next(start time.Time) (time.Time, bool) {
+	occurrences := r.Occurrences(start, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+	return occurrences[0], true
+}
+
+// matches checks whether the passed day (date at midnight UTC) belongs to the series starting at startDate
+func (r Rule) matches(startDate time.Time, day time.Time) bool {
+	switch r.Frequency {
+	case FrequencyDaily:
+		days := int(day.Sub(startDate).Hours() / 24)
+		return days%r.Interval == 0 && r.matchesWeekday(day)
+	case FrequencyWeekly:
+		weeks := int(weekStart(day).Sub(weekStart(startDate)).Hours() / (24 * 7))
+		if weeks%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == startDate.Weekday()
+		}
+		return r.matchesWeekday(day)
+	case FrequencyMonthly:
+		months := (day.Year()-startDate.Year())*12 + int(day.Month()) - int(startDate.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			// months without this day are skipped
+			return day.Day() == startDate.Day()
+		}
+		return r.matchesWeekday(day)
+	}
+	return false
+}
+
+// matchesWeekday checks the day against BYDAY (an empty BYDAY matches every day)
+func (r Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, weekday := range r.ByDay {
+		if day.Weekday() != weekday.Day {
+			continue
+		}
+		switch {
+		case weekday.Ordinal == 0:
+			return true
+		case weekday.Ordinal > 0 && (day.Day()-1)/7+1 == weekday.Ordinal:
+			return true
+		case weekday.Ordinal < 0 && (daysInMonth(day)-day.Day())/7+1 == -weekday.Ordinal:
+			return true
+		}
+	}
+	return false
+}
+
+// dateOf returns the calendar date of the point in time at midnight UTC
+func dateOf(value time.Time) time.Time {
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart returns the monday of the week of the passed date (weeks start on monday like the iCalendar default)
+func weekStart(date time.Time) time.Time {
+	return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+}
+
+func daysInMonth(date time.Time) int {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,175 @@
+package recurrence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// date returns the passed day at 09:30 UTC
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	until := time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC)
+	untilTime := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  Rule
+	}{
+		{"FREQ=DAILY", Rule{Frequency: FrequencyDaily, Interval: 1}},
+		{" rrule:freq=weekly;interval=2 ", Rule{Frequency: FrequencyWeekly, Interval: 2}},
+		{"FREQ=WEEKLY;BYDAY=MO,TH,MO", Rule{Frequency: FrequencyWeekly, Interval: 1,
+			ByDay: []Weekday{{Day: time.Monday}, {Day: time.Thursday}}}},
+		{"FREQ=MONTHLY;BYDAY=2TU,-1FR;COUNT=5", Rule{Frequency: FrequencyMonthly, Interval: 1,
+			ByDay: []Weekday{{Ordinal: 2, Day: time.Tuesday}, {Ordinal: -1, Day: time.Friday}}, Count: 5}},
+		// a date includes the whole day
+		{"FREQ=DAILY;UNTIL=20240331", Rule{Frequency: FrequencyDaily, Interval: 1, Until: &until}},
+		{"FREQ=DAILY;UNTIL=20240331T120000Z", Rule{Frequency: FrequencyDaily, Interval: 1, Until: &untilTime}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.value)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	for _, value := range []string{"", "RRULE:", "INTERVAL=2", "FREQ=YEARLY", "FREQ=DAILY;FREQ=WEEKLY", "FREQ",
+		"FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;INTERVAL=x", "FREQ=DAILY;COUNT=0", "FREQ=DAILY;UNTIL=2024-03-31",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240331", "FREQ=WEEKLY;BYDAY=1MO", "FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=0MO", "FREQ=MONTHLY;BYDAY=XX", "FREQ=MONTHLY;BYDAY=M", "FREQ=DAILY;BYMONTH=1"} {
+		_, err := Parse(value)
+		if !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) = %v, want an invalid rule", value, err)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"FREQ=DAILY;INTERVAL=1", "FREQ=DAILY"},
+		{"rrule:freq=weekly;byday=mo,fr;interval=2", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{"FREQ=MONTHLY;BYDAY=-1FR,2SU;COUNT=3", "FREQ=MONTHLY;BYDAY=-1FR,2SU;COUNT=3"},
+		{"FREQ=DAILY;UNTIL=20240331T120000Z", "FREQ=DAILY;UNTIL=20240331T120000Z"},
+		{"FREQ=DAILY;UNTIL=20240331", "FREQ=DAILY;UNTIL=20240331T235959Z"},
+	}
+	for _, test := range tests {
+		rule, err := Parse(test.value)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", test.value, err)
+		}
+		if got := rule.String(); got != test.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.value, got, test.want)
+		}
+		reparsed, err := Parse(rule.String())
+		if err != nil || reparsed.String() != rule.String() {
+			t.Errorf("%q doesn't parse to itself: %q (%v)", rule.String(), reparsed.String(), err)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		limit int
+		want  []time.Time
+	}{
+		{"daily", "FREQ=DAILY", date(2024, 2, 27), 4,
+			[]time.Time{date(2024, 2, 28), date(2024, 2, 29), date(2024, 3, 1), date(2024, 3, 2)}},
+		{"daily interval", "FREQ=DAILY;INTERVAL=3", date(2024, 1, 30), 2,
+			[]time.Time{date(2024, 2, 2), date(2024, 2, 5)}},
+		{"daily on weekdays", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", date(2024, 3, 8), 2, // a friday
+			[]time.Time{date(2024, 3, 11), date(2024, 3, 12)}},
+		{"weekly on the start weekday", "FREQ=WEEKLY", date(2024, 3, 6), 2,
+			[]time.Time{date(2024, 3, 13), date(2024, 3, 20)}},
+		{"weekly interval by day", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", date(2024, 3, 5), 4, // a tuesday
+			[]time.Time{date(2024, 3, 7), date(2024, 3, 18), date(2024, 3, 21), date(2024, 4, 1)}},
+		{"monthly on the start day", "FREQ=MONTHLY", date(2024, 1, 15), 2,
+			[]time.Time{date(2024, 2, 15), date(2024, 3, 15)}},
+		{"monthly skips months without the start day", "FREQ=MONTHLY", date(2024, 1, 31), 3,
+			[]time.Time{date(2024, 3, 31), date(2024, 5, 31), date(2024, 7, 31)}},
+		{"monthly interval", "FREQ=MONTHLY;INTERVAL=12", date(2024, 2, 29), 1, []time.Time{date(2028, 2, 29)}},
+		{"monthly on the second tuesday", "FREQ=MONTHLY;BYDAY=2TU", date(2024, 1, 9), 3,
+			[]time.Time{date(2024, 2, 13), date(2024, 3, 12), date(2024, 4, 9)}},
+		{"monthly on the last friday", "FREQ=MONTHLY;BYDAY=-1FR", date(2024, 1, 26), 3,
+			[]time.Time{date(2024, 2, 23), date(2024, 3, 29), date(2024, 4, 26)}},
+		{"monthly on the fifth thursday", "FREQ=MONTHLY;BYDAY=5TH", date(2024, 2, 29), 2,
+			[]time.Time{date(2024, 5, 30), date(2024, 8, 29)}},
+		{"count includes the start", "FREQ=DAILY;COUNT=3", date(2024, 3, 1), 10,
+			[]time.Time{date(2024, 3, 2), date(2024, 3, 3)}},
+		{"until date includes its day", "FREQ=DAILY;UNTIL=20240303", date(2024, 3, 1), 10,
+			[]time.Time{date(2024, 3, 2), date(2024, 3, 3)}},
+		{"until time excludes later occurrences", "FREQ=DAILY;UNTIL=20240303T090000Z", date(2024, 3, 1), 10,
+			[]time.Time{date(2024, 3, 2)}},
+		{"never matching rule ends at the horizon", "FREQ=DAILY;INTERVAL=7;BYDAY=TU", date(2024, 3, 4), 1, nil},
+	}
+	for _, test := range tests {
+		rule, err := Parse(test.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := rule.Occurrences(test.start, test.limit); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: occurrences %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestOccurrencesKeepTimeOfDayAndLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	rule, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the wall clock time stays the same across the change to daylight saving time
+	start := time.Date(2024, 3, 30, 8, 0, 0, 0, berlin)
+	next, ok := rule.Next(start)
+	if !ok || !next.Equal(time.Date(2024, 3, 31, 8, 0, 0, 0, berlin)) {
+		t.Errorf("next occurrence %v (%t), want 2024-03-31 08:00 in Berlin", next, ok)
+	}
+}
+
+func TestNextDecrementsCountUntilTheSeriesEnds(t *testing.T) {
+	// like the todos of a series: every next todo gets the rule with the count decremented
+	rule, err := Parse("FREQ=WEEKLY;COUNT=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := date(2024, 3, 4)
+	var got []time.Time
+	for {
+		next, ok := rule.Next(start)
+		if !ok {
+			break
+		}
+		got = append(got, next)
+		rule.Count--
+		rule, err = Parse(rule.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		start = next
+	}
+	if want := []time.Time{date(2024, 3, 11), date(2024, 3, 18)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("occurrences %v, want %v", got, want)
+	}
+	if rule.Count != 1 {
+		t.Errorf("count %d of the last todo, want 1", rule.Count)
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"todo-rest-backend/models/recurrence"
+	"todo-rest-backend/models/todo"
+)
+
+// OccurrencesDefaultCount number of occurrences previewed when no count is passed
+const OccurrencesDefaultCount = 5
+
+// OccurrencesMaxCount maximum number of occurrences which can be previewed at once
+const OccurrencesMaxCount = 100
+
+// OccurrencesMeta meta information of the occurrences preview
+type OccurrencesMeta struct {
+	Recurrence string     `json:"recurrence"`
+	Start      *time.Time `json:"start,omitempty"` // Occurrence of the todo itself the series continues from
+}
+
+// normalizeRecurrence returns the canonical form of the recurrence rule, invalid rules are kept as they are
+// (they are reported by ValidateTodo)
+func normalizeRecurrence(value string) string {
+	if value == "" {
+		return ""
+	}
+	rule, err := recurrence.Parse(value)
+	if err != nil {
+		return value
+	}
+	return rule.String()
+}
+
+// recurrenceStart returns the occurrence of the todo itself: its due date or, without one, its creation time
+func recurrenceStart(recurringTodo todo.Todo) *time.Time {
+	if recurringTodo.DueAt != nil {
+		return recurringTodo.DueAt
+	}
+	return recurringTodo.CreatedAt
+}
+
+// ReadOccurrences returns up to count occurrences following the one of the todo with passed id
+func ReadOccurrences(id string, count int) ([]time.Time, OccurrencesMeta, error) {
+	todoRead, err := ReadTodoById(id)
+	if err != nil {
+		return nil, OccurrencesMeta{}, err
+	}
+	occurrences := []time.Time{}
+	meta := OccurrencesMeta{Recurrence: todoRead.Recurrence, Start: recurrenceStart(todoRead)}
+	if todoRead.Recurrence == "" || meta.Start == nil {
+		return occurrences, meta, nil
+	}
+	rule, err := recurrence.Parse(todoRead.Recurrence)
+	if err != nil {
+		return nil, OccurrencesMeta{}, err
+	}
+	return append(occurrences, rule.Occurrences(meta.Start.UTC(), count)...), meta, nil
+}
+
+// continueSeries hands the recurrence over from a todo terminated by the change from the current to the changed todo
+// to the todo of its next occurrence: series is set to the changed todo with its recurrence to create the next
+// occurrence from, and the changed todo loses its recurrence. So terminating the todo again after reopening it doesn't
+// create the next occurrence a second time.
+func continueSeries(currentTodo todo.Todo, changedTodo *todo.Todo, series *todo.Todo) {
+	if !changedTodo.Terminated || currentTodo.Terminated || changedTodo.Recurrence == "" {
+		return
+	}
+	*series = *changedTodo
+	changedTodo.Recurrence = ""
+}
+
+// continueSeriesAfterChange creates the next occurrence of the passed series (see continueSeries) when a stored change
+// terminated a recurring todo. The change can't be taken back anymore, so a failure is logged instead of failing it.
+func continueSeriesAfterChange(ctx context.Context, series todo.Todo) {
+	if series.Recurrence == "" {
+		return
+	}
+	err := createNextOccurrence(ctx, series)
+	if err != nil {
+		log.Println("creating the next occurrence of todo", series.Id, "failed:", err)
+	}
+}
+
+// createNextOccurrence creates the todo for the occurrence following the one of the passed terminated todo.
+// Nothing is created when the series ends with the passed todo.
+func createNextOccurrence(ctx context.Context, terminatedTodo todo.Todo) error {
+	start := recurrenceStart(terminatedTodo)
+	if terminatedTodo.Recurrence == "" || start == nil {
+		return nil
+	}
+	rule, err := recurrence.Parse(terminatedTodo.Recurrence)
+	if err != nil {
+		return err
+	}
+	next, ok := rule.Next(start.UTC())
+	if !ok {
+		return nil
+	}
+	if rule.Count > 0 {
+		// the count of the next todo includes its own occurrence
+		rule.Count--
+	}
+
+	nextTodo := todo.Todo{
+		Title:       terminatedTodo.Title,
+		Description: terminatedTodo.Description,
+		DueAt:       &next,
+		Priority:    terminatedTodo.Priority,
+		Tags:        terminatedTodo.Tags,
+		ListId:      terminatedTodo.ListId,
+		ParentId:    terminatedTodo.ParentId,
+		Recurrence:  rule.String(),
+	}
+	// the list may have been deleted in the meantime
+	if !listExists(nextTodo.ListId) {
+		nextTodo.ListId = ""
+	}
+	_, err = CreateTodo(ctx, nextTodo)
+	if errors.Is(err, ErrInvalidParent) {
+		// the parent has been deleted in the meantime
+		nextTodo.ParentId = ""
+		_, err = CreateTodo(ctx, nextTodo)
+	}
+	return err
+}
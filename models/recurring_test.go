@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// openTodos returns the todos not terminated
+func openTodos(t *testing.T) []todo.Todo {
+	t.Helper()
+	todos, err := ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var open []todo.Todo
+	for _, currentTodo := range todos {
+		if !currentTodo.Terminated {
+			open = append(open, currentTodo)
+		}
+	}
+	return open
+}
+
+func TestTerminatingOccurrencesCountsDownTheSeries(t *testing.T) {
+	setUpRevisions(t)
+	ctx := context.Background()
+	dueAt := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	_, err := CreateTodo(ctx, todo.Todo{Title: "series", Description: "three times", DueAt: &dueAt,
+		Recurrence: "FREQ=WEEKLY;COUNT=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRules := []string{"FREQ=WEEKLY;COUNT=2", "FREQ=WEEKLY;COUNT=1"}
+	for _, wantRule := range wantRules {
+		open := openTodos(t)
+		if len(open) != 1 {
+			t.Fatalf("%d open todos, want the current occurrence only", len(open))
+		}
+		current := open[0]
+		current.Terminated = true
+		_, err = UpdateTodoById(ctx, current.Id, current, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		open = openTodos(t)
+		dueAt = dueAt.AddDate(0, 0, 7)
+		if len(open) != 1 || open[0].Recurrence != wantRule || !open[0].DueAt.Equal(dueAt) {
+			t.Fatalf("open todos %+v, want the next occurrence due %v with %s", open, dueAt, wantRule)
+		}
+	}
+
+	// the last occurrence ends the series
+	last := openTodos(t)[0]
+	last.Terminated = true
+	_, err = UpdateTodoById(ctx, last.Id, last, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open := openTodos(t); len(open) != 0 {
+		t.Errorf("open todos %+v after the last occurrence, want none", open)
+	}
+}
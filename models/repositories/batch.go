@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"todo-rest-backend/models/todo"
+)
+
+// ErrBatchAborted is the result of the operations of an all-or-nothing batch which weren't applied
+// because another operation failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchOperationKind kind of a batch operation
+type BatchOperationKind string
+
+// Kinds of batch operations
+const (
+	BatchCreate BatchOperationKind = "create"
+	BatchPatch  BatchOperationKind = "patch"
+	BatchDelete BatchOperationKind = "delete"
+)
+
+// BatchOperation single operation of a batch
+type BatchOperation struct {
+	Kind  BatchOperationKind
+	Id    string                             // todo to patch or delete
+	Todo  todo.Todo                          // todo to create; for deletes the version has to match when it isn't 0
+	Patch func(todo.Todo) (todo.Todo, error) // applied to the stored todo like PatchTodoById
+}
+
+// BatchResult result of a batch operation: the created, patched or deleted todo or the error
+type BatchResult struct {
+	Todo todo.Todo
+	Err  error
+}
+
+// ApplyBatchToTodos applies the operations in order to the passed todos for repositories keeping all todos at hand.
+// It returns the resulting todos, the result of every operation and whether the todos changed.
+// newId returns the id of a created todo. In atomic mode the passed todos are returned when an operation fails.
+func ApplyBatchToTodos(todos []todo.Todo, operations []BatchOperation, atomic bool,
+	newId func() (string, error)) ([]todo.Todo, []BatchResult, bool) {
+	working := slices.Clone(todos)
+	results := make([]BatchResult, len(operations))
+	failed := false
+	changed := false
+	for index, operation := range operations {
+		var result BatchResult
+		working, result = applyBatchOperation(working, operation, newId)
+		results[index] = result
+		if result.Err != nil {
+			failed = true
+			continue
+		}
+		changed = true
+	}
+
+	if atomic && failed {
+		return todos, AbortBatch(results), false
+	}
+	return working, results, changed
+}
+
+// AbortBatch returns the results of an all-or-nothing batch in which an operation failed:
+// the successful operations get ErrBatchAborted
+func AbortBatch(results []BatchResult) []BatchResult {
+	for index, result := range results {
+		if result.Err == nil {
+			results[index] = BatchResult{Err: ErrBatchAborted}
+		}
+	}
+	return results
+}
+
+func applyBatchOperation(todos []todo.Todo, operation BatchOperation, newId func() (string, error)) ([]todo.Todo, BatchResult) {
+	if operation.Kind == BatchCreate {
+		id, err := newId()
+		if err != nil {
+			return todos, BatchResult{Err: err}
+		}
+		if slices.ContainsFunc(todos, func(currentTodo todo.Todo) bool { return currentTodo.Id == id }) {
+			return todos, BatchResult{Err: fmt.Errorf("%w: generated id %s already exists", ErrConflict, id)}
+		}
+		todoCreated := operation.Todo
+		todoCreated.Id = id
+		return append(todos, todoCreated), BatchResult{Todo: todoCreated}
+	}
+
+	index := slices.IndexFunc(todos, func(currentTodo todo.Todo) bool { return currentTodo.Id == operation.Id })
+	if index < 0 {
+		return todos, BatchResult{Err: fmt.Errorf("%w: %s", ErrNotFound, operation.Id)}
+	}
+	switch operation.Kind {
+	case BatchPatch:
+		todoPatched, err := operation.Patch(todos[index])
+		if err != nil {
+			return todos, BatchResult{Err: err}
+		}
+		todoPatched.Id = operation.Id
+		todos[index] = todoPatched
+		return todos, BatchResult{Todo: todoPatched}
+	case BatchDelete:
+		deletedTodo := todos[index]
+		if operation.Todo.Version != 0 && operation.Todo.Version != deletedTodo.Version {
+			return todos, BatchResult{Err: ErrVersionMismatch}
+		}
+		return slices.Delete(todos, index, index+1), BatchResult{Todo: deletedTodo}
+	}
+	return todos, BatchResult{Err: fmt.Errorf("unknown batch operation %q", operation.Kind)}
+}
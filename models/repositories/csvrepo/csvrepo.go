@@ -2,12 +2,17 @@
 package csvrepo
 
 import (
+	"bytes"
 	"encoding/csv"
-	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
 	"todo-rest-backend/models/todo"
 	"todo-rest-backend/models/utils"
 )
@@ -15,8 +20,18 @@ import (
 // FileName for storage
 const FileName = "data.csv"
 
+// LockFileName of the advisory lock file serializing writers across processes
+const LockFileName = FileName + ".lock"
+
+// SequenceFileName of the file keeping the last handed out id sequence value
+const SequenceFileName = FileName + ".sequence"
+
+// writeMutex serializes writers of the todo, list, webhook and revision files within the process
+var writeMutex sync.Mutex
+
 // CsvFileTodoRepository type
 type CsvFileTodoRepository struct {
+	IdGenerator idgen.Generator
 }
 
 // Initialize initializes the repository
@@ -31,11 +46,11 @@ func (c CsvFileTodoRepository) Initialize() error {
 		var file *os.File
 		file, err = os.Create(FileName)
 		if err != nil {
-			return err
+			return repositories.StorageError(err)
 		}
 		defer utils.CloseFileAndHandleError(file, &err)
 	}
-	return err
+	return repositories.StorageError(err)
 }
 
 // ReadTodos returns todo's stored in file
@@ -46,13 +61,15 @@ func (c CsvFileTodoRepository) ReadTodos() ([]todo.Todo, error) {
 func readDataFromFile() ([]todo.Todo, error) {
 	file, err := os.Open(FileName)
 	if err != nil {
-		return nil, err
+		return nil, repositories.StorageError(err)
 	}
 
 	defer utils.CloseFileAndHandleError(file, &err)
 
 	var readTodos []todo.Todo
 	csvReader := csv.NewReader(file)
+	// Rows written by older versions have fewer columns
+	csvReader.FieldsPerRecord = -1
 	for {
 		var records []string
 		records, err = csvReader.Read()
@@ -61,12 +78,17 @@ func readDataFromFile() ([]todo.Todo, error) {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, repositories.StorageError(err)
 		}
-		readTodos = append(readTodos, parseTodoData(records))
+		var todoParsed todo.Todo
+		todoParsed, err = parseTodoData(records)
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		readTodos = append(readTodos, todoParsed)
 	}
 
-	return readTodos, err
+	return readTodos, repositories.StorageError(err)
 }
 
 // ReadTodoById returns todo with passed id when existing
@@ -82,122 +104,372 @@ func (c CsvFileTodoRepository) ReadTodoById(id string) (todo.Todo, error) {
 		}
 	}
 
-	err = errors.New("id not found")
+	err = fmt.Errorf("id %w", repositories.ErrNotFound)
 	return todo.Todo{}, err
 }
 
 // CreateTodo stores the passed todo in the file and returns the stored todo
 func (c CsvFileTodoRepository) CreateTodo(todoToCreate todo.Todo) (todo.Todo, error) {
-	todoCount, err := utils.GetLineCount(FileName)
+	err := withWriteLock(func() error {
+		todos, err := readDataFromFile()
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		for _, currentTodo := range todos {
+			ids = append(ids, currentTodo.Id)
+		}
+		id, err := idgen.OrDefault(c.IdGenerator).NewId(func() (uint64, error) {
+			return nextSequence(SequenceFileName, ids)
+		})
+		if err != nil {
+			return err
+		}
+		for _, currentTodo := range todos {
+			if id == currentTodo.Id {
+				return fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+			}
+		}
+
+		todoToCreate.Id = id
+		return writeDataToFile(append(todos, todoToCreate))
+	})
 	if err != nil {
 		return todo.Todo{}, err
 	}
 
-	file, err := os.OpenFile(FileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	return todoToCreate, nil
+}
+
+// nextSequence increments and persists the sequence stored in the passed sequence file.
+// The caller must hold the write lock.
+func nextSequence(sequenceFileName string, ids []string) (uint64, error) {
+	lastSequence, err := readSequence(sequenceFileName, ids)
 	if err != nil {
-		return todo.Todo{}, err
+		return 0, err
 	}
-	defer utils.CloseFileAndHandleError(file, &err)
 
-	todoToCreate.Id = strconv.Itoa(todoCount + 1)
+	lastSequence++
+	err = writeSequence(sequenceFileName, lastSequence)
+	if err != nil {
+		return 0, err
+	}
 
-	writer := csv.NewWriter(file)
-	err = writer.Write(todoToCreate.Serialize())
+	return lastSequence, nil
+}
+
+// readSequence returns the last handed out sequence value stored in the passed sequence file.
+// Without sequence file (e.g. data written by older versions) the sequence continues after the highest numeric id.
+func readSequence(sequenceFileName string, ids []string) (uint64, error) {
+	var lastSequence uint64
+	content, err := os.ReadFile(sequenceFileName)
+	if os.IsNotExist(err) {
+		for _, id := range ids {
+			idAsUint, parseErr := strconv.ParseUint(id, 10, 64)
+			if parseErr == nil && idAsUint > lastSequence {
+				lastSequence = idAsUint
+			}
+		}
+	} else if err != nil {
+		return 0, repositories.StorageError(err)
+	} else {
+		lastSequence, err = strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil {
+			return 0, repositories.StorageError(fmt.Errorf("corrupt sequence file %s: %w", sequenceFileName, err))
+		}
+	}
+
+	return lastSequence, nil
+}
+
+// writeSequence atomically persists the passed last handed out sequence value in the passed sequence file
+func writeSequence(sequenceFileName string, lastSequence uint64) error {
+	err := utils.WriteFileAtomically(sequenceFileName, []byte(strconv.FormatUint(lastSequence, 10)))
 	if err != nil {
-		return todo.Todo{}, err
+		return repositories.StorageError(err)
 	}
+	return nil
+}
 
+// withWriteLock serializes the passed mutation of the todo, list, webhook or revision file against other goroutines
+// (mutex) and other processes (advisory lock on LockFileName)
+func withWriteLock(mutate func() error) (err error) {
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+
+	lockFile, err := utils.LockFile(LockFileName)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	defer func() {
+		unlockErr := utils.UnlockFile(lockFile)
+		if err == nil {
+			err = repositories.StorageError(unlockErr)
+		}
+	}()
+
+	return mutate()
+}
+
+// writeDataToFile atomically replaces the file content with the passed todos
+func writeDataToFile(todos []todo.Todo) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, currentTodo := range todos {
+		err := writer.Write(currentTodo.Serialize())
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+	}
 	writer.Flush()
+	err := writer.Error()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
 
-	return todoToCreate, err
+	return repositories.StorageError(utils.WriteFileAtomically(FileName, buffer.Bytes()))
 }
 
-func parseTodoData(rec []string) todo.Todo {
+// MinColumnCount of a csv row (format of the first version: id, title, description, terminated).
+// Later versions append deletedAt, version, dueAt, priority, createdAt, updatedAt, completedAt, tags, listId, parentId, blockedBy and recurrence.
+const MinColumnCount = 4
+
+func parseTodoData(rec []string) (todo.Todo, error) {
+	if len(rec) < MinColumnCount {
+		return todo.Todo{}, fmt.Errorf("invalid row with %d columns", len(rec))
+	}
+
 	id := rec[0]
 	title := rec[1]
 	description := rec[2]
 	terminated := utils.ToBool(rec[3])
 
 	todoParsed := todo.Todo{Id: id, Title: title, Description: description, Terminated: terminated}
-	return todoParsed
-}
 
-// UpdateTodoById updates the passed todo by id in csv and returns the updated todo
-func (c *CsvFileTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
-	// Create todo slice based on file
-	todos, err := c.ReadTodos()
-	if err != nil {
-		return todo.Todo{}, err
+	// Optional columns added later
+	var err error
+	todoParsed.Priority = todo.PriorityNormal
+	if len(rec) > 4 {
+		todoParsed.DeletedAt, err = parseTimeColumn(rec[4], "deletedAt", id)
+		if err != nil {
+			return todo.Todo{}, err
+		}
 	}
-
-	// Update todo in slice
-	itemFound := false
-	for index, currentTodo := range todos {
-		if id == currentTodo.Id {
-			todoUpdate.Id = id
-			todos[index] = todoUpdate
-			itemFound = true
-			break
+	if len(rec) > 5 {
+		todoParsed.Version, err = strconv.ParseInt(rec[5], 10, 64)
+		if err != nil {
+			return todo.Todo{}, fmt.Errorf("invalid version of todo %s: %w", id, err)
 		}
 	}
-
-	if itemFound == false {
-		return todo.Todo{}, fmt.Errorf("item with id %s not found. Updating not possible", id)
+	// rows written before versions existed are in their first version, so that preconditions are checked for them too
+	todoParsed.Version = max(todoParsed.Version, 1)
+	if len(rec) > 10 {
+		todoParsed.DueAt, err = parseTimeColumn(rec[6], "dueAt", id)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		todoParsed.Priority = todo.Priority(rec[7])
+		todoParsed.CreatedAt, err = parseTimeColumn(rec[8], "createdAt", id)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		todoParsed.UpdatedAt, err = parseTimeColumn(rec[9], "updatedAt", id)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		todoParsed.CompletedAt, err = parseTimeColumn(rec[10], "completedAt", id)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+	}
+	todoParsed.Tags = []string{}
+	if len(rec) > 11 {
+		todoParsed.Tags, err = todo.ParseTags(rec[11])
+		if err != nil {
+			return todo.Todo{}, fmt.Errorf("invalid tags of todo %s: %w", id, err)
+		}
+	}
+	if len(rec) > 12 {
+		todoParsed.ListId = rec[12]
+	}
+	if len(rec) > 13 {
+		todoParsed.ParentId = rec[13]
+	}
+	if len(rec) > 14 {
+		todoParsed.BlockedBy, err = todo.ParseIds(rec[14])
+		if err != nil {
+			return todo.Todo{}, fmt.Errorf("invalid blockedBy of todo %s: %w", id, err)
+		}
+	}
+	if len(rec) > 15 {
+		todoParsed.Recurrence = rec[15]
 	}
 
-	// Clear file content
-	err = utils.ClearFile(FileName)
+	return todoParsed, nil
+}
+
+func parseTimeColumn(value string, name string, id string) (*time.Time, error) {
+	parsed, err := todo.ParseTime(value)
 	if err != nil {
-		return todo.Todo{}, err
+		return nil, fmt.Errorf("invalid %s of todo %s: %w", name, id, err)
 	}
+	return parsed, nil
+}
 
-	// Create todos from updated slice in file
-	for _, currentTodo := range todos {
-		_, err = c.CreateTodo(currentTodo)
+// UpdateTodoById updates the passed todo by id in csv and returns the updated todo
+func (c *CsvFileTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
+	err := withWriteLock(func() error {
+		// Create todo slice based on file
+		todos, err := readDataFromFile()
 		if err != nil {
-			return todo.Todo{}, err
+			return err
 		}
+
+		// Update todo in slice
+		itemFound := false
+		for index, currentTodo := range todos {
+			if id == currentTodo.Id {
+				todoUpdate.Id = id
+				todos[index] = todoUpdate
+				itemFound = true
+				break
+			}
+		}
+
+		if itemFound == false {
+			return fmt.Errorf("item with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+		}
+
+		// Replace file content by updated slice
+		return writeDataToFile(todos)
+	})
+	if err != nil {
+		return todo.Todo{}, err
 	}
 
 	return todoUpdate, nil
 }
-func (c *CsvFileTodoRepository) DeleteTodoById(id string, _ todo.Todo) (todo.Todo, error) {
-	// Todos aus Datei lesen
-	todos, err := c.ReadTodos()
+
+// PatchTodoById applies the passed patch function to the todo with passed id in csv and returns the patched todo
+func (c *CsvFileTodoRepository) PatchTodoById(id string, patch func(todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	var todoPatched todo.Todo
+	err := withWriteLock(func() error {
+		todos, err := readDataFromFile()
+		if err != nil {
+			return err
+		}
+
+		for index, currentTodo := range todos {
+			if id == currentTodo.Id {
+				todoPatched, err = patch(currentTodo)
+				if err != nil {
+					return err
+				}
+				todoPatched.Id = id
+				todos[index] = todoPatched
+				return writeDataToFile(todos)
+			}
+		}
+
+		return fmt.Errorf("item with id %s %w. Patching not possible", id, repositories.ErrNotFound)
+	})
 	if err != nil {
-		return todo.Todo{}, fmt.Errorf("error reading todos: %w", err)
+		return todo.Todo{}, err
 	}
 
-	// Todo finden und speichern bevor es gelöscht wird
+	return todoPatched, nil
+}
+
+// DeleteTodoById deletes the todo by id in csv and returns the deleted todo
+func (c *CsvFileTodoRepository) DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
 	var deletedTodo todo.Todo
-	var remainingTodos []todo.Todo
-	itemFound := false
+	err := withWriteLock(func() error {
+		// Todos aus Datei lesen
+		todos, err := readDataFromFile()
+		if err != nil {
+			return fmt.Errorf("error reading todos: %w", err)
+		}
 
-	for _, currentTodo := range todos {
-		if id == currentTodo.Id {
-			deletedTodo = currentTodo
-			itemFound = true
-			continue
+		// Todo finden und speichern bevor es gelöscht wird
+		var remainingTodos []todo.Todo
+		itemFound := false
+
+		for _, currentTodo := range todos {
+			if id == currentTodo.Id {
+				deletedTodo = currentTodo
+				itemFound = true
+				continue
+			}
+			remainingTodos = append(remainingTodos, currentTodo)
 		}
-		remainingTodos = append(remainingTodos, currentTodo)
-	}
 
-	if !itemFound {
-		return todo.Todo{}, fmt.Errorf("todo with ID %s not found", id)
-	}
+		if !itemFound {
+			return fmt.Errorf("todo with ID %s %w", id, repositories.ErrNotFound)
+		}
+		if todoDelete.Version != 0 && todoDelete.Version != deletedTodo.Version {
+			return repositories.ErrVersionMismatch
+		}
 
-	// Datei leeren
-	if err := utils.ClearFile(FileName); err != nil {
-		return todo.Todo{}, fmt.Errorf("error clearing file: %w", err)
+		// Verbleibende Todos atomar zurückschreiben
+		if err := writeDataToFile(remainingTodos); err != nil {
+			return fmt.Errorf("error rewriting todos: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return todo.Todo{}, err
 	}
 
-	// Verbleibende Todos zurückschreiben
-	for _, t := range remainingTodos {
-		// Verwende direkt die CreateTodo Methode um die verbleibenden Todos zu speichern
-		if _, err := c.CreateTodo(t); err != nil {
-			return todo.Todo{}, fmt.Errorf("error rewriting todos: %w", err)
+	return deletedTodo, nil
+}
+
+// ApplyBatch applies the batch operations to the todos in csv, rewriting the file once, and returns their results
+func (c *CsvFileTodoRepository) ApplyBatch(operations []repositories.BatchOperation, atomic bool) ([]repositories.BatchResult, error) {
+	var results []repositories.BatchResult
+	err := withWriteLock(func() error {
+		todos, err := readDataFromFile()
+		if err != nil {
+			return err
 		}
+
+		// the ids of the created todos are reserved in memory and the sequence is written once for the batch
+		var ids []string
+		for _, currentTodo := range todos {
+			ids = append(ids, currentTodo.Id)
+		}
+		var lastSequence, reservedSequence uint64
+		var sequenceRead bool
+		var changed bool
+		todos, results, changed = repositories.ApplyBatchToTodos(todos, operations, atomic, func() (string, error) {
+			return idgen.OrDefault(c.IdGenerator).NewId(func() (uint64, error) {
+				if !sequenceRead {
+					lastSequence, err = readSequence(SequenceFileName, ids)
+					if err != nil {
+						return 0, err
+					}
+					reservedSequence = lastSequence
+					sequenceRead = true
+				}
+				reservedSequence++
+				return reservedSequence, nil
+			})
+		})
+		if !changed {
+			return nil
+		}
+
+		if reservedSequence > lastSequence {
+			err = writeSequence(SequenceFileName, reservedSequence)
+			if err != nil {
+				return err
+			}
+		}
+		return writeDataToFile(todos)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return deletedTodo, nil
+	return results, nil
 }
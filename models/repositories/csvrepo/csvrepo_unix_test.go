@@ -0,0 +1,332 @@
+//go:build unix
+
+package csvrepo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/utils"
+)
+
+// helperModeVariable environment variable selecting the role of the test binary started as second process
+const helperModeVariable = "CSVREPO_TEST_HELPER"
+
+// TestHelperProcess is no test, it is the second process of the tests below
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperModeVariable)
+	if mode == "" {
+		t.Skip("helper process only")
+	}
+	repository := &CsvFileTodoRepository{}
+
+	switch mode {
+	case "lock":
+		// holds the write lock until stdin is closed
+		lockFile, err := utils.LockFile(LockFileName)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("locked")
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+		_ = utils.UnlockFile(lockFile)
+	case "create":
+		// creates the passed number of todos, 0 for creating todos until killed
+		count, _ := strconv.Atoi(os.Getenv("CSVREPO_TEST_COUNT"))
+		for created := 0; count == 0 || created < count; created++ {
+			_, err := repository.CreateTodo(todo.Todo{Title: "helper", Description: strings.Repeat("x", 512)})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+	}
+	os.Exit(0)
+}
+
+// startHelper starts the test binary as second process in the current directory
+func startHelper(t *testing.T, mode string, count int) (*exec.Cmd, *bufio.Reader, func()) {
+	t.Helper()
+	command := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	command.Env = append(os.Environ(), helperModeVariable+"="+mode, "CSVREPO_TEST_COUNT="+strconv.Itoa(count))
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = command.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = command.Process.Kill()
+		_ = command.Wait()
+	})
+	return command, bufio.NewReader(stdout), func() { _ = stdin.Close() }
+}
+
+// inTempDir changes into a new temporary directory for the files of the repository
+func inTempDir(t *testing.T) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(previous)
+	})
+}
+
+// newRepository returns an initialized repository with the passed number of todos
+func newRepository(t *testing.T, count int) *CsvFileTodoRepository {
+	t.Helper()
+	repository := &CsvFileTodoRepository{}
+	err := repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for created := 0; created < count; created++ {
+		_, err = repository.CreateTodo(todo.Todo{Title: "todo " + strconv.Itoa(created)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repository
+}
+
+// withFileSizeLimit runs the passed function with writes limited to the passed file size, so that a write
+// exceeding it stops in the middle (the Go runtime ignores SIGXFSZ, the write fails with EFBIG)
+func withFileSizeLimit(t *testing.T, size uint64, run func()) {
+	t.Helper()
+	var limit syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = syscall.Setrlimit(syscall.RLIMIT_FSIZE, &syscall.Rlimit{Cur: size, Max: limit.Max})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	run()
+}
+
+func readFile(t *testing.T, fileName string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestInterruptedSequenceWriteKeepsFiles(t *testing.T) {
+	inTempDir(t)
+	repository := newRepository(t, 3)
+	data := readFile(t, FileName)
+	sequence := readFile(t, SequenceFileName)
+
+	withFileSizeLimit(t, 0, func() {
+		_, err := repository.CreateTodo(todo.Todo{Title: "interrupted"})
+		if err == nil {
+			t.Error("create succeeded despite the failing write")
+		}
+	})
+
+	if !bytes.Equal(readFile(t, FileName), data) {
+		t.Errorf("%s changed by the interrupted create", FileName)
+	}
+	if !bytes.Equal(readFile(t, SequenceFileName), sequence) {
+		t.Errorf("%s changed by the interrupted create", SequenceFileName)
+	}
+}
+
+func TestInterruptedDataWriteKeepsFiles(t *testing.T) {
+	inTempDir(t)
+	repository := newRepository(t, 3)
+	data := readFile(t, FileName)
+
+	// the sequence fits, the data file with the new todo is cut off in the middle
+	withFileSizeLimit(t, uint64(len(data)/2), func() {
+		_, err := repository.CreateTodo(todo.Todo{Title: "interrupted"})
+		if err == nil {
+			t.Error("create succeeded despite the failing write")
+		}
+	})
+
+	if !bytes.Equal(readFile(t, FileName), data) {
+		t.Errorf("%s changed by the interrupted create", FileName)
+	}
+	// the id of the failed create isn't handed out again
+	if sequence := strings.TrimSpace(string(readFile(t, SequenceFileName))); sequence != "4" {
+		t.Errorf("sequence %q, want 4", sequence)
+	}
+	created, err := repository.CreateTodo(todo.Todo{Title: "after"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id != "5" {
+		t.Errorf("created todo %s, want 5", created.Id)
+	}
+	leftovers, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range leftovers {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestKilledWriterLeavesConsistentFiles(t *testing.T) {
+	inTempDir(t)
+	newRepository(t, 0)
+
+	for round := 0; round < 5; round++ {
+		command, _, _ := startHelper(t, "create", 0)
+		time.Sleep(time.Duration(20+round*30) * time.Millisecond)
+		_ = command.Process.Signal(syscall.SIGKILL)
+		_ = command.Wait()
+
+		todos, err := readDataFromFile()
+		if err != nil {
+			t.Fatalf("round %d: %s unreadable after the writer was killed: %v", round, FileName, err)
+		}
+		sequence, err := strconv.ParseUint(strings.TrimSpace(string(readFile(t, SequenceFileName))), 10, 64)
+		if err != nil {
+			t.Fatalf("round %d: %s unreadable after the writer was killed: %v", round, SequenceFileName, err)
+		}
+		seen := map[string]bool{}
+		for _, currentTodo := range todos {
+			id, _ := strconv.ParseUint(currentTodo.Id, 10, 64)
+			if seen[currentTodo.Id] || id > sequence {
+				t.Fatalf("round %d: todo %s duplicated or beyond the sequence %d", round, currentTodo.Id, sequence)
+			}
+			seen[currentTodo.Id] = true
+		}
+	}
+}
+
+func TestWriteLockBlocksOtherProcess(t *testing.T) {
+	inTempDir(t)
+	repository := newRepository(t, 1)
+
+	_, stdout, release := startHelper(t, "lock", 0)
+	line, err := stdout.ReadString('\n')
+	if err != nil || line != "locked\n" {
+		t.Fatalf("helper didn't take the lock: %q %v", line, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := repository.CreateTodo(todo.Todo{Title: "waiting"})
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		t.Fatalf("create didn't wait for the lock of the other process: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("create still waiting after the lock was released")
+	}
+}
+
+func TestConcurrentProcessesCreateUniqueIds(t *testing.T) {
+	inTempDir(t)
+	repository := newRepository(t, 0)
+	const count = 50
+
+	command, _, _ := startHelper(t, "create", count)
+	for created := 0; created < count; created++ {
+		_, err := repository.CreateTodo(todo.Todo{Title: "parent"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := command.Wait()
+	if err != nil {
+		t.Fatalf("helper failed: %v", err)
+	}
+
+	todos, err := repository.ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 2*count {
+		t.Errorf("%d todos stored, want %d (lost writes)", len(todos), 2*count)
+	}
+	seen := map[string]bool{}
+	for _, currentTodo := range todos {
+		if seen[currentTodo.Id] {
+			t.Errorf("id %s handed out twice", currentTodo.Id)
+		}
+		seen[currentTodo.Id] = true
+	}
+}
+
+func TestBatchReservesIdsOnce(t *testing.T) {
+	inTempDir(t)
+	repository := newRepository(t, 2)
+	data := readFile(t, FileName)
+	operations := []repositories.BatchOperation{
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "first"}},
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "second"}},
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "third"}},
+	}
+
+	// the sequence file fits, the data file doesn't: the batch fails without a partially written file
+	withFileSizeLimit(t, uint64(len(data)), func() {
+		_, err := repository.ApplyBatch(operations, true)
+		if err == nil {
+			t.Error("batch succeeded despite the failing write")
+		}
+	})
+	if !bytes.Equal(readFile(t, FileName), data) {
+		t.Errorf("%s changed by the interrupted batch", FileName)
+	}
+
+	results, err := repository.ApplyBatch(operations, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ids reserved by the failed batch aren't handed out again
+	for index, result := range results {
+		if want := strconv.Itoa(6 + index); result.Todo.Id != want {
+			t.Errorf("created todo %s, want %s", result.Todo.Id, want)
+		}
+	}
+	if sequence := strings.TrimSpace(string(readFile(t, SequenceFileName))); sequence != "8" {
+		t.Errorf("sequence %q, want 8", sequence)
+	}
+}
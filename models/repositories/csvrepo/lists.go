@@ -0,0 +1,225 @@
+package csvrepo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/list"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/utils"
+)
+
+// ListsFileName for storage of the lists
+const ListsFileName = "lists.csv"
+
+// ListsSequenceFileName of the file keeping the last handed out list id sequence value
+const ListsSequenceFileName = ListsFileName + ".sequence"
+
+// ListColumnCount of a list csv row: id, name, description, createdAt, updatedAt
+const ListColumnCount = 5
+
+// CsvFileListRepository type
+type CsvFileListRepository struct {
+	IdGenerator idgen.Generator
+}
+
+// Initialize initializes the repository
+func (c CsvFileListRepository) Initialize() error {
+	var err error = nil
+
+	_, err = os.Stat(ListsFileName)
+
+	if os.IsNotExist(err) {
+		var file *os.File
+		file, err = os.Create(ListsFileName)
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+		defer utils.CloseFileAndHandleError(file, &err)
+	}
+	return repositories.StorageError(err)
+}
+
+// ReadLists returns list's stored in file
+func (c CsvFileListRepository) ReadLists() ([]list.List, error) {
+	return readListsFromFile()
+}
+
+func readListsFromFile() ([]list.List, error) {
+	file, err := os.Open(ListsFileName)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+
+	defer utils.CloseFileAndHandleError(file, &err)
+
+	var readLists []list.List
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = ListColumnCount
+	for {
+		var records []string
+		records, err = csvReader.Read()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		var listParsed list.List
+		listParsed, err = parseListData(records)
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		readLists = append(readLists, listParsed)
+	}
+
+	return readLists, repositories.StorageError(err)
+}
+
+func parseListData(rec []string) (list.List, error) {
+	listParsed := list.List{Id: rec[0], Name: rec[1], Description: rec[2]}
+
+	var err error
+	listParsed.CreatedAt, err = todo.ParseTime(rec[3])
+	if err != nil {
+		return list.List{}, fmt.Errorf("invalid createdAt of list %s: %w", listParsed.Id, err)
+	}
+	listParsed.UpdatedAt, err = todo.ParseTime(rec[4])
+	if err != nil {
+		return list.List{}, fmt.Errorf("invalid updatedAt of list %s: %w", listParsed.Id, err)
+	}
+
+	return listParsed, nil
+}
+
+// writeListsToFile atomically replaces the file content with the passed lists
+func writeListsToFile(lists []list.List) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, currentList := range lists {
+		err := writer.Write(currentList.Serialize())
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+	}
+	writer.Flush()
+	err := writer.Error()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	return repositories.StorageError(utils.WriteFileAtomically(ListsFileName, buffer.Bytes()))
+}
+
+// ReadListById returns list with passed id when existing
+func (c CsvFileListRepository) ReadListById(id string) (list.List, error) {
+	lists, err := readListsFromFile()
+	if err != nil {
+		return list.List{}, err
+	}
+
+	for _, currentList := range lists {
+		if id == currentList.Id {
+			return currentList, nil
+		}
+	}
+
+	return list.List{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+}
+
+// CreateList stores the passed list in the file and returns the stored list
+func (c CsvFileListRepository) CreateList(listToCreate list.List) (list.List, error) {
+	err := withWriteLock(func() error {
+		lists, err := readListsFromFile()
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		for _, currentList := range lists {
+			ids = append(ids, currentList.Id)
+		}
+		id, err := idgen.OrDefault(c.IdGenerator).NewId(func() (uint64, error) {
+			return nextSequence(ListsSequenceFileName, ids)
+		})
+		if err != nil {
+			return err
+		}
+		for _, currentList := range lists {
+			if id == currentList.Id {
+				return fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+			}
+		}
+
+		listToCreate.Id = id
+		return writeListsToFile(append(lists, listToCreate))
+	})
+	if err != nil {
+		return list.List{}, err
+	}
+
+	return listToCreate, nil
+}
+
+// UpdateListById updates the passed list by id in csv and returns the updated list
+func (c CsvFileListRepository) UpdateListById(id string, listUpdate list.List) (list.List, error) {
+	err := withWriteLock(func() error {
+		lists, err := readListsFromFile()
+		if err != nil {
+			return err
+		}
+
+		for index, currentList := range lists {
+			if id == currentList.Id {
+				listUpdate.Id = id
+				lists[index] = listUpdate
+				return writeListsToFile(lists)
+			}
+		}
+
+		return fmt.Errorf("list with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+	})
+	if err != nil {
+		return list.List{}, err
+	}
+
+	return listUpdate, nil
+}
+
+// DeleteListById deletes the list by id in csv and returns the deleted list
+func (c CsvFileListRepository) DeleteListById(id string) (list.List, error) {
+	var deletedList list.List
+	err := withWriteLock(func() error {
+		lists, err := readListsFromFile()
+		if err != nil {
+			return err
+		}
+
+		var remainingLists []list.List
+		itemFound := false
+		for _, currentList := range lists {
+			if id == currentList.Id {
+				deletedList = currentList
+				itemFound = true
+				continue
+			}
+			remainingLists = append(remainingLists, currentList)
+		}
+
+		if !itemFound {
+			return fmt.Errorf("list with ID %s %w", id, repositories.ErrNotFound)
+		}
+
+		return writeListsToFile(remainingLists)
+	})
+	if err != nil {
+		return list.List{}, err
+	}
+
+	return deletedList, nil
+}
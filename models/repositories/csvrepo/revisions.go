@@ -0,0 +1,332 @@
+package csvrepo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/utils"
+)
+
+// RevisionsFileName for storage of the revisions
+const RevisionsFileName = "revisions.csv"
+
+// RevisionColumnCount of the columns of a revision csv row preceding the columns of its todo:
+// todoId, number, actor, time, action, changes, changeId, session
+const RevisionColumnCount = 8
+
+// CsvFileRevisionRepository type (safe for concurrent use). The revisions are appended to the file and indexed in
+// memory by their position in the file; rows appended by other processes are indexed before the next read or write.
+type CsvFileRevisionRepository struct {
+	mutex         sync.Mutex
+	indexed       int64                      // file offset after the last indexed row
+	byTodo        map[string][]revisionEntry // rows of the revisions by todo id, oldest first
+	changes       map[string][]revisionEntry // rows of the revisions by change id, in the order they were recorded
+	lastBySession map[string]string          // id of the last change by undo session key
+}
+
+// revisionEntry position of the row of a stored revision in the file
+type revisionEntry struct {
+	number int
+	offset int64
+	length int64
+}
+
+// Initialize creates the file when missing, cuts off a partial last row left behind by a crash during a write and
+// indexes the stored revisions
+func (c *CsvFileRevisionRepository) Initialize() error {
+	return withWriteLock(func() error {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		file, err := os.OpenFile(RevisionsFileName, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+		err = file.Close()
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+
+		c.reset()
+		return c.catchUp(true)
+	})
+}
+
+// ReadRevisions returns the revisions of the todo with passed id stored in file, oldest first
+func (c *CsvFileRevisionRepository) ReadRevisions(todoId string) ([]revision.Revision, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.catchUp(false)
+	if err != nil {
+		return nil, err
+	}
+	return readRevisionRows(c.byTodo[todoId])
+}
+
+// ReadRevision returns the revision with passed number of the todo with passed id when existing
+func (c *CsvFileRevisionRepository) ReadRevision(todoId string, number int) (revision.Revision, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.catchUp(false)
+	if err != nil {
+		return revision.Revision{}, err
+	}
+	for _, entry := range c.byTodo[todoId] {
+		if number == entry.number {
+			revisions, err := readRevisionRows([]revisionEntry{entry})
+			if err != nil {
+				return revision.Revision{}, err
+			}
+			return revisions[0], nil
+		}
+	}
+
+	return revision.Revision{}, fmt.Errorf("revision %w", repositories.ErrNotFound)
+}
+
+// ReadLastChange returns the revisions of the change recorded last for the passed undo session when existing
+func (c *CsvFileRevisionRepository) ReadLastChange(session string) ([]revision.Revision, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.catchUp(false)
+	if err != nil {
+		return nil, err
+	}
+	changeId, found := c.lastBySession[session]
+	if !found {
+		return nil, fmt.Errorf("change %w", repositories.ErrNotFound)
+	}
+	return readRevisionRows(c.changes[changeId])
+}
+
+// CreateRevision appends the passed revision to the file as next revision of its todo and returns the stored revision
+func (c *CsvFileRevisionRepository) CreateRevision(revisionToCreate revision.Revision) (revision.Revision, error) {
+	err := withWriteLock(func() error {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		// the rows of other processes are indexed first, they may have appended revisions of the same todo
+		err := c.catchUp(true)
+		if err != nil {
+			return err
+		}
+		revisionToCreate.Number = 1
+		if entries := c.byTodo[revisionToCreate.TodoId]; len(entries) > 0 {
+			revisionToCreate.Number = entries[len(entries)-1].number + 1
+		}
+
+		row, err := formatRevisionRow(revisionToCreate)
+		if err != nil {
+			return err
+		}
+		err = appendRow(c.indexed, row)
+		if err != nil {
+			return err
+		}
+		c.add(revisionToCreate, revisionEntry{number: revisionToCreate.Number, offset: c.indexed,
+			length: int64(len(row))})
+		return nil
+	})
+	if err != nil {
+		return revision.Revision{}, err
+	}
+
+	return revisionToCreate, nil
+}
+
+// reset clears the indexes. The caller must hold the mutex.
+func (c *CsvFileRevisionRepository) reset() {
+	c.indexed = 0
+	c.byTodo = map[string][]revisionEntry{}
+	c.changes = map[string][]revisionEntry{}
+	c.lastBySession = map[string]string{}
+}
+
+// add indexes the row of the passed revision. The caller must hold the mutex.
+func (c *CsvFileRevisionRepository) add(storedRevision revision.Revision, entry revisionEntry) {
+	c.byTodo[storedRevision.TodoId] = append(c.byTodo[storedRevision.TodoId], entry)
+	if storedRevision.ChangeId != "" {
+		c.changes[storedRevision.ChangeId] = append(c.changes[storedRevision.ChangeId], entry)
+		if storedRevision.Session != "" {
+			c.lastBySession[storedRevision.Session] = storedRevision.ChangeId
+		}
+	}
+	c.indexed = entry.offset + entry.length
+}
+
+// catchUp indexes the rows appended to the file since the last call. A partial last row is cut off when truncate
+// is set, the caller must hold the write lock then; otherwise it is left to be completed by its writer.
+// The caller must hold the mutex.
+func (c *CsvFileRevisionRepository) catchUp(truncate bool) (err error) {
+	if c.byTodo == nil {
+		return repositories.StorageError(errors.New("revision repository not initialized"))
+	}
+
+	info, err := os.Stat(RevisionsFileName)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	if info.Size() < c.indexed {
+		// the file was replaced, e.g. restored from a backup
+		c.reset()
+	}
+	if info.Size() == c.indexed {
+		return nil
+	}
+
+	file, err := os.Open(RevisionsFileName)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	defer utils.CloseFileAndHandleError(file, &err)
+
+	data := make([]byte, info.Size()-c.indexed)
+	_, err = file.ReadAt(data, c.indexed)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	csvReader := csv.NewReader(bytes.NewReader(data))
+	// The number of todo columns grows with the todo fields
+	csvReader.FieldsPerRecord = -1
+	var position int64
+	for {
+		records, readErr := csvReader.Read()
+		if readErr == io.EOF {
+			return nil
+		}
+		next := csvReader.InputOffset()
+		// every row ends with a newline, a row without one or unreadable up to the end of the file is partial
+		if next == int64(len(data)) && (readErr != nil || data[next-1] != '\n') {
+			break
+		}
+		if readErr != nil {
+			return repositories.StorageError(readErr)
+		}
+		revisionParsed, parseErr := parseRevisionData(records)
+		if parseErr != nil {
+			return repositories.StorageError(parseErr)
+		}
+		c.add(revisionParsed, revisionEntry{number: revisionParsed.Number, offset: c.indexed, length: next - position})
+		position = next
+	}
+
+	if !truncate {
+		return nil
+	}
+	return repositories.StorageError(os.Truncate(RevisionsFileName, c.indexed))
+}
+
+// appendRow durably appends the row to the file, which has the passed size. A failed write is cut off again, so
+// that later rows don't follow a partial one. The caller must hold the write lock.
+func appendRow(size int64, row []byte) (err error) {
+	file, err := os.OpenFile(RevisionsFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	defer utils.CloseFileAndHandleError(file, &err)
+
+	_, err = file.Write(row)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return repositories.StorageError(errors.Join(err, file.Truncate(size)))
+	}
+	return nil
+}
+
+// readRevisionRows reads the revisions of the passed rows of the file
+func readRevisionRows(entries []revisionEntry) (revisions []revision.Revision, err error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	file, err := os.Open(RevisionsFileName)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+	defer utils.CloseFileAndHandleError(file, &err)
+
+	for _, entry := range entries {
+		csvReader := csv.NewReader(io.NewSectionReader(file, entry.offset, entry.length))
+		csvReader.FieldsPerRecord = -1
+		var records []string
+		records, err = csvReader.Read()
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		var revisionParsed revision.Revision
+		revisionParsed, err = parseRevisionData(records)
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		revisions = append(revisions, revisionParsed)
+	}
+
+	return revisions, repositories.StorageError(err)
+}
+
+func parseRevisionData(rec []string) (revision.Revision, error) {
+	if len(rec) < RevisionColumnCount+MinColumnCount {
+		return revision.Revision{}, fmt.Errorf("invalid revision row with %d columns", len(rec))
+	}
+
+	revisionParsed := revision.Revision{TodoId: rec[0], Actor: rec[2], Action: revision.Action(rec[4]), ChangeId: rec[6],
+		Session: rec[7]}
+
+	var err error
+	revisionParsed.Number, err = strconv.Atoi(rec[1])
+	if err != nil {
+		return revision.Revision{}, fmt.Errorf("invalid number of revision of todo %s: %w", revisionParsed.TodoId, err)
+	}
+	revisionParsed.Time, err = time.Parse(time.RFC3339Nano, rec[3])
+	if err != nil {
+		return revision.Revision{}, fmt.Errorf("invalid time of revision %d of todo %s: %w", revisionParsed.Number, revisionParsed.TodoId, err)
+	}
+	err = json.Unmarshal([]byte(rec[5]), &revisionParsed.Changes)
+	if err != nil {
+		return revision.Revision{}, fmt.Errorf("invalid changes of revision %d of todo %s: %w", revisionParsed.Number, revisionParsed.TodoId, err)
+	}
+	revisionParsed.Todo, err = parseTodoData(rec[RevisionColumnCount:])
+	if err != nil {
+		return revision.Revision{}, fmt.Errorf("invalid todo of revision %d: %w", revisionParsed.Number, err)
+	}
+
+	return revisionParsed, nil
+}
+
+// formatRevisionRow returns the csv row of the passed revision
+func formatRevisionRow(revisionToFormat revision.Revision) ([]byte, error) {
+	changes, err := json.Marshal(revisionToFormat.Changes)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+	record := append([]string{revisionToFormat.TodoId, strconv.Itoa(revisionToFormat.Number), revisionToFormat.Actor,
+		revisionToFormat.Time.Format(time.RFC3339Nano), string(revisionToFormat.Action), string(changes),
+		revisionToFormat.ChangeId, revisionToFormat.Session},
+		revisionToFormat.Todo.Serialize()...)
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	err = writer.Write(record)
+	if err == nil {
+		writer.Flush()
+		err = writer.Error()
+	}
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+	return buffer.Bytes(), nil
+}
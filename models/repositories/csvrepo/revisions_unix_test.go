@@ -0,0 +1,179 @@
+//go:build unix
+
+package csvrepo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/todo"
+)
+
+// newRevisionRepository returns an initialized revision repository with its file in a new temporary directory
+func newRevisionRepository(t *testing.T) *CsvFileRevisionRepository {
+	t.Helper()
+	inTempDir(t)
+	repository := &CsvFileRevisionRepository{}
+	initializeRevisions(t, repository)
+	return repository
+}
+
+// initializeRevisions (re)initializes the repository from its file, e.g. like a restart
+func initializeRevisions(t *testing.T, repository *CsvFileRevisionRepository) {
+	t.Helper()
+	err := repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createRevision(t *testing.T, repository *CsvFileRevisionRepository, todoId string, changeId string,
+	session string) revision.Revision {
+	t.Helper()
+	created, err := repository.CreateRevision(revision.Revision{TodoId: todoId, Actor: "tester",
+		Time: time.Now().UTC(), Action: revision.ActionUpdated, ChangeId: changeId, Session: session,
+		Todo: todo.Todo{Id: todoId, Title: "todo " + todoId, Description: "multi\nline"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// numbers returns the numbers of the revisions of the todo with passed id
+func numbers(t *testing.T, repository *CsvFileRevisionRepository, todoId string) []int {
+	t.Helper()
+	revisions, err := repository.ReadRevisions(todoId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revisionNumbers []int
+	for _, currentRevision := range revisions {
+		revisionNumbers = append(revisionNumbers, currentRevision.Number)
+	}
+	return revisionNumbers
+}
+
+func TestRevisionsAreAppended(t *testing.T) {
+	repository := newRevisionRepository(t)
+	createRevision(t, repository, "1", "a", "session")
+	createRevision(t, repository, "2", "a", "session")
+	stored := readFile(t, RevisionsFileName)
+
+	if created := createRevision(t, repository, "1", "b", "session"); created.Number != 2 {
+		t.Errorf("revision %d created, want 2", created.Number)
+	}
+	if content := readFile(t, RevisionsFileName); !bytes.HasPrefix(content, stored) {
+		t.Errorf("stored revisions changed by the append:\n%s", content)
+	}
+
+	// the indexes are rebuilt from the file on a restart
+	initializeRevisions(t, repository)
+	if got := numbers(t, repository, "1"); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("revisions %v of todo 1 after the restart, want [1 2]", got)
+	}
+	read, err := repository.ReadRevision("1", 2)
+	if err != nil || read.ChangeId != "b" || read.Todo.Description != "multi\nline" {
+		t.Errorf("revision %+v (%v), want revision 2 of change b", read, err)
+	}
+	_, err = repository.ReadRevision("1", 3)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v for an unknown revision, want not found", err)
+	}
+}
+
+func TestReadLastChangeReadsAllRevisionsOfTheChange(t *testing.T) {
+	repository := newRevisionRepository(t)
+	createRevision(t, repository, "1", "first", "session")
+	createRevision(t, repository, "1", "second", "session")
+	createRevision(t, repository, "2", "second", "session")
+	createRevision(t, repository, "3", "other", "other session")
+
+	change, err := repository.ReadLastChange("session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(change) != 2 || change[0].TodoId != "1" || change[1].TodoId != "2" || change[0].ChangeId != "second" {
+		t.Errorf("last change %+v, want the revisions of todo 1 and 2 of change second", change)
+	}
+	_, err = repository.ReadLastChange("unknown")
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v for an unknown session, want not found", err)
+	}
+}
+
+func TestPartialRevisionIsCutOffOnStart(t *testing.T) {
+	repository := newRevisionRepository(t)
+	createRevision(t, repository, "1", "a", "session")
+	stored := readFile(t, RevisionsFileName)
+
+	// a crash in the middle of the next write leaves a partial row, here inside the quoted description
+	appendToFile(t, RevisionsFileName, "1,2,tester,2024-01-01T00:00:00Z,updated,[],b,session,1,todo 1,\"multi\n")
+	if got := numbers(t, repository, "1"); !slices.Equal(got, []int{1}) {
+		t.Errorf("revisions %v with the partial row, want [1]", got)
+	}
+	initializeRevisions(t, repository)
+	if content := readFile(t, RevisionsFileName); !bytes.Equal(content, stored) {
+		t.Errorf("partial row not cut off:\n%s", content)
+	}
+	if created := createRevision(t, repository, "1", "c", "session"); created.Number != 2 {
+		t.Errorf("revision %d created after the restart, want 2", created.Number)
+	}
+}
+
+func TestInterruptedRevisionWriteIsCutOff(t *testing.T) {
+	repository := newRevisionRepository(t)
+	createRevision(t, repository, "1", "a", "session")
+	stored := readFile(t, RevisionsFileName)
+
+	withFileSizeLimit(t, uint64(len(stored)+10), func() {
+		_, err := repository.CreateRevision(revision.Revision{TodoId: "1", Time: time.Now().UTC(),
+			Todo: todo.Todo{Id: "1", Title: "interrupted"}})
+		if err == nil {
+			t.Error("create succeeded despite the failing write")
+		}
+	})
+
+	if content := readFile(t, RevisionsFileName); !bytes.Equal(content, stored) {
+		t.Errorf("%s changed by the interrupted create:\n%s", RevisionsFileName, content)
+	}
+	if created := createRevision(t, repository, "1", "b", "session"); created.Number != 2 {
+		t.Errorf("revision %d created after the failed write, want 2", created.Number)
+	}
+}
+
+func TestRevisionsOfOtherRepositoryAreIndexed(t *testing.T) {
+	repository := newRevisionRepository(t)
+	// a second repository on the same file, like the one of another process
+	other := &CsvFileRevisionRepository{}
+	initializeRevisions(t, other)
+
+	createRevision(t, repository, "1", "a", "session")
+	if created := createRevision(t, other, "1", "b", "other session"); created.Number != 2 {
+		t.Errorf("revision %d created by the other repository, want 2", created.Number)
+	}
+	if got := numbers(t, repository, "1"); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("revisions %v, want the ones of both repositories", got)
+	}
+	change, err := repository.ReadLastChange("other session")
+	if err != nil || len(change) != 1 || change[0].ChangeId != "b" {
+		t.Errorf("last change %+v (%v) of the other session, want b", change, err)
+	}
+}
+
+func appendToFile(t *testing.T, fileName string, content string) {
+	t.Helper()
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString(content)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,412 @@
+package csvrepo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/utils"
+	"todo-rest-backend/models/webhook"
+)
+
+// WebhooksFileName for storage of the webhooks
+const WebhooksFileName = "webhooks.csv"
+
+// WebhooksSequenceFileName of the file keeping the last handed out webhook id sequence value
+const WebhooksSequenceFileName = WebhooksFileName + ".sequence"
+
+// WebhookColumnCount of a webhook csv row: id, url, secret, events, createdAt, updatedAt
+const WebhookColumnCount = 6
+
+// DeadLettersFileName for storage of the webhook deliveries which failed for good
+const DeadLettersFileName = "dead-letters.csv"
+
+// DeadLettersSequenceFileName of the file keeping the last handed out dead letter id sequence value
+const DeadLettersSequenceFileName = DeadLettersFileName + ".sequence"
+
+// DeadLetterColumnCount of a dead letter csv row: id, webhookId, url, event, attempts, lastStatus, lastError, failedAt
+const DeadLetterColumnCount = 8
+
+// CsvFileWebhookRepository type
+type CsvFileWebhookRepository struct {
+	IdGenerator idgen.Generator
+}
+
+// Initialize initializes the repository
+func (c CsvFileWebhookRepository) Initialize() error {
+	err := createFileIfMissing(WebhooksFileName)
+	if err != nil {
+		return err
+	}
+	return createFileIfMissing(DeadLettersFileName)
+}
+
+// createFileIfMissing creates the passed empty file unless it exists
+func createFileIfMissing(fileName string) (err error) {
+	_, err = os.Stat(fileName)
+
+	if os.IsNotExist(err) {
+		var file *os.File
+		file, err = os.Create(fileName)
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+		defer utils.CloseFileAndHandleError(file, &err)
+	}
+	return repositories.StorageError(err)
+}
+
+// ReadWebhooks returns webhook's stored in file
+func (c CsvFileWebhookRepository) ReadWebhooks() ([]webhook.Webhook, error) {
+	return readWebhooksFromFile()
+}
+
+func readWebhooksFromFile() ([]webhook.Webhook, error) {
+	file, err := os.Open(WebhooksFileName)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+
+	defer utils.CloseFileAndHandleError(file, &err)
+
+	var readWebhooks []webhook.Webhook
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = WebhookColumnCount
+	for {
+		var records []string
+		records, err = csvReader.Read()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		var webhookParsed webhook.Webhook
+		webhookParsed, err = parseWebhookData(records)
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		readWebhooks = append(readWebhooks, webhookParsed)
+	}
+
+	return readWebhooks, repositories.StorageError(err)
+}
+
+func parseWebhookData(rec []string) (webhook.Webhook, error) {
+	webhookParsed := webhook.Webhook{Id: rec[0], Url: rec[1], Secret: rec[2]}
+
+	var err error
+	webhookParsed.Events, err = todo.ParseTags(rec[3])
+	if err != nil {
+		return webhook.Webhook{}, fmt.Errorf("invalid events of webhook %s: %w", webhookParsed.Id, err)
+	}
+	webhookParsed.CreatedAt, err = todo.ParseTime(rec[4])
+	if err != nil {
+		return webhook.Webhook{}, fmt.Errorf("invalid createdAt of webhook %s: %w", webhookParsed.Id, err)
+	}
+	webhookParsed.UpdatedAt, err = todo.ParseTime(rec[5])
+	if err != nil {
+		return webhook.Webhook{}, fmt.Errorf("invalid updatedAt of webhook %s: %w", webhookParsed.Id, err)
+	}
+
+	return webhookParsed, nil
+}
+
+// writeWebhooksToFile atomically replaces the file content with the passed webhooks
+func writeWebhooksToFile(webhooks []webhook.Webhook) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, currentWebhook := range webhooks {
+		err := writer.Write(currentWebhook.Serialize())
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+	}
+	writer.Flush()
+	err := writer.Error()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	return repositories.StorageError(utils.WriteFileAtomically(WebhooksFileName, buffer.Bytes()))
+}
+
+// ReadWebhookById returns webhook with passed id when existing
+func (c CsvFileWebhookRepository) ReadWebhookById(id string) (webhook.Webhook, error) {
+	webhooks, err := readWebhooksFromFile()
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+
+	for _, currentWebhook := range webhooks {
+		if id == currentWebhook.Id {
+			return currentWebhook, nil
+		}
+	}
+
+	return webhook.Webhook{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+}
+
+// CreateWebhook stores the passed webhook in the file and returns the stored webhook
+func (c CsvFileWebhookRepository) CreateWebhook(webhookToCreate webhook.Webhook) (webhook.Webhook, error) {
+	err := withWriteLock(func() error {
+		webhooks, err := readWebhooksFromFile()
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		for _, currentWebhook := range webhooks {
+			ids = append(ids, currentWebhook.Id)
+		}
+		id, err := idgen.OrDefault(c.IdGenerator).NewId(func() (uint64, error) {
+			return nextSequence(WebhooksSequenceFileName, ids)
+		})
+		if err != nil {
+			return err
+		}
+		for _, currentWebhook := range webhooks {
+			if id == currentWebhook.Id {
+				return fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+			}
+		}
+
+		webhookToCreate.Id = id
+		return writeWebhooksToFile(append(webhooks, webhookToCreate))
+	})
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+
+	return webhookToCreate, nil
+}
+
+// UpdateWebhookById updates the passed webhook by id in csv and returns the updated webhook
+func (c CsvFileWebhookRepository) UpdateWebhookById(id string, webhookUpdate webhook.Webhook) (webhook.Webhook, error) {
+	err := withWriteLock(func() error {
+		webhooks, err := readWebhooksFromFile()
+		if err != nil {
+			return err
+		}
+
+		for index, currentWebhook := range webhooks {
+			if id == currentWebhook.Id {
+				webhookUpdate.Id = id
+				webhooks[index] = webhookUpdate
+				return writeWebhooksToFile(webhooks)
+			}
+		}
+
+		return fmt.Errorf("webhook with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+	})
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+
+	return webhookUpdate, nil
+}
+
+// DeleteWebhookById deletes the webhook by id in csv and returns the deleted webhook
+func (c CsvFileWebhookRepository) DeleteWebhookById(id string) (webhook.Webhook, error) {
+	var deletedWebhook webhook.Webhook
+	err := withWriteLock(func() error {
+		webhooks, err := readWebhooksFromFile()
+		if err != nil {
+			return err
+		}
+
+		var remainingWebhooks []webhook.Webhook
+		itemFound := false
+		for _, currentWebhook := range webhooks {
+			if id == currentWebhook.Id {
+				deletedWebhook = currentWebhook
+				itemFound = true
+				continue
+			}
+			remainingWebhooks = append(remainingWebhooks, currentWebhook)
+		}
+
+		if !itemFound {
+			return fmt.Errorf("webhook with ID %s %w", id, repositories.ErrNotFound)
+		}
+
+		return writeWebhooksToFile(remainingWebhooks)
+	})
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+
+	return deletedWebhook, nil
+}
+
+// ReadDeadLetters returns the dead letters stored in file, oldest first
+func (c CsvFileWebhookRepository) ReadDeadLetters() ([]webhook.DeadLetter, error) {
+	return readDeadLettersFromFile()
+}
+
+func readDeadLettersFromFile() ([]webhook.DeadLetter, error) {
+	file, err := os.Open(DeadLettersFileName)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+
+	defer utils.CloseFileAndHandleError(file, &err)
+
+	var readDeadLetters []webhook.DeadLetter
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = DeadLetterColumnCount
+	for {
+		var records []string
+		records, err = csvReader.Read()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		var deadLetterParsed webhook.DeadLetter
+		deadLetterParsed, err = parseDeadLetterData(records)
+		if err != nil {
+			return nil, repositories.StorageError(err)
+		}
+		readDeadLetters = append(readDeadLetters, deadLetterParsed)
+	}
+
+	return readDeadLetters, repositories.StorageError(err)
+}
+
+func parseDeadLetterData(rec []string) (webhook.DeadLetter, error) {
+	deadLetterParsed := webhook.DeadLetter{Id: rec[0], WebhookId: rec[1], Url: rec[2], Event: json.RawMessage(rec[3]),
+		LastError: rec[6]}
+
+	if !json.Valid(deadLetterParsed.Event) {
+		return webhook.DeadLetter{}, fmt.Errorf("invalid event of dead letter %s", deadLetterParsed.Id)
+	}
+	var err error
+	deadLetterParsed.Attempts, err = strconv.Atoi(rec[4])
+	if err != nil {
+		return webhook.DeadLetter{}, fmt.Errorf("invalid attempts of dead letter %s: %w", deadLetterParsed.Id, err)
+	}
+	deadLetterParsed.LastStatus, err = strconv.Atoi(rec[5])
+	if err != nil {
+		return webhook.DeadLetter{}, fmt.Errorf("invalid lastStatus of dead letter %s: %w", deadLetterParsed.Id, err)
+	}
+	failedAt, err := todo.ParseTime(rec[7])
+	if err != nil {
+		return webhook.DeadLetter{}, fmt.Errorf("invalid failedAt of dead letter %s: %w", deadLetterParsed.Id, err)
+	}
+	if failedAt == nil {
+		return webhook.DeadLetter{}, fmt.Errorf("missing failedAt of dead letter %s", deadLetterParsed.Id)
+	}
+	deadLetterParsed.FailedAt = *failedAt
+
+	return deadLetterParsed, nil
+}
+
+// writeDeadLettersToFile atomically replaces the file content with the passed dead letters
+func writeDeadLettersToFile(deadLetters []webhook.DeadLetter) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, currentDeadLetter := range deadLetters {
+		err := writer.Write(currentDeadLetter.Serialize())
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+	}
+	writer.Flush()
+	err := writer.Error()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	return repositories.StorageError(utils.WriteFileAtomically(DeadLettersFileName, buffer.Bytes()))
+}
+
+// ReadDeadLetterById returns the dead letter with passed id when existing
+func (c CsvFileWebhookRepository) ReadDeadLetterById(id string) (webhook.DeadLetter, error) {
+	deadLetters, err := readDeadLettersFromFile()
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+
+	for _, currentDeadLetter := range deadLetters {
+		if id == currentDeadLetter.Id {
+			return currentDeadLetter, nil
+		}
+	}
+
+	return webhook.DeadLetter{}, fmt.Errorf("dead letter %w", repositories.ErrNotFound)
+}
+
+// CreateDeadLetter stores the passed dead letter in the file, dropping the oldest ones beyond webhook.DeadLetterSize,
+// and returns the stored dead letter
+func (c CsvFileWebhookRepository) CreateDeadLetter(deadLetterToCreate webhook.DeadLetter) (webhook.DeadLetter, error) {
+	err := withWriteLock(func() error {
+		deadLetters, err := readDeadLettersFromFile()
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		for _, currentDeadLetter := range deadLetters {
+			ids = append(ids, currentDeadLetter.Id)
+		}
+		sequence, err := nextSequence(DeadLettersSequenceFileName, ids)
+		if err != nil {
+			return err
+		}
+
+		deadLetterToCreate.Id = strconv.FormatUint(sequence, 10)
+		deadLetters = append(deadLetters, deadLetterToCreate)
+		if len(deadLetters) > webhook.DeadLetterSize {
+			deadLetters = deadLetters[len(deadLetters)-webhook.DeadLetterSize:]
+		}
+		return writeDeadLettersToFile(deadLetters)
+	})
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+
+	return deadLetterToCreate, nil
+}
+
+// DeleteDeadLetterById deletes the dead letter by id in csv and returns the deleted dead letter
+func (c CsvFileWebhookRepository) DeleteDeadLetterById(id string) (webhook.DeadLetter, error) {
+	var deletedDeadLetter webhook.DeadLetter
+	err := withWriteLock(func() error {
+		deadLetters, err := readDeadLettersFromFile()
+		if err != nil {
+			return err
+		}
+
+		var remainingDeadLetters []webhook.DeadLetter
+		itemFound := false
+		for _, currentDeadLetter := range deadLetters {
+			if id == currentDeadLetter.Id {
+				deletedDeadLetter = currentDeadLetter
+				itemFound = true
+				continue
+			}
+			remainingDeadLetters = append(remainingDeadLetters, currentDeadLetter)
+		}
+
+		if !itemFound {
+			return fmt.Errorf("dead letter %w", repositories.ErrNotFound)
+		}
+
+		return writeDeadLettersToFile(remainingDeadLetters)
+	})
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+
+	return deletedDeadLetter, nil
+}
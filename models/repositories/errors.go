@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned for todos and lists which don't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a change conflicts with the stored state, e.g. an already existing id
+var ErrConflict = errors.New("conflict")
+
+// ErrValidation is returned for todos and lists violating the business rules, see ValidationError
+var ErrValidation = errors.New("validation failed")
+
+// ErrStorage is returned when the underlying storage (file, database) fails; the details are not meant for clients
+var ErrStorage = errors.New("storage error")
+
+// ErrVersionMismatch is returned by DeleteTodoById when the stored todo has another version than expected
+var ErrVersionMismatch = fmt.Errorf("%w: version mismatch", ErrConflict)
+
+// FieldError violation of a business rule by a single field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists all violations of an entity, it matches ErrValidation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError returns the validation error of the passed violations, nil without violations
+func NewValidationError(fields []FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	violations := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		violations = append(violations, field.Field+": "+field.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(violations, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// StorageError marks the passed error of the underlying storage as ErrStorage, nil stays nil
+func StorageError(err error) error {
+	if err == nil || errors.Is(err, ErrStorage) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
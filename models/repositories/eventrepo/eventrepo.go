@@ -0,0 +1,658 @@
+// Package eventrepo contains the repository logic for an event-sourced todo store. Every mutation is appended as
+// immutable event to an event log and the current state is a projection of the events. Snapshots of the projection
+// are taken periodically, so that rebuilding the current or a recent state replays a bounded number of events; only
+// the last snapshots are kept.
+package eventrepo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/utils"
+)
+
+// SnapshotFileSuffix is appended to the event log path to get the path of the snapshot log
+const SnapshotFileSuffix = ".snapshots"
+
+// SnapshotIntervalDefault default number of events between two snapshots
+const SnapshotIntervalDefault = 1000
+
+// SnapshotRetentionDefault default number of snapshots kept, older ones are dropped when twice as many are taken
+const SnapshotRetentionDefault = 10
+
+// event immutable change of a todo
+type event struct {
+	Sequence   uint64                  `json:"seq"`
+	Time       time.Time               `json:"time"`
+	Type       repositories.ChangeType `json:"type"`
+	Todo       todo.Todo               `json:"todo"`            // todo after the change, the deleted todo for deletions
+	IdSequence uint64                  `json:"idSeq,omitempty"` // id sequence value after a creation
+}
+
+// snapshot projection of the events up to and including the event with Sequence
+type snapshot struct {
+	Sequence   uint64      `json:"seq"`
+	Time       time.Time   `json:"time"`   // time of the last contained event
+	Offset     int64       `json:"offset"` // event log offset after the last contained event
+	IdSequence uint64      `json:"idSeq"`
+	Todos      []todo.Todo `json:"todos,omitempty"`
+}
+
+// snapshotRef snapshot without its todos, with its position in the snapshot log
+type snapshotRef struct {
+	Sequence uint64
+	Time     time.Time
+	Offset   int64
+	Position int64
+}
+
+// projection state built from the events
+type projection struct {
+	todos      map[string]todo.Todo
+	sequence   uint64    // last applied event
+	time       time.Time // of the last applied event
+	idSequence uint64
+}
+
+func newProjection() projection {
+	return projection{todos: map[string]todo.Todo{}}
+}
+
+func (p *projection) apply(e event) {
+	switch e.Type {
+	case repositories.ChangeCreated, repositories.ChangeUpdated:
+		p.todos[e.Todo.Id] = e.Todo
+	case repositories.ChangeDeleted:
+		delete(p.todos, e.Todo.Id)
+	}
+	p.sequence = e.Sequence
+	p.time = e.Time
+	p.idSequence = max(p.idSequence, e.IdSequence)
+}
+
+func (p *projection) list() []todo.Todo {
+	var todos []todo.Todo
+	for _, currentTodo := range p.todos {
+		todos = append(todos, currentTodo)
+	}
+	return todos
+}
+
+// EventSourcedTodoRepository type
+type EventSourcedTodoRepository struct {
+	EventLogPath      string
+	SnapshotInterval  int // events between two snapshots, SnapshotIntervalDefault when not set
+	SnapshotRetention int // snapshots kept, SnapshotRetentionDefault when not set
+	IdGenerator       idgen.Generator
+
+	mutex           sync.Mutex
+	eventLog        *os.File
+	eventLogSize    int64
+	snapshotLog     *os.File
+	snapshotLogSize int64
+	snapshots       []snapshotRef // oldest first
+	pendingEvents   int           // events appended since the last snapshot
+	state           projection
+
+	// held for reading while a snapshot is read without holding mutex, for writing while the snapshot log is compacted
+	snapshotMutex sync.RWMutex
+
+	// index of the history of the todos, built on demand up to historyIndexed
+	historyMutex   sync.Mutex
+	historyOffsets map[string][]int64 // event log offsets of the entries with events of a todo by todo id
+	historyIndexed int64              // event log offset after the last indexed entry
+}
+
+// Initialize rebuilds the state from the last snapshot and the events after it and opens the logs for appending
+func (r *EventSourcedTodoRepository) Initialize() error {
+	if r.EventLogPath == "" {
+		return errors.New("event log path must not be empty")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_ = r.closeLogs()
+	r.state = newProjection()
+	r.snapshots = nil
+	r.pendingEvents = 0
+	r.historyMutex.Lock()
+	r.historyOffsets = nil
+	r.historyIndexed = 0
+	r.historyMutex.Unlock()
+
+	snapshotLogSize, err := r.loadSnapshots()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	var offset int64
+	if len(r.snapshots) > 0 {
+		last := r.snapshots[len(r.snapshots)-1]
+		r.state, err = r.readSnapshot(last)
+		if err != nil {
+			return repositories.StorageError(err)
+		}
+		offset = last.Offset
+	}
+
+	eventLogSize, err := utils.ReadLogEntries(r.EventLogPath, offset, -1, func(payload []byte, _ int64) (bool, error) {
+		var events []event
+		err := json.Unmarshal(payload, &events)
+		if err != nil {
+			return false, err
+		}
+		for _, e := range events {
+			r.state.apply(e)
+		}
+		r.pendingEvents += len(events)
+		return true, nil
+	})
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	if eventLogSize < offset {
+		return repositories.StorageError(fmt.Errorf("snapshot beyond the end of the event log %s", r.EventLogPath))
+	}
+
+	r.eventLog, err = utils.OpenLogForAppending(r.EventLogPath, eventLogSize)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	r.eventLogSize = eventLogSize
+	r.snapshotLog, err = utils.OpenLogForAppending(r.snapshotPath(), snapshotLogSize)
+	if err != nil {
+		_ = r.closeLogs()
+		return repositories.StorageError(err)
+	}
+	r.snapshotLogSize = snapshotLogSize
+	return nil
+}
+
+// Close closes the event and snapshot logs
+func (r *EventSourcedTodoRepository) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.closeLogs()
+}
+
+// ReadTodos returns todo's of the current state
+func (r *EventSourcedTodoRepository) ReadTodos() ([]todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.list(), nil
+}
+
+// ReadTodosFiltered returns todo's of the current state matching the passed filter
+func (r *EventSourcedTodoRepository) ReadTodosFiltered(filter repositories.TodoFilter) ([]todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var filteredTodos []todo.Todo
+	for _, currentTodo := range r.state.todos {
+		if filter.Matches(currentTodo) {
+			filteredTodos = append(filteredTodos, currentTodo)
+		}
+	}
+
+	return filteredTodos, nil
+}
+
+// ReadTodoById returns todo with passed id when existing
+func (r *EventSourcedTodoRepository) ReadTodoById(id string) (todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	currentTodo, ok := r.state.todos[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+
+	return currentTodo, nil
+}
+
+// CreateTodo appends a created event and returns the stored todo
+func (r *EventSourcedTodoRepository) CreateTodo(todoToCreate todo.Todo) (todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sequence := r.state.idSequence
+	id, err := idgen.OrDefault(r.IdGenerator).NewId(func() (uint64, error) {
+		sequence++
+		return sequence, nil
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	if _, exists := r.state.todos[id]; exists {
+		return todo.Todo{}, fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+	}
+
+	todoToCreate.Id = id
+	err = r.append([]event{{Type: repositories.ChangeCreated, Todo: todoToCreate, IdSequence: sequence}})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoToCreate, nil
+}
+
+// UpdateTodoById appends an updated event and returns the updated todo
+func (r *EventSourcedTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.state.todos[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("item with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+	}
+
+	todoUpdate.Id = id
+	err := r.append([]event{{Type: repositories.ChangeUpdated, Todo: todoUpdate}})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoUpdate, nil
+}
+
+// PatchTodoById appends an updated event with the result of the passed patch function and returns the patched todo
+func (r *EventSourcedTodoRepository) PatchTodoById(id string, patch func(todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	currentTodo, ok := r.state.todos[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("item with id %s %w. Patching not possible", id, repositories.ErrNotFound)
+	}
+
+	todoPatched, err := patch(currentTodo)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	todoPatched.Id = id
+
+	err = r.append([]event{{Type: repositories.ChangeUpdated, Todo: todoPatched}})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoPatched, nil
+}
+
+// DeleteTodoById appends a deleted event and returns the deleted todo
+func (r *EventSourcedTodoRepository) DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deletedTodo, ok := r.state.todos[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("todo with ID %s %w", id, repositories.ErrNotFound)
+	}
+	if todoDelete.Version != 0 && todoDelete.Version != deletedTodo.Version {
+		return todo.Todo{}, repositories.ErrVersionMismatch
+	}
+
+	err := r.append([]event{{Type: repositories.ChangeDeleted, Todo: deletedTodo}})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return deletedTodo, nil
+}
+
+// ApplyBatch appends the events of the successful operations at once and returns the results of the operations
+func (r *EventSourcedTodoRepository) ApplyBatch(operations []repositories.BatchOperation, atomic bool) ([]repositories.BatchResult, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sequence := r.state.idSequence
+	_, results, changed := repositories.ApplyBatchToTodos(r.state.list(), operations, atomic, func() (string, error) {
+		return idgen.OrDefault(r.IdGenerator).NewId(func() (uint64, error) {
+			sequence++
+			return sequence, nil
+		})
+	})
+	if !changed {
+		return results, nil
+	}
+
+	var events []event
+	for index, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		switch operations[index].Kind {
+		case repositories.BatchCreate:
+			events = append(events, event{Type: repositories.ChangeCreated, Todo: result.Todo, IdSequence: sequence})
+		case repositories.BatchPatch:
+			events = append(events, event{Type: repositories.ChangeUpdated, Todo: result.Todo})
+		case repositories.BatchDelete:
+			events = append(events, event{Type: repositories.ChangeDeleted, Todo: result.Todo})
+		}
+	}
+	err := r.append(events)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ReadTodosAsOf returns the todos as they were at the passed point in time, replaying the events after the
+// last snapshot taken before (from the start of the event log when it isn't kept anymore)
+func (r *EventSourcedTodoRepository) ReadTodosAsOf(asOf time.Time) ([]todo.Todo, error) {
+	r.mutex.Lock()
+	if !asOf.Before(r.state.time) {
+		todos := r.state.list()
+		r.mutex.Unlock()
+		return todos, nil
+	}
+	index := sort.Search(len(r.snapshots), func(index int) bool {
+		return r.snapshots[index].Time.After(asOf)
+	}) - 1
+	var ref *snapshotRef
+	if index >= 0 {
+		ref = &r.snapshots[index]
+	}
+	// the event log is only appended to, so the part written until now can be read without holding the mutex;
+	// the snapshot log isn't compacted until the snapshot is read
+	end := r.eventLogSize
+	r.snapshotMutex.RLock()
+	r.mutex.Unlock()
+
+	state := newProjection()
+	var offset int64
+	if ref != nil {
+		var err error
+		state, err = r.readSnapshot(*ref)
+		if err != nil {
+			r.snapshotMutex.RUnlock()
+			return nil, repositories.StorageError(err)
+		}
+		offset = ref.Offset
+	}
+	r.snapshotMutex.RUnlock()
+	_, err := utils.ReadLogEntries(r.EventLogPath, offset, end, func(payload []byte, _ int64) (bool, error) {
+		var events []event
+		err := json.Unmarshal(payload, &events)
+		if err != nil {
+			return false, err
+		}
+		for _, e := range events {
+			if e.Time.After(asOf) {
+				return false, nil
+			}
+			state.apply(e)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+
+	return state.list(), nil
+}
+
+// ReadTodoHistory returns the changes of the todo with passed id, oldest first, reading only the entries of the
+// event log with changes of the todo
+func (r *EventSourcedTodoRepository) ReadTodoHistory(id string) ([]repositories.TodoChange, error) {
+	r.mutex.Lock()
+	end := r.eventLogSize
+	r.mutex.Unlock()
+
+	offsets, err := r.historyOffsetsOf(id, end)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+	var changes []repositories.TodoChange
+	err = utils.ReadLogEntriesAt(r.EventLogPath, offsets, func(payload []byte) error {
+		var events []event
+		err := json.Unmarshal(payload, &events)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if e.Todo.Id == id {
+				changes = append(changes, repositories.TodoChange{Sequence: e.Sequence, Type: e.Type, Time: e.Time, Todo: e.Todo})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+
+	return changes, nil
+}
+
+// historyOffsetsOf returns the event log offsets of the entries with changes of the todo with passed id, indexing
+// the entries up to end appended since the last call first
+func (r *EventSourcedTodoRepository) historyOffsetsOf(id string, end int64) ([]int64, error) {
+	r.historyMutex.Lock()
+	defer r.historyMutex.Unlock()
+
+	if r.historyOffsets == nil {
+		r.historyOffsets = map[string][]int64{}
+	}
+	if r.historyIndexed < end {
+		indexed, err := utils.ReadLogEntries(r.EventLogPath, r.historyIndexed, end, func(payload []byte, position int64) (bool, error) {
+			// only the ids are needed for the index
+			var events []struct {
+				Todo struct {
+					Id string `json:"id"`
+				} `json:"todo"`
+			}
+			err := json.Unmarshal(payload, &events)
+			if err != nil {
+				return false, err
+			}
+			for _, e := range events {
+				offsets := r.historyOffsets[e.Todo.Id]
+				if len(offsets) == 0 || offsets[len(offsets)-1] != position {
+					r.historyOffsets[e.Todo.Id] = append(offsets, position)
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.historyIndexed = indexed
+	}
+	return slices.Clone(r.historyOffsets[id]), nil
+}
+
+// append durably writes the events as one entry to the event log, applies them to the state and takes a snapshot
+// when due. The caller must hold the mutex.
+func (r *EventSourcedTodoRepository) append(events []event) error {
+	if r.eventLog == nil {
+		return repositories.StorageError(errors.New("repository not initialized"))
+	}
+
+	// the event times never go back, even when the clock does, so that the log is ordered by time
+	now := time.Now().UTC()
+	if now.Before(r.state.time) {
+		now = r.state.time
+	}
+	for index := range events {
+		events[index].Sequence = r.state.sequence + uint64(index) + 1
+		events[index].Time = now
+	}
+
+	err := utils.AppendLogEntry(r.eventLog, &r.eventLogSize, events)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	for _, e := range events {
+		r.state.apply(e)
+	}
+
+	r.pendingEvents += len(events)
+	if r.pendingEvents >= r.snapshotInterval() {
+		// the events are stored, a failed snapshot is taken again with the next event
+		err = r.takeSnapshot()
+		if err != nil {
+			log.Println("taking snapshot failed:", err)
+		}
+	}
+	return nil
+}
+
+// takeSnapshot appends the current state to the snapshot log. The caller must hold the mutex.
+func (r *EventSourcedTodoRepository) takeSnapshot() error {
+	if r.snapshotLog == nil {
+		var err error
+		r.snapshotLog, err = utils.OpenLogForAppending(r.snapshotPath(), r.snapshotLogSize)
+		if err != nil {
+			return err
+		}
+	}
+	taken := snapshot{
+		Sequence:   r.state.sequence,
+		Time:       r.state.time,
+		Offset:     r.eventLogSize,
+		IdSequence: r.state.idSequence,
+		Todos:      r.state.list(),
+	}
+	position := r.snapshotLogSize
+	err := utils.AppendLogEntry(r.snapshotLog, &r.snapshotLogSize, taken)
+	if err != nil {
+		return err
+	}
+
+	r.snapshots = append(r.snapshots, snapshotRef{Sequence: taken.Sequence, Time: taken.Time, Offset: taken.Offset, Position: position})
+	r.pendingEvents = 0
+	if len(r.snapshots) > 2*r.snapshotRetention() {
+		// the snapshot is taken, a failed compaction is tried again with the next snapshot
+		err = r.compactSnapshots()
+		if err != nil {
+			log.Println("compacting snapshot log failed:", err)
+		}
+	}
+	return nil
+}
+
+// compactSnapshots atomically rewrites the snapshot log with the retained last snapshots only. The caller must hold
+// the mutex.
+func (r *EventSourcedTodoRepository) compactSnapshots() error {
+	r.snapshotMutex.Lock()
+	defer r.snapshotMutex.Unlock()
+
+	kept := r.snapshots[len(r.snapshots)-r.snapshotRetention():]
+	base := kept[0].Position
+	file, err := os.Open(r.snapshotPath())
+	if err != nil {
+		return err
+	}
+	content := make([]byte, r.snapshotLogSize-base)
+	_, err = file.ReadAt(content, base)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		return err
+	}
+	err = utils.WriteFileAtomically(r.snapshotPath(), content)
+	if err != nil {
+		return err
+	}
+
+	r.snapshots = slices.Clone(kept)
+	for index := range r.snapshots {
+		r.snapshots[index].Position -= base
+	}
+	r.snapshotLogSize = int64(len(content))
+	// the open file is the replaced one, the next snapshot reopens the log when this fails
+	_ = r.snapshotLog.Close()
+	r.snapshotLog, err = utils.OpenLogForAppending(r.snapshotPath(), r.snapshotLogSize)
+	return err
+}
+
+// loadSnapshots reads the references of the snapshots and returns the size of the valid part of the snapshot log
+func (r *EventSourcedTodoRepository) loadSnapshots() (int64, error) {
+	return utils.ReadLogEntries(r.snapshotPath(), 0, -1, func(payload []byte, position int64) (bool, error) {
+		// the todos aren't needed for the reference
+		var header struct {
+			Sequence uint64    `json:"seq"`
+			Time     time.Time `json:"time"`
+			Offset   int64     `json:"offset"`
+		}
+		err := json.Unmarshal(payload, &header)
+		if err != nil {
+			return false, err
+		}
+		r.snapshots = append(r.snapshots, snapshotRef{Sequence: header.Sequence, Time: header.Time, Offset: header.Offset, Position: position})
+		return true, nil
+	})
+}
+
+// readSnapshot returns the projection stored by the referenced snapshot
+func (r *EventSourcedTodoRepository) readSnapshot(ref snapshotRef) (projection, error) {
+	var state projection
+	found := false
+	_, err := utils.ReadLogEntries(r.snapshotPath(), ref.Position, -1, func(payload []byte, _ int64) (bool, error) {
+		var stored snapshot
+		err := json.Unmarshal(payload, &stored)
+		if err != nil {
+			return false, err
+		}
+		state = newProjection()
+		for _, storedTodo := range stored.Todos {
+			state.todos[storedTodo.Id] = storedTodo
+		}
+		state.sequence = stored.Sequence
+		state.time = stored.Time
+		state.idSequence = stored.IdSequence
+		found = true
+		return false, nil
+	})
+	if err != nil {
+		return projection{}, err
+	}
+	if !found || state.sequence != ref.Sequence {
+		return projection{}, fmt.Errorf("snapshot %d missing in %s", ref.Sequence, r.snapshotPath())
+	}
+	return state, nil
+}
+
+func (r *EventSourcedTodoRepository) snapshotInterval() int {
+	if r.SnapshotInterval <= 0 {
+		return SnapshotIntervalDefault
+	}
+	return r.SnapshotInterval
+}
+
+func (r *EventSourcedTodoRepository) snapshotRetention() int {
+	if r.SnapshotRetention <= 0 {
+		return SnapshotRetentionDefault
+	}
+	return r.SnapshotRetention
+}
+
+func (r *EventSourcedTodoRepository) snapshotPath() string {
+	return r.EventLogPath + SnapshotFileSuffix
+}
+
+// closeLogs closes the open logs. The caller must hold the mutex.
+func (r *EventSourcedTodoRepository) closeLogs() error {
+	var errs []error
+	for _, logFile := range []**os.File{&r.eventLog, &r.snapshotLog} {
+		if *logFile != nil {
+			errs = append(errs, (*logFile).Close())
+			*logFile = nil
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,323 @@
+package eventrepo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// newRepository returns an initialized repository with its logs in a temporary directory, closed when the test ends
+func newRepository(t *testing.T, snapshotInterval int, snapshotRetention int) *EventSourcedTodoRepository {
+	t.Helper()
+	repository := &EventSourcedTodoRepository{EventLogPath: filepath.Join(t.TempDir(), "events.log"),
+		SnapshotInterval: snapshotInterval, SnapshotRetention: snapshotRetention}
+	initialize(t, repository)
+	t.Cleanup(func() { _ = repository.Close() })
+	return repository
+}
+
+// initialize (re)initializes the repository from its logs, e.g. like a restart
+func initialize(t *testing.T, repository *EventSourcedTodoRepository) {
+	t.Helper()
+	err := repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func create(t *testing.T, repository *EventSourcedTodoRepository, title string) todo.Todo {
+	t.Helper()
+	created, err := repository.CreateTodo(todo.Todo{Title: title, Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func update(t *testing.T, repository *EventSourcedTodoRepository, changedTodo todo.Todo) todo.Todo {
+	t.Helper()
+	changedTodo.Version++
+	updated, err := repository.UpdateTodoById(changedTodo.Id, changedTodo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return updated
+}
+
+// titles returns the sorted titles of the passed todos
+func titles(todos []todo.Todo) []string {
+	var todoTitles []string
+	for _, currentTodo := range todos {
+		todoTitles = append(todoTitles, currentTodo.Title)
+	}
+	slices.Sort(todoTitles)
+	return todoTitles
+}
+
+func readTitles(t *testing.T, repository *EventSourcedTodoRepository) []string {
+	t.Helper()
+	todos, err := repository.ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return titles(todos)
+}
+
+func appendToFile(t *testing.T, path string, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString(content)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncatedEventIsDiscardedOnStart(t *testing.T) {
+	repository := newRepository(t, 100, 0)
+	create(t, repository, "kept")
+	err := repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(repository.EventLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash in the middle of the next write leaves a partial entry without newline
+	appendToFile(t, repository.EventLogPath, `1a2b3c4d [{"seq":2,"type":"created","todo":{"id":"2","tit`)
+	initialize(t, repository)
+
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"kept"}) {
+		t.Errorf("todos %v after the restart, want [kept]", got)
+	}
+	// the partial entry is cut off, so that the next entry doesn't follow it
+	create(t, repository, "after")
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"after", "kept"}) {
+		t.Errorf("todos %v after the second restart, want [after kept]", got)
+	}
+	content, err := os.ReadFile(repository.EventLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(content, stored) || bytes.Contains(content, []byte(`"tit`+"\n")) {
+		t.Errorf("event log still contains the partial entry:\n%s", content)
+	}
+}
+
+func TestCorruptEventBeforeOthersFailsStart(t *testing.T) {
+	repository := newRepository(t, 100, 0)
+	create(t, repository, "first")
+	create(t, repository, "second")
+	err := repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(repository.EventLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := bytes.Replace(content, []byte(`"first"`), []byte(`"fir5t"`), 1)
+	err = os.WriteFile(repository.EventLogPath, corrupted, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.Initialize()
+	if !errors.Is(err, repositories.ErrStorage) {
+		t.Errorf("got %v, want a storage error for the corrupt entry", err)
+	}
+}
+
+func TestStartRecoversFromSnapshotAndOffset(t *testing.T) {
+	repository := newRepository(t, 3, 0)
+	for index := 0; index < 8; index++ {
+		create(t, repository, "todo "+strconv.Itoa(index))
+	}
+	want := readTitles(t, repository)
+	if len(repository.snapshots) != 2 {
+		t.Fatalf("%d snapshots after 8 events with interval 3, want 2", len(repository.snapshots))
+	}
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, want) {
+		t.Errorf("todos %v after the restart, want %v", got, want)
+	}
+	// the events after the last snapshot are replayed from its offset
+	if repository.pendingEvents != 2 || repository.state.sequence != 8 {
+		t.Errorf("%d events replayed up to sequence %d, want 2 up to 8", repository.pendingEvents, repository.state.sequence)
+	}
+	if created := create(t, repository, "next"); created.Id != "9" {
+		t.Errorf("created todo %s after the restart, want 9", created.Id)
+	}
+	want = readTitles(t, repository)
+	complete := slices.Clone(repository.snapshots)
+
+	// a snapshot interrupted by a crash is discarded, the state is replayed from the snapshot before
+	err := repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, repository.snapshotPath(), `0badc0de {"seq":12,"todos":[{"id":"1"`)
+	initialize(t, repository)
+	if !slices.Equal(repository.snapshots, complete) {
+		t.Errorf("snapshots %+v after the restart, want the complete ones %+v", repository.snapshots, complete)
+	}
+	if got := readTitles(t, repository); !slices.Equal(got, want) {
+		t.Errorf("todos %v after the restart, want %v", got, want)
+	}
+}
+
+func TestReadTodosAsOfSnapshotBoundaries(t *testing.T) {
+	repository := newRepository(t, 2, 0)
+	var ids []string
+	for index := 0; index < 7; index++ {
+		ids = append(ids, create(t, repository, "todo "+strconv.Itoa(index)).Id)
+	}
+
+	// the todos as of the time of each event are the ones created by it and the events before (or at the same time)
+	for index, id := range ids {
+		history, err := repository.ReadTodoHistory(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		asOf := history[0].Time
+		var want []string
+		for other := range ids {
+			otherHistory, err := repository.ReadTodoHistory(ids[other])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !otherHistory[0].Time.After(asOf) {
+				want = append(want, "todo "+strconv.Itoa(other))
+			}
+		}
+
+		todos, err := repository.ReadTodosAsOf(asOf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(todos); !slices.Equal(got, want) {
+			t.Errorf("todos as of event %d: %v, want %v", index+1, got, want)
+		}
+		todos, err = repository.ReadTodosAsOf(asOf.Add(-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Contains(titles(todos), "todo "+strconv.Itoa(index)) {
+			t.Errorf("todos just before event %d contain its todo", index+1)
+		}
+	}
+}
+
+func TestSnapshotLogIsCompacted(t *testing.T) {
+	repository := newRepository(t, 2, 2)
+	first := create(t, repository, "first")
+	for index := 0; index < 20; index++ {
+		create(t, repository, "todo "+strconv.Itoa(index))
+	}
+
+	if len(repository.snapshots) > 4 {
+		t.Errorf("%d snapshots kept, want at most 4 with retention 2", len(repository.snapshots))
+	}
+	info, err := os.Stat(repository.snapshotPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != repository.snapshotLogSize {
+		t.Errorf("snapshot log of %d bytes, %d expected", info.Size(), repository.snapshotLogSize)
+	}
+
+	// the kept snapshots are found after a restart and past states before them are replayed from the start
+	want := readTitles(t, repository)
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, want) {
+		t.Errorf("todos %v after the restart, want %v", got, want)
+	}
+	history, err := repository.ReadTodoHistory(first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos, err := repository.ReadTodosAsOf(history[0].Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(todos); !slices.Contains(got, "first") || len(got) > 2 {
+		t.Errorf("todos %v as of the first event", got)
+	}
+	// snapshots are still appended after the compaction
+	create(t, repository, "after")
+	create(t, repository, "after")
+	initialize(t, repository)
+	if got := readTitles(t, repository); len(got) != len(want)+2 {
+		t.Errorf("%d todos after the restart, want %d", len(got), len(want)+2)
+	}
+}
+
+func TestReadTodoHistoryReadsIndexedEntries(t *testing.T) {
+	repository := newRepository(t, 100, 0)
+	watched := create(t, repository, "watched")
+	other := create(t, repository, "other")
+	watched = update(t, repository, watched)
+
+	history, err := repository.ReadTodoHistory(watched.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Type != repositories.ChangeCreated || history[1].Type != repositories.ChangeUpdated {
+		t.Fatalf("history %+v, want created and updated", history)
+	}
+
+	// changes after the first read are indexed on the next one, also the ones of a batch and deletions
+	update(t, repository, other)
+	_, err = repository.ApplyBatch([]repositories.BatchOperation{
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "batch", Version: 1}},
+		{Kind: repositories.BatchPatch, Id: watched.Id, Patch: func(currentTodo todo.Todo) (todo.Todo, error) {
+			currentTodo.Version++
+			return currentTodo, nil
+		}},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.DeleteTodoById(watched.Id, todo.Todo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = repository.ReadTodoHistory(watched.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []repositories.ChangeType
+	for _, change := range history {
+		if change.Todo.Id != watched.Id {
+			t.Errorf("change of todo %s in the history of %s", change.Todo.Id, watched.Id)
+		}
+		types = append(types, change.Type)
+	}
+	want := []repositories.ChangeType{repositories.ChangeCreated, repositories.ChangeUpdated, repositories.ChangeUpdated,
+		repositories.ChangeDeleted}
+	if !slices.Equal(types, want) {
+		t.Errorf("history %v, want %v", types, want)
+	}
+	if offsets := repository.historyOffsets[watched.Id]; len(offsets) != 4 {
+		t.Errorf("%d indexed entries of the watched todo, want 4", len(offsets))
+	}
+
+	_, err = repository.ReadTodoHistory("unknown")
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v for an unknown todo, want not found", err)
+	}
+}
@@ -1,11 +1,15 @@
-// Package factory contains logic for creating todo repository instances
+// Package factory contains logic for creating todo, list, webhook and revision repository instances
 package factory
 
 import (
 	"todo-rest-backend/models/configuration"
+	"todo-rest-backend/models/idgen"
 	"todo-rest-backend/models/repositories"
 	"todo-rest-backend/models/repositories/csvrepo"
+	"todo-rest-backend/models/repositories/eventrepo"
+	"todo-rest-backend/models/repositories/journalrepo"
 	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/repositories/sqliterepo"
 )
 
 // GetTodoRepositoryInstance returns the configured todo repository instance (factory design pattern function)
@@ -15,16 +19,128 @@ func GetTodoRepositoryInstance() (repositories.TodoRepository, error) {
 		return nil, err
 	}
 
+	idGenerator, err := newIdGenerator()
+	if err != nil {
+		return nil, err
+	}
+
 	switch repositoryMode {
 	case configuration.MemoryRepository:
 		{
-			return &memrepo.MemoryTodoRepository{}, nil
+			return &memrepo.MemoryTodoRepository{IdGenerator: idGenerator}, nil
 		}
 	case configuration.CsvFileRepository:
 		{
-			return &csvrepo.CsvFileTodoRepository{}, nil
+			return &csvrepo.CsvFileTodoRepository{IdGenerator: idGenerator}, nil
+		}
+	case configuration.SqliteRepository:
+		{
+			databasePath, err := configuration.GetSqliteDatabasePath()
+			if err != nil {
+				return nil, err
+			}
+			return &sqliterepo.SqliteTodoRepository{DatabasePath: databasePath, IdGenerator: idGenerator}, nil
+		}
+	case configuration.JournalRepository:
+		{
+			journalPath, err := configuration.GetJournalPath()
+			if err != nil {
+				return nil, err
+			}
+			compactionSize, err := configuration.GetJournalCompactionSize()
+			if err != nil {
+				return nil, err
+			}
+			return &journalrepo.JournalTodoRepository{JournalPath: journalPath, CompactionSize: compactionSize, IdGenerator: idGenerator}, nil
+		}
+	case configuration.EventSourcedRepository:
+		{
+			eventLogPath, err := configuration.GetEventLogPath()
+			if err != nil {
+				return nil, err
+			}
+			snapshotInterval, err := configuration.GetSnapshotInterval(eventrepo.SnapshotIntervalDefault)
+			if err != nil {
+				return nil, err
+			}
+			snapshotRetention, err := configuration.GetSnapshotRetention(eventrepo.SnapshotRetentionDefault)
+			if err != nil {
+				return nil, err
+			}
+			return &eventrepo.EventSourcedTodoRepository{EventLogPath: eventLogPath, SnapshotInterval: snapshotInterval,
+				SnapshotRetention: snapshotRetention, IdGenerator: idGenerator}, nil
 		}
 	default:
-		return &memrepo.MemoryTodoRepository{}, nil
+		return &memrepo.MemoryTodoRepository{IdGenerator: idGenerator}, nil
+	}
+}
+
+// GetListRepositoryInstance returns the list repository instance fitting the configured repository mode
+// (factory design pattern function). Lists are kept in memory in memory mode and in a csv file otherwise.
+func GetListRepositoryInstance() (repositories.ListRepository, error) {
+	repositoryMode, err := configuration.GetRepositoryMode()
+	if err != nil {
+		return nil, err
+	}
+
+	idGenerator, err := newIdGenerator()
+	if err != nil {
+		return nil, err
+	}
+
+	switch repositoryMode {
+	case configuration.CsvFileRepository, configuration.SqliteRepository, configuration.JournalRepository,
+		configuration.EventSourcedRepository:
+		return &csvrepo.CsvFileListRepository{IdGenerator: idGenerator}, nil
+	default:
+		return &memrepo.MemoryListRepository{IdGenerator: idGenerator}, nil
+	}
+}
+
+// GetWebhookRepositoryInstance returns the webhook repository instance fitting the configured repository mode
+// (factory design pattern function). Webhooks are kept like lists, in memory in memory mode and in a csv file otherwise.
+func GetWebhookRepositoryInstance() (repositories.WebhookRepository, error) {
+	repositoryMode, err := configuration.GetRepositoryMode()
+	if err != nil {
+		return nil, err
+	}
+
+	idGenerator, err := newIdGenerator()
+	if err != nil {
+		return nil, err
+	}
+
+	switch repositoryMode {
+	case configuration.CsvFileRepository, configuration.SqliteRepository, configuration.JournalRepository,
+		configuration.EventSourcedRepository:
+		return &csvrepo.CsvFileWebhookRepository{IdGenerator: idGenerator}, nil
+	default:
+		return &memrepo.MemoryWebhookRepository{IdGenerator: idGenerator}, nil
+	}
+}
+
+// GetRevisionRepositoryInstance returns the revision repository instance fitting the configured repository mode
+// (factory design pattern function). The revisions are kept in memory in memory mode and in a csv file otherwise.
+func GetRevisionRepositoryInstance() (repositories.RevisionRepository, error) {
+	repositoryMode, err := configuration.GetRepositoryMode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch repositoryMode {
+	case configuration.CsvFileRepository, configuration.SqliteRepository, configuration.JournalRepository,
+		configuration.EventSourcedRepository:
+		return &csvrepo.CsvFileRevisionRepository{}, nil
+	default:
+		return &memrepo.MemoryRevisionRepository{}, nil
+	}
+}
+
+// newIdGenerator returns the id generator of the configured id strategy
+func newIdGenerator() (idgen.Generator, error) {
+	idStrategy, err := configuration.GetIdStrategy()
+	if err != nil {
+		return nil, err
 	}
+	return idgen.New(idStrategy)
 }
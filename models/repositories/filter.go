@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// TodoFilter criteria for reading todos
+type TodoFilter struct {
+	Terminated    *bool           // nil matches both states
+	Text          string          // case-insensitive substring of title or description, empty matches all
+	Trashed       bool            // true matches only todos in the trash, false only todos not in the trash
+	Priorities    []todo.Priority // empty matches all priorities
+	DueBefore     *time.Time      // only todos due before, nil matches all
+	DueAfter      *time.Time      // only todos due after, nil matches all
+	CreatedBefore *time.Time      // only todos created before, nil matches all
+	CreatedAfter  *time.Time      // only todos created after, nil matches all
+	Tags          []string        // distinct tags, empty matches all
+	AllTags       bool            // true matches todos having all Tags, false todos having any of them
+	ListId        string          // only todos of the list, empty matches all
+	ParentId      string          // only children of the todo, empty matches all
+}
+
+// Matches checks whether the passed todo fulfills all criteria of the filter
+func (f TodoFilter) Matches(currentTodo todo.Todo) bool {
+	if currentTodo.IsDeleted() != f.Trashed {
+		return false
+	}
+	if f.Terminated != nil && currentTodo.Terminated != *f.Terminated {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(currentTodo.Title), text) &&
+			!strings.Contains(strings.ToLower(currentTodo.Description), text) {
+			return false
+		}
+	}
+	if f.ListId != "" && currentTodo.ListId != f.ListId {
+		return false
+	}
+	if f.ParentId != "" && currentTodo.ParentId != f.ParentId {
+		return false
+	}
+	if len(f.Priorities) > 0 && !slices.Contains(f.Priorities, currentTodo.Priority) {
+		return false
+	}
+	if len(f.Tags) > 0 && !matchesTags(currentTodo.Tags, f.Tags, f.AllTags) {
+		return false
+	}
+	if !isBefore(currentTodo.DueAt, f.DueBefore) || !isAfter(currentTodo.DueAt, f.DueAfter) {
+		return false
+	}
+	if !isBefore(currentTodo.CreatedAt, f.CreatedBefore) || !isAfter(currentTodo.CreatedAt, f.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// matchesTags checks whether the todo tags contain all (or any) of the wanted tags
+func matchesTags(todoTags []string, wantedTags []string, all bool) bool {
+	for _, wantedTag := range wantedTags {
+		found := slices.Contains(todoTags, wantedTag)
+		if all && !found {
+			return false
+		}
+		if !all && found {
+			return true
+		}
+	}
+	return all
+}
+
+// isBefore checks whether value is before limit; a nil limit matches every value, a nil value no limit
+func isBefore(value *time.Time, limit *time.Time) bool {
+	return limit == nil || (value != nil && value.Before(*limit))
+}
+
+// isAfter checks whether value is after limit; a nil limit matches every value, a nil value no limit
+func isAfter(value *time.Time, limit *time.Time) bool {
+	return limit == nil || (value != nil && value.After(*limit))
+}
+
+// FilteringTodoRepository interface implemented by todo repositories able to apply a filter themselves
+// instead of returning all todos
+type FilteringTodoRepository interface {
+	ReadTodosFiltered(TodoFilter) ([]todo.Todo, error)
+}
+
+// ReadTodosFiltered reads the todos matching the filter from the passed repository, letting it filter when it is able to
+func ReadTodosFiltered(todoRepository TodoRepository, filter TodoFilter) ([]todo.Todo, error) {
+	filteringRepository, ok := todoRepository.(FilteringTodoRepository)
+	if ok {
+		return filteringRepository.ReadTodosFiltered(filter)
+	}
+
+	todos, err := todoRepository.ReadTodos()
+	if err != nil {
+		return nil, err
+	}
+	var filteredTodos []todo.Todo
+	for _, currentTodo := range todos {
+		if filter.Matches(currentTodo) {
+			filteredTodos = append(filteredTodos, currentTodo)
+		}
+	}
+	return filteredTodos, nil
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// ErrHistoryUnsupported is returned when the past states of the todos are requested from a repository not keeping them
+var ErrHistoryUnsupported = errors.New("history not supported by the repository")
+
+// ChangeType type of a stored change of a todo
+type ChangeType string
+
+// Types of stored changes
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated" // includes moving to and restoring from the trash
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// TodoChange stored change of a todo with json tags
+type TodoChange struct {
+	Sequence uint64     `json:"sequence"` // position within the changes of all todos
+	Type     ChangeType `json:"type"`
+	Time     time.Time  `json:"time"`
+	Todo     todo.Todo  `json:"todo"` // todo after the change, for deletions the deleted todo
+}
+
+// HistoryTodoRepository interface implemented by todo repositories keeping the past states of the todos
+type HistoryTodoRepository interface {
+	// ReadTodosAsOf returns the todos (including the trashed ones) as they were at the passed point in time
+	ReadTodosAsOf(time.Time) ([]todo.Todo, error)
+	// ReadTodoHistory returns the changes of the todo with passed id, oldest first
+	ReadTodoHistory(string) ([]TodoChange, error)
+}
+
+// ReadTodosAsOf reads the past state of the todos from the passed repository when it keeps the history
+func ReadTodosAsOf(todoRepository TodoRepository, asOf time.Time) ([]todo.Todo, error) {
+	historyRepository, ok := todoRepository.(HistoryTodoRepository)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+	return historyRepository.ReadTodosAsOf(asOf)
+}
+
+// ReadTodoHistory reads the changes of a todo from the passed repository when it keeps the history
+func ReadTodoHistory(todoRepository TodoRepository, id string) ([]TodoChange, error) {
+	historyRepository, ok := todoRepository.(HistoryTodoRepository)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+	return historyRepository.ReadTodoHistory(id)
+}
@@ -0,0 +1,405 @@
+// Package journalrepo contains the repository logic for an append-only journal file with snapshot compaction
+package journalrepo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/utils"
+)
+
+// SnapshotFileSuffix is appended to the journal path to get the snapshot path
+const SnapshotFileSuffix = ".snapshot"
+
+// Operations recorded in the journal
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+	OperationBatch  = "batch" // the records of a batch, written at once so that a batch is replayed completely or not at all
+)
+
+// record is a single journal entry. Applying a record is idempotent, so replaying a journal on top
+// of a snapshot that already contains some of its records gives the same state.
+type record struct {
+	Operation string    `json:"op"`
+	Todo      todo.Todo `json:"todo"`
+	Sequence  uint64    `json:"seq,omitempty"`     // id sequence value after a create
+	Records   []record  `json:"records,omitempty"` // records of a batch
+}
+
+// snapshot is the compacted state
+type snapshot struct {
+	LastSequence uint64      `json:"lastSequence"`
+	Todos        []todo.Todo `json:"todos"`
+}
+
+// JournalTodoRepository type
+type JournalTodoRepository struct {
+	JournalPath    string
+	CompactionSize int64
+	IdGenerator    idgen.Generator
+
+	mutex        sync.Mutex
+	journal      *os.File
+	journalSize  int64
+	todoStore    map[string]todo.Todo
+	lastSequence uint64
+}
+
+// Initialize rebuilds the state from snapshot and journal and opens the journal for appending
+func (j *JournalTodoRepository) Initialize() error {
+	if j.JournalPath == "" {
+		return errors.New("journal path must not be empty")
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.journal != nil {
+		_ = j.journal.Close()
+		j.journal = nil
+	}
+	j.todoStore = map[string]todo.Todo{}
+	j.lastSequence = 0
+
+	err := j.loadSnapshot()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	validSize, err := j.replayJournal()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	journal, err := utils.OpenLogForAppending(j.JournalPath, validSize)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	j.journal = journal
+	j.journalSize = validSize
+	return nil
+}
+
+// Close closes the journal file
+func (j *JournalTodoRepository) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.journal == nil {
+		return nil
+	}
+	err := j.journal.Close()
+	j.journal = nil
+	return err
+}
+
+// ReadTodos returns todo's of the current state
+func (j *JournalTodoRepository) ReadTodos() ([]todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var readTodos []todo.Todo
+	for _, currentTodo := range j.todoStore {
+		readTodos = append(readTodos, currentTodo)
+	}
+
+	return readTodos, nil
+}
+
+// ReadTodosFiltered returns todo's of the current state matching the passed filter
+func (j *JournalTodoRepository) ReadTodosFiltered(filter repositories.TodoFilter) ([]todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var filteredTodos []todo.Todo
+	for _, currentTodo := range j.todoStore {
+		if filter.Matches(currentTodo) {
+			filteredTodos = append(filteredTodos, currentTodo)
+		}
+	}
+
+	return filteredTodos, nil
+}
+
+// ReadTodoById returns todo with passed id when existing
+func (j *JournalTodoRepository) ReadTodoById(id string) (todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	currentTodo, ok := j.todoStore[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+
+	return currentTodo, nil
+}
+
+// CreateTodo appends a create record to the journal and returns the stored todo
+func (j *JournalTodoRepository) CreateTodo(todoToCreate todo.Todo) (todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	sequence := j.lastSequence
+	id, err := idgen.OrDefault(j.IdGenerator).NewId(func() (uint64, error) {
+		sequence++
+		return sequence, nil
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	if _, exists := j.todoStore[id]; exists {
+		return todo.Todo{}, fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+	}
+
+	todoToCreate.Id = id
+	err = j.append(record{Operation: OperationCreate, Todo: todoToCreate, Sequence: sequence})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoToCreate, nil
+}
+
+// UpdateTodoById appends an update record to the journal and returns the updated todo
+func (j *JournalTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	_, ok := j.todoStore[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("item with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+	}
+
+	todoUpdate.Id = id
+	err := j.append(record{Operation: OperationUpdate, Todo: todoUpdate})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoUpdate, nil
+}
+
+// PatchTodoById appends an update record with the result of the passed patch function and returns the patched todo
+func (j *JournalTodoRepository) PatchTodoById(id string, patch func(todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	currentTodo, ok := j.todoStore[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("item with id %s %w. Patching not possible", id, repositories.ErrNotFound)
+	}
+
+	todoPatched, err := patch(currentTodo)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	todoPatched.Id = id
+
+	err = j.append(record{Operation: OperationUpdate, Todo: todoPatched})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoPatched, nil
+}
+
+// DeleteTodoById appends a delete record to the journal and returns the deleted todo
+func (j *JournalTodoRepository) DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	deletedTodo, ok := j.todoStore[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("todo with ID %s %w", id, repositories.ErrNotFound)
+	}
+	if todoDelete.Version != 0 && todoDelete.Version != deletedTodo.Version {
+		return todo.Todo{}, repositories.ErrVersionMismatch
+	}
+
+	err := j.append(record{Operation: OperationDelete, Todo: todo.Todo{Id: id}})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return deletedTodo, nil
+}
+
+// ApplyBatch appends a single batch record with the successful operations to the journal and returns their results
+func (j *JournalTodoRepository) ApplyBatch(operations []repositories.BatchOperation, atomic bool) ([]repositories.BatchResult, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var todos []todo.Todo
+	for _, currentTodo := range j.todoStore {
+		todos = append(todos, currentTodo)
+	}
+	sequence := j.lastSequence
+	_, results, changed := repositories.ApplyBatchToTodos(todos, operations, atomic, func() (string, error) {
+		return idgen.OrDefault(j.IdGenerator).NewId(func() (uint64, error) {
+			sequence++
+			return sequence, nil
+		})
+	})
+	if !changed {
+		return results, nil
+	}
+
+	batch := record{Operation: OperationBatch}
+	for index, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		switch operations[index].Kind {
+		case repositories.BatchCreate:
+			batch.Records = append(batch.Records, record{Operation: OperationCreate, Todo: result.Todo, Sequence: sequence})
+		case repositories.BatchPatch:
+			batch.Records = append(batch.Records, record{Operation: OperationUpdate, Todo: result.Todo})
+		case repositories.BatchDelete:
+			batch.Records = append(batch.Records, record{Operation: OperationDelete, Todo: todo.Todo{Id: result.Todo.Id}})
+		}
+	}
+	err := j.append(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// append durably writes the record to the journal, applies it to the state and compacts when due.
+// A failed write is cut off again and leaves the state unchanged, a failed compaction doesn't fail the append.
+// The caller must hold the mutex.
+func (j *JournalTodoRepository) append(rec record) error {
+	if j.journal == nil {
+		return repositories.StorageError(errors.New("repository not initialized"))
+	}
+
+	err := utils.AppendLogEntry(j.journal, &j.journalSize, rec)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	j.apply(rec)
+
+	if j.CompactionSize > 0 && j.journalSize >= j.CompactionSize {
+		// the record is stored, a failed compaction is tried again with the next record
+		err = j.compact()
+		if err != nil {
+			log.Println("compacting journal failed:", err)
+		}
+	}
+	return nil
+}
+
+// apply applies the record to the in-memory state
+func (j *JournalTodoRepository) apply(rec record) {
+	switch rec.Operation {
+	case OperationCreate, OperationUpdate:
+		// records written before versions, priorities and tags existed
+		rec.Todo.Version = max(rec.Todo.Version, 1)
+		if rec.Todo.Priority == "" {
+			rec.Todo.Priority = todo.PriorityNormal
+		}
+		if rec.Todo.Tags == nil {
+			rec.Todo.Tags = []string{}
+		}
+		j.todoStore[rec.Todo.Id] = rec.Todo
+		if rec.Sequence > j.lastSequence {
+			j.lastSequence = rec.Sequence
+		}
+	case OperationDelete:
+		delete(j.todoStore, rec.Todo.Id)
+	case OperationBatch:
+		for _, batchRecord := range rec.Records {
+			j.apply(batchRecord)
+		}
+	}
+}
+
+// compact writes the current state as snapshot and empties the journal.
+// A crash between both steps is harmless, because replaying the journal on the new snapshot is idempotent.
+func (j *JournalTodoRepository) compact() error {
+	compacted := snapshot{LastSequence: j.lastSequence}
+	for _, currentTodo := range j.todoStore {
+		compacted.Todos = append(compacted.Todos, currentTodo)
+	}
+
+	content, err := json.Marshal(compacted)
+	if err != nil {
+		return err
+	}
+
+	err = utils.WriteFileAtomically(j.snapshotPath(), content)
+	if err != nil {
+		return err
+	}
+
+	err = j.journal.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = j.journal.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	j.journalSize = 0
+
+	return j.journal.Sync()
+}
+
+func (j *JournalTodoRepository) snapshotPath() string {
+	return j.JournalPath + SnapshotFileSuffix
+}
+
+func (j *JournalTodoRepository) loadSnapshot() error {
+	content, err := os.ReadFile(j.snapshotPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var compacted snapshot
+	err = json.Unmarshal(content, &compacted)
+	if err != nil {
+		return fmt.Errorf("corrupt snapshot %s: %w", j.snapshotPath(), err)
+	}
+
+	for _, currentTodo := range compacted.Todos {
+		currentTodo.Version = max(currentTodo.Version, 1)
+		if currentTodo.Priority == "" {
+			currentTodo.Priority = todo.PriorityNormal
+		}
+		if currentTodo.Tags == nil {
+			currentTodo.Tags = []string{}
+		}
+		j.todoStore[currentTodo.Id] = currentTodo
+	}
+	j.lastSequence = compacted.LastSequence
+	return nil
+}
+
+// replayJournal applies all complete records of the journal and returns the size of the valid part.
+// An incomplete or corrupt last record is ignored, a corrupt record followed by others is an error.
+func (j *JournalTodoRepository) replayJournal() (int64, error) {
+	return utils.ReadLogEntries(j.JournalPath, 0, -1, func(payload []byte, _ int64) (bool, error) {
+		var rec record
+		err := json.Unmarshal(payload, &rec)
+		if err != nil {
+			return false, err
+		}
+		j.apply(rec)
+		return true, nil
+	})
+}
@@ -0,0 +1,234 @@
+package journalrepo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// newRepository returns an initialized repository with its journal in a temporary directory, closed when the test ends
+func newRepository(t *testing.T, compactionSize int64) *JournalTodoRepository {
+	t.Helper()
+	repository := &JournalTodoRepository{JournalPath: filepath.Join(t.TempDir(), "journal.log"),
+		CompactionSize: compactionSize}
+	initialize(t, repository)
+	t.Cleanup(func() { _ = repository.Close() })
+	return repository
+}
+
+// initialize (re)initializes the repository from its snapshot and journal, e.g. like a restart
+func initialize(t *testing.T, repository *JournalTodoRepository) {
+	t.Helper()
+	err := repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func create(t *testing.T, repository *JournalTodoRepository, title string) todo.Todo {
+	t.Helper()
+	created, err := repository.CreateTodo(todo.Todo{Title: title, Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func readTitles(t *testing.T, repository *JournalTodoRepository) []string {
+	t.Helper()
+	todos, err := repository.ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, currentTodo := range todos {
+		titles = append(titles, currentTodo.Title)
+	}
+	slices.Sort(titles)
+	return titles
+}
+
+func readFile(t *testing.T, fileName string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func appendToFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.Write(content)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournalIsReplayedOnStart(t *testing.T) {
+	repository := newRepository(t, 0)
+	first := create(t, repository, "first")
+	second := create(t, repository, "second")
+	first.Title = "updated"
+	first.Version++
+	_, err := repository.UpdateTodoById(first.Id, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.DeleteTodoById(second.Id, todo.Todo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.ApplyBatch([]repositories.BatchOperation{
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "batch", Version: 1}},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"batch", "updated"}) {
+		t.Errorf("todos %v after the restart, want [batch updated]", got)
+	}
+	// the id sequence continues after the replayed creations
+	if created := create(t, repository, "next"); created.Id != "4" {
+		t.Errorf("created todo %s after the restart, want 4", created.Id)
+	}
+}
+
+func TestTruncatedRecordIsIgnoredOnStart(t *testing.T) {
+	repository := newRepository(t, 0)
+	create(t, repository, "kept")
+	stored := readFile(t, repository.JournalPath)
+
+	// a crash in the middle of the next write leaves a partial record without newline
+	appendToFile(t, repository.JournalPath, []byte(`1a2b3c4d {"op":"create","todo":{"id":"2","tit`))
+	initialize(t, repository)
+
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"kept"}) {
+		t.Errorf("todos %v after the restart, want [kept]", got)
+	}
+	// the partial record is cut off, so that the next record doesn't follow it
+	create(t, repository, "after")
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"after", "kept"}) {
+		t.Errorf("todos %v after the second restart, want [after kept]", got)
+	}
+	if content := readFile(t, repository.JournalPath); !bytes.HasPrefix(content, stored) ||
+		bytes.Contains(content, []byte("1a2b3c4d")) {
+		t.Errorf("journal still contains the partial record:\n%s", content)
+	}
+}
+
+func TestCorruptRecordBeforeOthersFailsStart(t *testing.T) {
+	repository := newRepository(t, 0)
+	create(t, repository, "first")
+	create(t, repository, "second")
+	err := repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted := bytes.Replace(readFile(t, repository.JournalPath), []byte(`"first"`), []byte(`"fir5t"`), 1)
+	err = os.WriteFile(repository.JournalPath, corrupted, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.Initialize()
+	if !errors.Is(err, repositories.ErrStorage) {
+		t.Errorf("got %v, want a storage error for the corrupt record", err)
+	}
+}
+
+func TestCompactionKeepsState(t *testing.T) {
+	repository := newRepository(t, 512)
+	for index := 0; index < 10; index++ {
+		create(t, repository, "todo "+strconv.Itoa(index))
+	}
+	want := readTitles(t, repository)
+
+	if repository.journalSize >= repository.CompactionSize {
+		t.Errorf("journal of %d bytes not compacted at %d", repository.journalSize, repository.CompactionSize)
+	}
+	if _, err := os.Stat(repository.snapshotPath()); err != nil {
+		t.Fatalf("no snapshot after the compaction: %v", err)
+	}
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, want) {
+		t.Errorf("todos %v after the restart, want %v", got, want)
+	}
+	if created := create(t, repository, "next"); created.Id != "11" {
+		t.Errorf("created todo %s after the restart, want 11", created.Id)
+	}
+}
+
+func TestCrashDuringCompactionKeepsState(t *testing.T) {
+	repository := newRepository(t, 0)
+	first := create(t, repository, "first")
+	create(t, repository, "second")
+	first.Title = "updated"
+	first.Version++
+	_, err := repository.UpdateTodoById(first.Id, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal := readFile(t, repository.JournalPath)
+	want := readTitles(t, repository)
+
+	// a crash after the snapshot was renamed into place leaves the journal with records already in the snapshot
+	repository.mutex.Lock()
+	err = repository.compact()
+	repository.mutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(repository.JournalPath, journal, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, want) {
+		t.Errorf("todos %v after the restart, want %v", got, want)
+	}
+	if created := create(t, repository, "next"); created.Id != "3" {
+		t.Errorf("created todo %s after the restart, want 3", created.Id)
+	}
+}
+
+func TestFailedCompactionDoesNotFailWrite(t *testing.T) {
+	repository := newRepository(t, 1)
+	// the snapshot can't be renamed onto a directory
+	err := os.Mkdir(repository.snapshotPath(), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create(t, repository, "stored")
+	create(t, repository, "stored too")
+
+	err = os.Remove(repository.snapshotPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"stored", "stored too"}) {
+		t.Errorf("todos %v after the restart, want both", got)
+	}
+}
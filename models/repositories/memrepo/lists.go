@@ -0,0 +1,109 @@
+package memrepo
+
+import (
+	"fmt"
+	"sync"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/list"
+	"todo-rest-backend/models/repositories"
+)
+
+// MemoryListRepository type (safe for concurrent use)
+type MemoryListRepository struct {
+	IdGenerator idgen.Generator
+
+	mutex        sync.RWMutex
+	lastSequence uint64
+	listStore    map[string]list.List
+}
+
+// Initialize initializes the repository
+func (m *MemoryListRepository) Initialize() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.listStore = map[string]list.List{}
+	m.lastSequence = 0
+	return nil
+}
+
+// ReadLists returns list's stored in memory
+func (m *MemoryListRepository) ReadLists() ([]list.List, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var readLists []list.List
+	for _, currentList := range m.listStore {
+		readLists = append(readLists, currentList)
+	}
+
+	return readLists, nil
+}
+
+// ReadListById returns list stored in memory with passed id when existing
+func (m *MemoryListRepository) ReadListById(id string) (list.List, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	currentList, ok := m.listStore[id]
+	if !ok {
+		return list.List{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+
+	return currentList, nil
+}
+
+// CreateList stores the passed list in memory and returns the stored list
+func (m *MemoryListRepository) CreateList(listToCreate list.List) (list.List, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.listStore == nil {
+		m.listStore = map[string]list.List{}
+	}
+
+	id, err := idgen.OrDefault(m.IdGenerator).NewId(func() (uint64, error) {
+		m.lastSequence++
+		return m.lastSequence, nil
+	})
+	if err != nil {
+		return list.List{}, err
+	}
+	if _, exists := m.listStore[id]; exists {
+		return list.List{}, fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+	}
+
+	listToCreate.Id = id
+	m.listStore[id] = listToCreate
+
+	return listToCreate, nil
+}
+
+// UpdateListById updates the passed list by id in memory and returns the updated list
+func (m *MemoryListRepository) UpdateListById(id string, listUpdate list.List) (list.List, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.listStore[id]; !ok {
+		return list.List{}, fmt.Errorf("list with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+	}
+
+	listUpdate.Id = id
+	m.listStore[id] = listUpdate
+
+	return listUpdate, nil
+}
+
+// DeleteListById deletes the list by id in memory and returns the deleted list
+func (m *MemoryListRepository) DeleteListById(id string) (list.List, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	deletedList, ok := m.listStore[id]
+	if !ok {
+		return list.List{}, fmt.Errorf("list with ID %s %w", id, repositories.ErrNotFound)
+	}
+	delete(m.listStore, id)
+
+	return deletedList, nil
+}
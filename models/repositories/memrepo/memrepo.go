@@ -2,28 +2,57 @@
 package memrepo
 
 import (
-	"errors"
 	"fmt"
-	"strconv"
+	"sync"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
 	"todo-rest-backend/models/todo"
 )
 
-// MemoryTodoRepository type
+// MemoryTodoRepository type (safe for concurrent use)
 type MemoryTodoRepository struct {
-	todoStore []todo.Todo
+	IdGenerator idgen.Generator
+
+	mutex        sync.RWMutex
+	lastSequence uint64
+	todoStore    []todo.Todo
+	todoIndex    map[string]int // maps the todo id to its position in todoStore
 }
 
 // Initialize initializes the repository
 func (m *MemoryTodoRepository) Initialize() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	m.todoStore = []todo.Todo{}
+	m.todoIndex = map[string]int{}
+	m.lastSequence = 0
 	return nil
 }
 
 // ReadTodos returns todo's stored in memory
 func (m *MemoryTodoRepository) ReadTodos() ([]todo.Todo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
 	return clone(m.todoStore), nil
 }
 
+// ReadTodosFiltered returns todo's stored in memory matching the passed filter
+func (m *MemoryTodoRepository) ReadTodosFiltered(filter repositories.TodoFilter) ([]todo.Todo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var filteredTodos []todo.Todo
+	for _, currentTodo := range m.todoStore {
+		if filter.Matches(currentTodo) {
+			filteredTodos = append(filteredTodos, currentTodo)
+		}
+	}
+
+	return filteredTodos, nil
+}
+
 func clone(todos []todo.Todo) []todo.Todo {
 	var todoStoreClone []todo.Todo
 
@@ -36,39 +65,125 @@ func clone(todos []todo.Todo) []todo.Todo {
 
 // ReadTodoById returns todo stored in memory with passed id when existing
 func (m *MemoryTodoRepository) ReadTodoById(id string) (todo.Todo, error) {
-	for _, currentTodo := range m.todoStore {
-		if id == currentTodo.Id {
-			return currentTodo, nil
-		}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	index, ok := m.todoIndex[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("id %w", repositories.ErrNotFound)
 	}
 
-	return todo.Todo{}, errors.New("id not found")
+	return m.todoStore[index], nil
 }
 
 // CreateTodo stores the passed todo in memory and returns the stored todo
 func (m *MemoryTodoRepository) CreateTodo(todo todo.Todo) (todo.Todo, error) {
-	todoCount := len(m.todoStore) + 1
-	todo.Id = strconv.Itoa(todoCount)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.todoIndex == nil {
+		m.todoIndex = map[string]int{}
+	}
+
+	id, err := idgen.OrDefault(m.IdGenerator).NewId(func() (uint64, error) {
+		m.lastSequence++
+		return m.lastSequence, nil
+	})
+	if err != nil {
+		return todo, err
+	}
+	if _, exists := m.todoIndex[id]; exists {
+		return todo, fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+	}
+
+	todo.Id = id
 	m.todoStore = append(m.todoStore, todo)
+	m.todoIndex[todo.Id] = len(m.todoStore) - 1
 
 	return todo, nil
 }
 
 // UpdateTodoById updates the passed todo by id in memory and returns the updated todo
 func (m *MemoryTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
-	for index, currentTodo := range m.todoStore {
-		if currentTodo.Id == id {
-			// update todo based on input
-			todoUpdate.Id = id
-			m.todoStore[index] = todoUpdate
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-			return todoUpdate, nil
-		}
+	index, ok := m.todoIndex[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("item with id %s %w. Updating not possible", id, repositories.ErrNotFound)
 	}
 
-	return todo.Todo{}, fmt.Errorf("item with id %s not found. Updating not possible", id)
+	// update todo based on input
+	todoUpdate.Id = id
+	m.todoStore[index] = todoUpdate
+
+	return todoUpdate, nil
 }
 
+// PatchTodoById applies the passed patch function to the todo with passed id in memory and returns the patched todo
+func (m *MemoryTodoRepository) PatchTodoById(id string, patch func(todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	index, ok := m.todoIndex[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("item with id %s %w. Patching not possible", id, repositories.ErrNotFound)
+	}
+
+	todoPatched, err := patch(m.todoStore[index])
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	todoPatched.Id = id
+	m.todoStore[index] = todoPatched
+
+	return todoPatched, nil
+}
+
+// DeleteTodoById deletes the todo by id in memory and returns the deleted todo
 func (m *MemoryTodoRepository) DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
-	return todoDelete, nil
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	index, ok := m.todoIndex[id]
+	if !ok {
+		return todo.Todo{}, fmt.Errorf("todo with ID %s %w", id, repositories.ErrNotFound)
+	}
+
+	deletedTodo := m.todoStore[index]
+	if todoDelete.Version != 0 && todoDelete.Version != deletedTodo.Version {
+		return todo.Todo{}, repositories.ErrVersionMismatch
+	}
+	m.todoStore = append(m.todoStore[:index], m.todoStore[index+1:]...)
+	delete(m.todoIndex, id)
+	// positions behind the deleted todo moved by one
+	for position := index; position < len(m.todoStore); position++ {
+		m.todoIndex[m.todoStore[position].Id] = position
+	}
+
+	return deletedTodo, nil
+}
+
+// ApplyBatch applies the batch operations to the todos in memory and returns their results
+func (m *MemoryTodoRepository) ApplyBatch(operations []repositories.BatchOperation, atomic bool) ([]repositories.BatchResult, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	todos, results, changed := repositories.ApplyBatchToTodos(m.todoStore, operations, atomic, func() (string, error) {
+		return idgen.OrDefault(m.IdGenerator).NewId(func() (uint64, error) {
+			m.lastSequence++
+			return m.lastSequence, nil
+		})
+	})
+	if !changed {
+		return results, nil
+	}
+
+	m.todoStore = todos
+	m.todoIndex = map[string]int{}
+	for position, currentTodo := range m.todoStore {
+		m.todoIndex[currentTodo.Id] = position
+	}
+
+	return results, nil
 }
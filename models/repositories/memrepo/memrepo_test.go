@@ -0,0 +1,198 @@
+package memrepo
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// stressWorkers number of goroutines per kind of operation, stressRounds number of operations of each goroutine
+const (
+	stressWorkers = 8
+	stressRounds  = 200
+)
+
+// TestConcurrentOperations runs all repository methods concurrently (run with -race) and checks the invariants:
+// unique ids, no lost updates and an index consistent with the store
+func TestConcurrentOperations(t *testing.T) {
+	repository := &MemoryTodoRepository{}
+	err := repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter, err := repository.CreateTodo(todo.Todo{Title: "counter", Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wait sync.WaitGroup
+	errs := make(chan error, 6*stressWorkers*stressRounds)
+	createdIds := make([][]string, stressWorkers)
+	for worker := 0; worker < stressWorkers; worker++ {
+		wait.Add(6)
+
+		// creates todos and deletes every other one of them again
+		go func() {
+			defer wait.Done()
+			for round := 0; round < stressRounds; round++ {
+				created, err := repository.CreateTodo(todo.Todo{Title: "worker " + strconv.Itoa(worker), Version: 1})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				createdIds[worker] = append(createdIds[worker], created.Id)
+				if round%2 == 1 {
+					_, err = repository.DeleteTodoById(created.Id, todo.Todo{Version: created.Version})
+					if err != nil {
+						errs <- err
+					}
+				}
+			}
+		}()
+
+		// increments the version of the counter, every patch has to be kept
+		go func() {
+			defer wait.Done()
+			for round := 0; round < stressRounds; round++ {
+				_, err := repository.PatchTodoById(counter.Id, func(currentTodo todo.Todo) (todo.Todo, error) {
+					currentTodo.Version++
+					return currentTodo, nil
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+
+		// replaces the counter without changing its version
+		go func() {
+			defer wait.Done()
+			for round := 0; round < stressRounds; round++ {
+				_, err := repository.PatchTodoById(counter.Id, func(currentTodo todo.Todo) (todo.Todo, error) {
+					currentTodo.Description = strconv.Itoa(round)
+					return currentTodo, nil
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+
+		// updates todos which may be deleted in the meantime
+		go func() {
+			defer wait.Done()
+			for round := 0; round < stressRounds; round++ {
+				todos, err := repository.ReadTodos()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				target := todos[round%len(todos)]
+				if target.Id == counter.Id {
+					continue
+				}
+				target.Terminated = true
+				_, err = repository.UpdateTodoById(target.Id, target)
+				if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+					errs <- err
+				}
+			}
+		}()
+
+		// reads the todos one by one, every listed todo is found unless it is deleted in the meantime
+		go func() {
+			defer wait.Done()
+			for round := 0; round < stressRounds; round++ {
+				todos, err := repository.ReadTodos()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				for _, currentTodo := range todos {
+					todoRead, err := repository.ReadTodoById(currentTodo.Id)
+					if errors.Is(err, repositories.ErrNotFound) {
+						continue
+					}
+					if err != nil {
+						errs <- err
+						continue
+					}
+					if todoRead.Id != currentTodo.Id {
+						errs <- errors.New("read todo " + todoRead.Id + " instead of " + currentTodo.Id)
+					}
+				}
+			}
+		}()
+
+		// creates and deletes todos in batches
+		go func() {
+			defer wait.Done()
+			for round := 0; round < stressRounds; round++ {
+				results, err := repository.ApplyBatch([]repositories.BatchOperation{
+					{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "batch", Version: 1}},
+					{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "batch", Version: 1}},
+				}, true)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				_, err = repository.DeleteTodoById(results[0].Todo.Id, todo.Todo{})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wait.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	counterRead, err := repository.ReadTodoById(counter.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := counter.Version + stressWorkers*stressRounds; counterRead.Version != want {
+		t.Errorf("counter version %d, want %d (lost updates)", counterRead.Version, want)
+	}
+
+	ids := map[string]bool{}
+	for _, workerIds := range createdIds {
+		for _, id := range workerIds {
+			if ids[id] {
+				t.Errorf("id %s handed out twice", id)
+			}
+			ids[id] = true
+		}
+	}
+	todos, err := repository.ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// counter, the kept half of the created todos and one todo per batch
+	if want := 1 + stressWorkers*stressRounds/2 + stressWorkers*stressRounds; len(todos) != want {
+		t.Errorf("%d todos stored, want %d", len(todos), want)
+	}
+	checkIndex(t, repository)
+}
+
+// checkIndex checks that the index maps every stored todo to its position and nothing else
+func checkIndex(t *testing.T, repository *MemoryTodoRepository) {
+	t.Helper()
+	repository.mutex.RLock()
+	defer repository.mutex.RUnlock()
+
+	if len(repository.todoIndex) != len(repository.todoStore) {
+		t.Errorf("index has %d entries for %d todos", len(repository.todoIndex), len(repository.todoStore))
+	}
+	for position, currentTodo := range repository.todoStore {
+		indexed, ok := repository.todoIndex[currentTodo.Id]
+		if !ok || indexed != position {
+			t.Errorf("todo %s at position %d is indexed at %d (%t)", currentTodo.Id, position, indexed, ok)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package memrepo
+
+import (
+	"fmt"
+	"sync"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/revision"
+)
+
+// MemoryRevisionRepository type (safe for concurrent use)
+type MemoryRevisionRepository struct {
+	mutex         sync.RWMutex
+	revisionStore map[string][]revision.Revision // by todo id, oldest first
+	changes       map[string][]revisionRef       // revisions by change id, in the order they were recorded
+	lastBySession map[string]string              // id of the last change by undo session key
+}
+
+// revisionRef reference of a stored revision
+type revisionRef struct {
+	todoId string
+	number int
+}
+
+// Initialize initializes the repository
+func (m *MemoryRevisionRepository) Initialize() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.revisionStore = map[string][]revision.Revision{}
+	m.changes = map[string][]revisionRef{}
+	m.lastBySession = map[string]string{}
+	return nil
+}
+
+// ReadRevisions returns the revisions of the todo with passed id stored in memory, oldest first
+func (m *MemoryRevisionRepository) ReadRevisions(todoId string) ([]revision.Revision, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var readRevisions []revision.Revision
+	readRevisions = append(readRevisions, m.revisionStore[todoId]...)
+
+	return readRevisions, nil
+}
+
+// ReadRevision returns the revision with passed number of the todo with passed id when existing
+func (m *MemoryRevisionRepository) ReadRevision(todoId string, number int) (revision.Revision, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	revisions := m.revisionStore[todoId]
+	if number < 1 || number > len(revisions) {
+		return revision.Revision{}, fmt.Errorf("revision %w", repositories.ErrNotFound)
+	}
+
+	return revisions[number-1], nil
+}
+
+// ReadLastChange returns the revisions of the change recorded last for the passed undo session when existing
+func (m *MemoryRevisionRepository) ReadLastChange(session string) ([]revision.Revision, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	changeId, ok := m.lastBySession[session]
+	if !ok {
+		return nil, fmt.Errorf("change %w", repositories.ErrNotFound)
+	}
+
+	var changeRevisions []revision.Revision
+	for _, ref := range m.changes[changeId] {
+		changeRevisions = append(changeRevisions, m.revisionStore[ref.todoId][ref.number-1])
+	}
+	return changeRevisions, nil
+}
+
+// CreateRevision stores the passed revision in memory as next revision of its todo and returns the stored revision
+func (m *MemoryRevisionRepository) CreateRevision(revisionToCreate revision.Revision) (revision.Revision, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.revisionStore == nil {
+		m.revisionStore = map[string][]revision.Revision{}
+		m.changes = map[string][]revisionRef{}
+		m.lastBySession = map[string]string{}
+	}
+
+	revisionToCreate.Number = len(m.revisionStore[revisionToCreate.TodoId]) + 1
+	m.revisionStore[revisionToCreate.TodoId] = append(m.revisionStore[revisionToCreate.TodoId], revisionToCreate)
+	// changes without id (of the backend itself) aren't read by their id
+	if revisionToCreate.ChangeId != "" {
+		m.changes[revisionToCreate.ChangeId] = append(m.changes[revisionToCreate.ChangeId],
+			revisionRef{todoId: revisionToCreate.TodoId, number: revisionToCreate.Number})
+	}
+	if revisionToCreate.Session != "" && revisionToCreate.ChangeId != "" {
+		m.lastBySession[revisionToCreate.Session] = revisionToCreate.ChangeId
+	}
+
+	return revisionToCreate, nil
+}
@@ -0,0 +1,174 @@
+package memrepo
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/webhook"
+)
+
+// MemoryWebhookRepository type (safe for concurrent use)
+type MemoryWebhookRepository struct {
+	IdGenerator idgen.Generator
+
+	mutex        sync.RWMutex
+	lastSequence uint64
+	webhookStore map[string]webhook.Webhook
+
+	lastDeadLetterSequence uint64
+	deadLetters            []webhook.DeadLetter // oldest first
+}
+
+// Initialize initializes the repository
+func (m *MemoryWebhookRepository) Initialize() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.webhookStore = map[string]webhook.Webhook{}
+	m.lastSequence = 0
+	m.deadLetters = nil
+	m.lastDeadLetterSequence = 0
+	return nil
+}
+
+// ReadWebhooks returns webhook's stored in memory
+func (m *MemoryWebhookRepository) ReadWebhooks() ([]webhook.Webhook, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var readWebhooks []webhook.Webhook
+	for _, currentWebhook := range m.webhookStore {
+		readWebhooks = append(readWebhooks, currentWebhook)
+	}
+
+	return readWebhooks, nil
+}
+
+// ReadWebhookById returns webhook stored in memory with passed id when existing
+func (m *MemoryWebhookRepository) ReadWebhookById(id string) (webhook.Webhook, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	currentWebhook, ok := m.webhookStore[id]
+	if !ok {
+		return webhook.Webhook{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+
+	return currentWebhook, nil
+}
+
+// CreateWebhook stores the passed webhook in memory and returns the stored webhook
+func (m *MemoryWebhookRepository) CreateWebhook(webhookToCreate webhook.Webhook) (webhook.Webhook, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.webhookStore == nil {
+		m.webhookStore = map[string]webhook.Webhook{}
+	}
+
+	id, err := idgen.OrDefault(m.IdGenerator).NewId(func() (uint64, error) {
+		m.lastSequence++
+		return m.lastSequence, nil
+	})
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+	if _, exists := m.webhookStore[id]; exists {
+		return webhook.Webhook{}, fmt.Errorf("%w: generated id %s already exists", repositories.ErrConflict, id)
+	}
+
+	webhookToCreate.Id = id
+	m.webhookStore[id] = webhookToCreate
+
+	return webhookToCreate, nil
+}
+
+// UpdateWebhookById updates the passed webhook by id in memory and returns the updated webhook
+func (m *MemoryWebhookRepository) UpdateWebhookById(id string, webhookUpdate webhook.Webhook) (webhook.Webhook, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.webhookStore[id]; !ok {
+		return webhook.Webhook{}, fmt.Errorf("webhook with id %s %w. Updating not possible", id, repositories.ErrNotFound)
+	}
+
+	webhookUpdate.Id = id
+	m.webhookStore[id] = webhookUpdate
+
+	return webhookUpdate, nil
+}
+
+// DeleteWebhookById deletes the webhook by id in memory and returns the deleted webhook
+func (m *MemoryWebhookRepository) DeleteWebhookById(id string) (webhook.Webhook, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	deletedWebhook, ok := m.webhookStore[id]
+	if !ok {
+		return webhook.Webhook{}, fmt.Errorf("webhook with ID %s %w", id, repositories.ErrNotFound)
+	}
+	delete(m.webhookStore, id)
+
+	return deletedWebhook, nil
+}
+
+// ReadDeadLetters returns the dead letters stored in memory, oldest first
+func (m *MemoryWebhookRepository) ReadDeadLetters() ([]webhook.DeadLetter, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return slices.Clone(m.deadLetters), nil
+}
+
+// ReadDeadLetterById returns the dead letter stored in memory with passed id when existing
+func (m *MemoryWebhookRepository) ReadDeadLetterById(id string) (webhook.DeadLetter, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	index := m.deadLetterIndex(id)
+	if index < 0 {
+		return webhook.DeadLetter{}, fmt.Errorf("dead letter %w", repositories.ErrNotFound)
+	}
+
+	return m.deadLetters[index], nil
+}
+
+// CreateDeadLetter stores the passed dead letter in memory, dropping the oldest ones beyond webhook.DeadLetterSize,
+// and returns the stored dead letter
+func (m *MemoryWebhookRepository) CreateDeadLetter(deadLetterToCreate webhook.DeadLetter) (webhook.DeadLetter, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.lastDeadLetterSequence++
+	deadLetterToCreate.Id = strconv.FormatUint(m.lastDeadLetterSequence, 10)
+	m.deadLetters = append(m.deadLetters, deadLetterToCreate)
+	if len(m.deadLetters) > webhook.DeadLetterSize {
+		m.deadLetters = slices.Delete(m.deadLetters, 0, len(m.deadLetters)-webhook.DeadLetterSize)
+	}
+
+	return deadLetterToCreate, nil
+}
+
+// DeleteDeadLetterById deletes the dead letter by id in memory and returns the deleted dead letter
+func (m *MemoryWebhookRepository) DeleteDeadLetterById(id string) (webhook.DeadLetter, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	index := m.deadLetterIndex(id)
+	if index < 0 {
+		return webhook.DeadLetter{}, fmt.Errorf("dead letter %w", repositories.ErrNotFound)
+	}
+	deletedDeadLetter := m.deadLetters[index]
+	m.deadLetters = slices.Delete(m.deadLetters, index, index+1)
+
+	return deletedDeadLetter, nil
+}
+
+func (m *MemoryWebhookRepository) deadLetterIndex(id string) int {
+	return slices.IndexFunc(m.deadLetters, func(deadLetter webhook.DeadLetter) bool {
+		return deadLetter.Id == id
+	})
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// MutationKind kind of a change reported to the observers of an ObservingTodoRepository
+type MutationKind string
+
+// Kinds of reported changes
+const (
+	MutationCreated MutationKind = "created"
+	MutationUpdated MutationKind = "updated" // includes patches, moving to and restoring from the trash
+	MutationDeleted MutationKind = "deleted"
+)
+
+// Mutation successful change of a todo
+type Mutation struct {
+	Kind   MutationKind
+	Before todo.Todo // stored todo before the change, empty for creations
+	After  todo.Todo // todo after the change, the deleted todo for deletions
+}
+
+// TodoObserver is notified of the changes made through an ObservingTodoRepository
+type TodoObserver interface {
+	// TodoChanged is called after every successful change in the order of the changes. The changes are serialized
+	// until it returns, the passed context is the one of the repository the change was made through.
+	TodoChanged(context.Context, Mutation)
+}
+
+// ObservingTodoRepository decorates a todo repository, reporting every successful change to its observers
+type ObservingTodoRepository struct {
+	TodoRepository
+	observation *observation
+	ctx         context.Context
+}
+
+// observation observers and serialization shared by an ObservingTodoRepository and its copies of WithContext
+type observation struct {
+	observers []TodoObserver
+
+	// serializes the mutations with their reports, so that the observers see them in the same order
+	mutex sync.Mutex
+}
+
+// NewObservingTodoRepository returns the passed repository decorated to report its changes to the passed observers
+// in the passed order
+func NewObservingTodoRepository(todoRepository TodoRepository, observers ...TodoObserver) *ObservingTodoRepository {
+	return &ObservingTodoRepository{TodoRepository: todoRepository, observation: &observation{observers: observers},
+		ctx: context.Background()}
+}
+
+// WithContext returns a copy of the repository passing the changes made through it with the passed context to the
+// observers, e.g. to attribute them to a caller
+func (r *ObservingTodoRepository) WithContext(ctx context.Context) *ObservingTodoRepository {
+	copied := *r
+	copied.ctx = ctx
+	return &copied
+}
+
+// ReadTodosFiltered reads the todos matching the filter, by the decorated repository when it is able to filter
+func (r *ObservingTodoRepository) ReadTodosFiltered(filter TodoFilter) ([]todo.Todo, error) {
+	return ReadTodosFiltered(r.TodoRepository, filter)
+}
+
+// ReadTodosAsOf reads the past state of the todos from the decorated repository when it keeps the history
+func (r *ObservingTodoRepository) ReadTodosAsOf(asOf time.Time) ([]todo.Todo, error) {
+	return ReadTodosAsOf(r.TodoRepository, asOf)
+}
+
+// ReadTodoHistory reads the changes of a todo from the decorated repository when it keeps the history
+func (r *ObservingTodoRepository) ReadTodoHistory(id string) ([]TodoChange, error) {
+	return ReadTodoHistory(r.TodoRepository, id)
+}
+
+// CreateTodo creates the todo in the decorated repository and reports its creation
+func (r *ObservingTodoRepository) CreateTodo(todoToCreate todo.Todo) (todo.Todo, error) {
+	r.observation.mutex.Lock()
+	defer r.observation.mutex.Unlock()
+	todoCreated, err := r.TodoRepository.CreateTodo(todoToCreate)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	r.report(Mutation{Kind: MutationCreated, After: todoCreated})
+	return todoCreated, nil
+}
+
+// UpdateTodoById updates the todo in the decorated repository and reports the update
+func (r *ObservingTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
+	r.observation.mutex.Lock()
+	defer r.observation.mutex.Unlock()
+	currentTodo, err := r.TodoRepository.ReadTodoById(id)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	todoUpdated, err := r.TodoRepository.UpdateTodoById(id, todoUpdate)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	r.report(Mutation{Kind: MutationUpdated, Before: currentTodo, After: todoUpdated})
+	return todoUpdated, nil
+}
+
+// PatchTodoById patches the todo in the decorated repository and reports the change
+func (r *ObservingTodoRepository) PatchTodoById(id string, patch func(todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	r.observation.mutex.Lock()
+	defer r.observation.mutex.Unlock()
+	var currentTodo todo.Todo
+	todoPatched, err := r.TodoRepository.PatchTodoById(id, recordingCurrent(patch, &currentTodo))
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	r.report(Mutation{Kind: MutationUpdated, Before: currentTodo, After: todoPatched})
+	return todoPatched, nil
+}
+
+// DeleteTodoById deletes the todo in the decorated repository and reports the deletion
+func (r *ObservingTodoRepository) DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
+	r.observation.mutex.Lock()
+	defer r.observation.mutex.Unlock()
+	todoDeleted, err := r.TodoRepository.DeleteTodoById(id, todoDelete)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	r.report(Mutation{Kind: MutationDeleted, Before: todoDeleted, After: todoDeleted})
+	return todoDeleted, nil
+}
+
+// ApplyBatch applies the batch operations in the decorated repository and reports the changes in order
+func (r *ObservingTodoRepository) ApplyBatch(operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	r.observation.mutex.Lock()
+	defer r.observation.mutex.Unlock()
+	currentTodos := make([]todo.Todo, len(operations))
+	recordingOperations := make([]BatchOperation, len(operations))
+	for index, operation := range operations {
+		if operation.Kind == BatchPatch {
+			operation.Patch = recordingCurrent(operation.Patch, &currentTodos[index])
+		}
+		recordingOperations[index] = operation
+	}
+
+	results, err := r.TodoRepository.ApplyBatch(recordingOperations, atomic)
+	if err != nil {
+		return nil, err
+	}
+	for index, result := range results {
+		switch {
+		case result.Err != nil:
+			continue
+		case operations[index].Kind == BatchCreate:
+			r.report(Mutation{Kind: MutationCreated, After: result.Todo})
+		case operations[index].Kind == BatchDelete:
+			r.report(Mutation{Kind: MutationDeleted, Before: result.Todo, After: result.Todo})
+		default:
+			r.report(Mutation{Kind: MutationUpdated, Before: currentTodos[index], After: result.Todo})
+		}
+	}
+	return results, nil
+}
+
+// report passes the change to the observers, the caller holds the mutex
+func (r *ObservingTodoRepository) report(mutation Mutation) {
+	for _, observer := range r.observation.observers {
+		observer.TodoChanged(r.ctx, mutation)
+	}
+}
+
+// recordingCurrent returns the patch recording the stored todo it is applied to
+func recordingCurrent(patch func(todo.Todo) (todo.Todo, error), currentTodo *todo.Todo) func(todo.Todo) (todo.Todo, error) {
+	return func(storedTodo todo.Todo) (todo.Todo, error) {
+		*currentTodo = storedTodo
+		return patch(storedTodo)
+	}
+}
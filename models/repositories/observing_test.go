@@ -0,0 +1,176 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/todo"
+)
+
+// contextKey key of the test value in the contexts of the reported changes
+type contextKey struct{}
+
+// observed change reported to a recordingObserver
+type observed struct {
+	value    interface{} // test value of the context
+	mutation repositories.Mutation
+}
+
+// recordingObserver observer recording the reported changes
+type recordingObserver struct {
+	mutex    sync.Mutex
+	observed []observed
+}
+
+func (o *recordingObserver) TodoChanged(ctx context.Context, mutation repositories.Mutation) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.observed = append(o.observed, observed{value: ctx.Value(contextKey{}), mutation: mutation})
+}
+
+func (o *recordingObserver) reported() []observed {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return slices.Clone(o.observed)
+}
+
+// newObservingRepository returns an observing memory repository reporting to the returned observers
+func newObservingRepository(t *testing.T) (*repositories.ObservingTodoRepository, *recordingObserver, *recordingObserver) {
+	t.Helper()
+	first, second := &recordingObserver{}, &recordingObserver{}
+	repository := repositories.NewObservingTodoRepository(&memrepo.MemoryTodoRepository{}, first, second)
+	err := repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository, first, second
+}
+
+func increment(currentTodo todo.Todo) (todo.Todo, error) {
+	currentTodo.Title += "+"
+	currentTodo.Version++
+	return currentTodo, nil
+}
+
+func TestChangesAreReportedWithStateBefore(t *testing.T) {
+	repository, first, second := newObservingRepository(t)
+	ctx := context.WithValue(context.Background(), contextKey{}, "caller")
+
+	created, err := repository.WithContext(ctx).CreateTodo(todo.Todo{Title: "observed", Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := repository.WithContext(ctx).PatchTodoById(created.Id, increment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := patched
+	updated.Title = "updated"
+	updated.Version++
+	updated, err = repository.UpdateTodoById(created.Id, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := repository.DeleteTodoById(created.Id, todo.Todo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// failed changes aren't reported
+	_, err = repository.PatchTodoById(created.Id, increment)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("got %v patching a deleted todo, want not found", err)
+	}
+
+	want := []observed{
+		{"caller", repositories.Mutation{Kind: repositories.MutationCreated, After: created}},
+		{"caller", repositories.Mutation{Kind: repositories.MutationUpdated, Before: created, After: patched}},
+		{nil, repositories.Mutation{Kind: repositories.MutationUpdated, Before: patched, After: updated}},
+		{nil, repositories.Mutation{Kind: repositories.MutationDeleted, Before: deleted, After: deleted}},
+	}
+	for _, observer := range []*recordingObserver{first, second} {
+		got := observer.reported()
+		if len(got) != len(want) {
+			t.Fatalf("%d changes reported, want %d", len(got), len(want))
+		}
+		for index := range want {
+			if got[index].value != want[index].value || got[index].mutation.Kind != want[index].mutation.Kind ||
+				got[index].mutation.Before.Title != want[index].mutation.Before.Title ||
+				got[index].mutation.After.Title != want[index].mutation.After.Title {
+				t.Errorf("change %d reported as %+v, want %+v", index+1, got[index], want[index])
+			}
+		}
+	}
+}
+
+func TestBatchChangesAreReportedInOrder(t *testing.T) {
+	repository, observer, _ := newObservingRepository(t)
+	existing, err := repository.CreateTodo(todo.Todo{Title: "existing", Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := repository.ApplyBatch([]repositories.BatchOperation{
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "batch", Version: 1}},
+		{Kind: repositories.BatchPatch, Id: "unknown", Patch: increment},
+		{Kind: repositories.BatchPatch, Id: existing.Id, Patch: increment},
+		{Kind: repositories.BatchDelete, Id: existing.Id},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[1].Err == nil {
+		t.Fatal("patching an unknown todo succeeded")
+	}
+
+	got := observer.reported()[1:]
+	wantKinds := []repositories.MutationKind{repositories.MutationCreated, repositories.MutationUpdated,
+		repositories.MutationDeleted}
+	if len(got) != len(wantKinds) {
+		t.Fatalf("%d batch changes reported, want %d", len(got), len(wantKinds))
+	}
+	for index, kind := range wantKinds {
+		if got[index].mutation.Kind != kind {
+			t.Errorf("batch change %d reported as %s, want %s", index+1, got[index].mutation.Kind, kind)
+		}
+	}
+	if got[1].mutation.Before.Title != "existing" || got[1].mutation.After.Title != "existing+" {
+		t.Errorf("patch reported from %q to %q, want from existing to existing+", got[1].mutation.Before.Title,
+			got[1].mutation.After.Title)
+	}
+}
+
+func TestConcurrentChangesAreReportedInOrderOfStorage(t *testing.T) {
+	repository, observer, _ := newObservingRepository(t)
+	counter, err := repository.CreateTodo(todo.Todo{Title: "counter", Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wait sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for round := 0; round < 50; round++ {
+				_, err := repository.PatchTodoById(counter.Id, increment)
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wait.Wait()
+
+	// every reported patch starts at the state the previous one ended with
+	got := observer.reported()[1:]
+	for index := 1; index < len(got); index++ {
+		if got[index].mutation.Before.Version != got[index-1].mutation.After.Version {
+			t.Fatalf("change %d reported from version %d after version %d", index+1, got[index].mutation.Before.Version,
+				got[index-1].mutation.After.Version)
+		}
+	}
+}
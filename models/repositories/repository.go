@@ -1,7 +1,12 @@
 // Package repositories contains the repository definitions
 package repositories
 
-import "todo-rest-backend/models/todo"
+import (
+	"todo-rest-backend/models/list"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/webhook"
+)
 
 // TodoRepository interface todo repository type (used for repository architectural pattern interface definition)
 type TodoRepository interface {
@@ -10,5 +15,51 @@ type TodoRepository interface {
 	ReadTodoById(string) (todo.Todo, error)
 	CreateTodo(todo.Todo) (todo.Todo, error)
 	UpdateTodoById(string, todo.Todo) (todo.Todo, error)
+	// PatchTodoById replaces the todo by the result of the passed function applied to the stored todo (atomically)
+	PatchTodoById(string, func(todo.Todo) (todo.Todo, error)) (todo.Todo, error)
+	// DeleteTodoById deletes the todo; when the version of the passed todo isn't 0 it must match the stored version.
+	// Stored todos have version 1 or higher, also the ones loaded from data written before versions existed.
 	DeleteTodoById(string, todo.Todo) (todo.Todo, error)
+	// ApplyBatch applies the operations in order, later operations see the changes of earlier ones.
+	// When atomic is set, nothing is stored if an operation fails, otherwise failing operations are skipped.
+	// The returned error is set when the batch couldn't be stored at all.
+	ApplyBatch([]BatchOperation, bool) ([]BatchResult, error)
+}
+
+// ListRepository interface list repository type (used for repository architectural pattern interface definition)
+type ListRepository interface {
+	Initialize() error
+	ReadLists() ([]list.List, error)
+	ReadListById(string) (list.List, error)
+	CreateList(list.List) (list.List, error)
+	UpdateListById(string, list.List) (list.List, error)
+	DeleteListById(string) (list.List, error)
+}
+
+// WebhookRepository interface webhook repository type (used for repository architectural pattern interface definition)
+type WebhookRepository interface {
+	Initialize() error
+	ReadWebhooks() ([]webhook.Webhook, error)
+	ReadWebhookById(string) (webhook.Webhook, error)
+	CreateWebhook(webhook.Webhook) (webhook.Webhook, error)
+	UpdateWebhookById(string, webhook.Webhook) (webhook.Webhook, error)
+	DeleteWebhookById(string) (webhook.Webhook, error)
+	ReadDeadLetters() ([]webhook.DeadLetter, error) // oldest first
+	ReadDeadLetterById(string) (webhook.DeadLetter, error)
+	CreateDeadLetter(webhook.DeadLetter) (webhook.DeadLetter, error) // drops the oldest beyond webhook.DeadLetterSize
+	DeleteDeadLetterById(string) (webhook.DeadLetter, error)
+}
+
+// RevisionRepository interface revision repository type (used for repository architectural pattern interface definition).
+// Revisions are only appended, never changed.
+type RevisionRepository interface {
+	Initialize() error
+	// ReadRevisions returns the revisions of the todo with passed id, oldest first
+	ReadRevisions(string) ([]revision.Revision, error)
+	ReadRevision(string, int) (revision.Revision, error)
+	// ReadLastChange returns the revisions of the change recorded last for the undo session with passed key, in the
+	// order they were recorded
+	ReadLastChange(string) ([]revision.Revision, error)
+	// CreateRevision stores the passed revision as next revision of its todo and returns it with its number
+	CreateRevision(revision.Revision) (revision.Revision, error)
 }
@@ -0,0 +1,567 @@
+// Package sqliterepo contains the repository logic for sqlite database I/O operations
+package sqliterepo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todo-rest-backend/models/idgen"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 database/sql driver
+)
+
+// DriverName of the database/sql driver
+const DriverName = "sqlite3"
+
+// schema of the todo storage (executed on every Initialize, therefore idempotent)
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS todos (
+		id          TEXT PRIMARY KEY NOT NULL,
+		title       TEXT NOT NULL,
+		description TEXT NOT NULL,
+		terminated  INTEGER NOT NULL DEFAULT 0
+	)`,
+	// the primary key is indexed already, databases created by older versions have a redundant index on it
+	`DROP INDEX IF EXISTS todos_id_idx`,
+	`CREATE INDEX IF NOT EXISTS todos_terminated_idx ON todos (terminated)`,
+	`CREATE TABLE IF NOT EXISTS sequences (
+		name  TEXT PRIMARY KEY NOT NULL,
+		value INTEGER NOT NULL
+	)`,
+	// databases created before the sequence table existed continue after the highest numeric id
+	`INSERT OR IGNORE INTO sequences (name, value)
+		SELECT 'todos', COALESCE(MAX(CAST(id AS INTEGER)), 0) FROM todos`,
+}
+
+// migrations adds columns introduced after the first version of the schema
+var migrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{table: "todos", column: "deleted_at", definition: "TEXT"},
+	{table: "todos", column: "version", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "todos", column: "due_at", definition: "TEXT"},
+	{table: "todos", column: "priority", definition: "TEXT NOT NULL DEFAULT 'normal'"},
+	{table: "todos", column: "created_at", definition: "TEXT"},
+	{table: "todos", column: "updated_at", definition: "TEXT"},
+	{table: "todos", column: "completed_at", definition: "TEXT"},
+	{table: "todos", column: "tags", definition: "TEXT NOT NULL DEFAULT '[]'"}, // JSON array
+	{table: "todos", column: "list_id", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todos", column: "parent_id", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todos", column: "blocked_by", definition: "TEXT NOT NULL DEFAULT '[]'"}, // JSON array
+	{table: "todos", column: "recurrence", definition: "TEXT NOT NULL DEFAULT ''"},
+}
+
+// indexes on columns added by migrations
+var migrationIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS todos_due_at_idx ON todos (due_at)`,
+	`CREATE INDEX IF NOT EXISTS todos_list_id_idx ON todos (list_id)`,
+	`CREATE INDEX IF NOT EXISTS todos_parent_id_idx ON todos (parent_id)`,
+}
+
+// todoColumnNames of a todo row (in the order of scanTodo and todoValues)
+var todoColumnNames = []string{"id", "title", "description", "terminated", "deleted_at", "version", "due_at", "priority",
+	"created_at", "updated_at", "completed_at", "tags", "list_id", "parent_id", "blocked_by",
+	"recurrence"}
+
+// todoColumns selected for reading a todo
+var todoColumns = strings.Join(todoColumnNames, ", ")
+
+// SqliteTodoRepository type
+type SqliteTodoRepository struct {
+	DatabasePath string
+	IdGenerator  idgen.Generator
+	db           *sql.DB
+}
+
+// Initialize opens the database and creates the schema when not existing
+func (s *SqliteTodoRepository) Initialize() error {
+	if s.DatabasePath == "" {
+		return errors.New("database path must not be empty")
+	}
+
+	// _txlock=immediate: write transactions take the write lock on begin, so two transactions
+	// can't both read the next id before one of them writes.
+	dataSourceName := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate", s.DatabasePath)
+	db, err := sql.Open(DriverName, dataSourceName)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	// sqlite allows only one writer at a time
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range schema {
+		_, err = db.Exec(statement)
+		if err != nil {
+			_ = db.Close()
+			return repositories.StorageError(err)
+		}
+	}
+	for _, migration := range migrations {
+		err = ensureColumn(db, migration.table, migration.column, migration.definition)
+		if err != nil {
+			_ = db.Close()
+			return repositories.StorageError(err)
+		}
+	}
+	for _, statement := range migrationIndexes {
+		_, err = db.Exec(statement)
+		if err != nil {
+			_ = db.Close()
+			return repositories.StorageError(err)
+		}
+	}
+
+	s.db = db
+	return nil
+}
+
+// Close closes the database
+func (s *SqliteTodoRepository) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// ReadTodos returns todo's stored in the database
+func (s *SqliteTodoRepository) ReadTodos() ([]todo.Todo, error) {
+	if s.db == nil {
+		return nil, repositories.StorageError(errors.New("repository not initialized"))
+	}
+
+	return s.queryTodos(`SELECT ` + todoColumns + ` FROM todos`)
+}
+
+// ReadTodosFiltered returns todo's stored in the database matching the passed filter
+func (s *SqliteTodoRepository) ReadTodosFiltered(filter repositories.TodoFilter) ([]todo.Todo, error) {
+	if s.db == nil {
+		return nil, repositories.StorageError(errors.New("repository not initialized"))
+	}
+
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.Trashed {
+		conditions = []string{"deleted_at IS NOT NULL"}
+	}
+	var args []any
+	if filter.Terminated != nil {
+		conditions = append(conditions, "terminated = ?")
+		args = append(args, *filter.Terminated)
+	}
+	if filter.Text != "" {
+		conditions = append(conditions, "(instr(lower(title), lower(?)) > 0 OR instr(lower(description), lower(?)) > 0)")
+		args = append(args, filter.Text, filter.Text)
+	}
+	if filter.ListId != "" {
+		conditions = append(conditions, "list_id = ?")
+		args = append(args, filter.ListId)
+	}
+	if filter.ParentId != "" {
+		conditions = append(conditions, "parent_id = ?")
+		args = append(args, filter.ParentId)
+	}
+	if len(filter.Priorities) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Priorities)), ", ")
+		conditions = append(conditions, "priority IN ("+placeholders+")")
+		for _, priority := range filter.Priorities {
+			args = append(args, string(priority))
+		}
+	}
+	if len(filter.Tags) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Tags)), ", ")
+		if filter.AllTags {
+			conditions = append(conditions, "(SELECT COUNT(DISTINCT value) FROM json_each(todos.tags) WHERE value IN ("+placeholders+")) = ?")
+		} else {
+			conditions = append(conditions, "EXISTS (SELECT 1 FROM json_each(todos.tags) WHERE value IN ("+placeholders+"))")
+		}
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		if filter.AllTags {
+			args = append(args, len(filter.Tags))
+		}
+	}
+	// times are stored in a fixed width format in UTC, so they can be compared as strings
+	timeConditions := []struct {
+		condition string
+		limit     *time.Time
+	}{
+		{"due_at < ?", filter.DueBefore}, {"due_at > ?", filter.DueAfter},
+		{"created_at < ?", filter.CreatedBefore}, {"created_at > ?", filter.CreatedAfter},
+	}
+	for _, timeCondition := range timeConditions {
+		if timeCondition.limit != nil {
+			conditions = append(conditions, timeCondition.condition)
+			args = append(args, todo.FormatTime(timeCondition.limit))
+		}
+	}
+
+	return s.queryTodos(`SELECT `+todoColumns+` FROM todos WHERE `+strings.Join(conditions, " AND "), args...)
+}
+
+// queryTodos returns the todos selected by the passed query
+func (s *SqliteTodoRepository) queryTodos(query string, args ...any) (readTodos []todo.Todo, err error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, repositories.StorageError(err)
+	}
+	defer func() {
+		closeRowsAndHandleError(rows, &err)
+		if err != nil {
+			readTodos, err = nil, repositories.StorageError(err)
+		}
+	}()
+
+	for rows.Next() {
+		var todoRead todo.Todo
+		todoRead, err = scanTodo(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		readTodos = append(readTodos, todoRead)
+	}
+
+	return readTodos, rows.Err()
+}
+
+// ReadTodoById returns todo stored in the database with passed id when existing
+func (s *SqliteTodoRepository) ReadTodoById(id string) (todo.Todo, error) {
+	if s.db == nil {
+		return todo.Todo{}, repositories.StorageError(errors.New("repository not initialized"))
+	}
+
+	return readTodoById(s.db.QueryRow, id)
+}
+
+// CreateTodo stores the passed todo in the database and returns the stored todo
+func (s *SqliteTodoRepository) CreateTodo(todoToCreate todo.Todo) (todo.Todo, error) {
+	var todoCreated todo.Todo
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		var err error
+		todoCreated, err = s.insertTodo(tx, todoToCreate)
+		return err
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoCreated, nil
+}
+
+// insertTodo inserts the passed todo with a new id and returns the inserted todo
+func (s *SqliteTodoRepository) insertTodo(tx *sql.Tx, todoToCreate todo.Todo) (todo.Todo, error) {
+	id, err := idgen.OrDefault(s.IdGenerator).NewId(func() (uint64, error) {
+		var sequence uint64
+		err := tx.QueryRow(`UPDATE sequences SET value = value + 1 WHERE name = 'todos' RETURNING value`).Scan(&sequence)
+		return sequence, err
+	})
+	if err != nil {
+		return todo.Todo{}, repositories.StorageError(err)
+	}
+
+	todoToCreate.Id = id
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(todoColumnNames)), ", ")
+	_, err = tx.Exec(`INSERT INTO todos (`+todoColumns+`) VALUES (`+placeholders+`)`, todoValues(todoToCreate)...)
+	if err != nil {
+		return todo.Todo{}, repositories.StorageError(err)
+	}
+	return todoToCreate, nil
+}
+
+// UpdateTodoById updates the passed todo by id in the database and returns the updated todo
+func (s *SqliteTodoRepository) UpdateTodoById(id string, todoUpdate todo.Todo) (todo.Todo, error) {
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		return updateTodo(tx, id, todoUpdate, fmt.Errorf("item with id %s %w. Updating not possible", id, repositories.ErrNotFound))
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	todoUpdate.Id = id
+	return todoUpdate, nil
+}
+
+// PatchTodoById applies the passed patch function to the todo with passed id in the database and returns the patched todo
+func (s *SqliteTodoRepository) PatchTodoById(id string, patch func(todo.Todo) (todo.Todo, error)) (todo.Todo, error) {
+	var todoPatched todo.Todo
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		currentTodo, err := readTodoById(tx.QueryRow, id)
+		if err != nil {
+			return err
+		}
+
+		todoPatched, err = patch(currentTodo)
+		if err != nil {
+			return err
+		}
+		todoPatched.Id = id
+
+		return updateTodo(tx, id, todoPatched, fmt.Errorf("item with id %s %w. Patching not possible", id, repositories.ErrNotFound))
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return todoPatched, nil
+}
+
+// DeleteTodoById deletes the todo by id in the database and returns the deleted todo
+func (s *SqliteTodoRepository) DeleteTodoById(id string, todoDelete todo.Todo) (todo.Todo, error) {
+	var deletedTodo todo.Todo
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		var err error
+		deletedTodo, err = readTodoById(tx.QueryRow, id)
+		if err != nil {
+			return err
+		}
+		if todoDelete.Version != 0 && todoDelete.Version != deletedTodo.Version {
+			return repositories.ErrVersionMismatch
+		}
+
+		_, err = tx.Exec(`DELETE FROM todos WHERE id = ?`, id)
+		return repositories.StorageError(err)
+	})
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	return deletedTodo, nil
+}
+
+// ApplyBatch applies the batch operations in a single transaction and returns their results.
+// Every operation runs in a savepoint, so that a failing operation leaves no partial changes behind.
+func (s *SqliteTodoRepository) ApplyBatch(operations []repositories.BatchOperation, atomic bool) ([]repositories.BatchResult, error) {
+	results := make([]repositories.BatchResult, len(operations))
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		failed := false
+		for index, operation := range operations {
+			_, err := tx.Exec(`SAVEPOINT batch_operation`)
+			if err != nil {
+				return repositories.StorageError(err)
+			}
+			results[index] = s.applyBatchOperation(tx, operation)
+			statement := `RELEASE batch_operation`
+			if results[index].Err != nil {
+				failed = true
+				statement = `ROLLBACK TO batch_operation; RELEASE batch_operation`
+			}
+			_, err = tx.Exec(statement)
+			if err != nil {
+				return repositories.StorageError(err)
+			}
+		}
+		if atomic && failed {
+			return errBatchFailed
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchFailed) {
+		return repositories.AbortBatch(results), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// errBatchFailed rolls back the transaction of an all-or-nothing batch in which an operation failed
+var errBatchFailed = errors.New("batch operation failed")
+
+func (s *SqliteTodoRepository) applyBatchOperation(tx *sql.Tx, operation repositories.BatchOperation) repositories.BatchResult {
+	if operation.Kind == repositories.BatchCreate {
+		todoCreated, err := s.insertTodo(tx, operation.Todo)
+		if err != nil {
+			return repositories.BatchResult{Err: err}
+		}
+		return repositories.BatchResult{Todo: todoCreated}
+	}
+
+	currentTodo, err := readTodoById(tx.QueryRow, operation.Id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return repositories.BatchResult{Err: fmt.Errorf("%w: %s", repositories.ErrNotFound, operation.Id)}
+	}
+	if err != nil {
+		return repositories.BatchResult{Err: err}
+	}
+	switch operation.Kind {
+	case repositories.BatchPatch:
+		todoPatched, err := operation.Patch(currentTodo)
+		if err != nil {
+			return repositories.BatchResult{Err: err}
+		}
+		todoPatched.Id = operation.Id
+		err = updateTodo(tx, operation.Id, todoPatched, repositories.ErrNotFound)
+		if err != nil {
+			return repositories.BatchResult{Err: err}
+		}
+		return repositories.BatchResult{Todo: todoPatched}
+	case repositories.BatchDelete:
+		if operation.Todo.Version != 0 && operation.Todo.Version != currentTodo.Version {
+			return repositories.BatchResult{Err: repositories.ErrVersionMismatch}
+		}
+		_, err = tx.Exec(`DELETE FROM todos WHERE id = ?`, operation.Id)
+		if err != nil {
+			return repositories.BatchResult{Err: repositories.StorageError(err)}
+		}
+		return repositories.BatchResult{Todo: currentTodo}
+	}
+	return repositories.BatchResult{Err: fmt.Errorf("unknown batch operation %q", operation.Kind)}
+}
+
+// inTransaction runs the passed function in a transaction, which is committed on success and rolled back otherwise
+func (s *SqliteTodoRepository) inTransaction(fn func(tx *sql.Tx) error) error {
+	if s.db == nil {
+		return repositories.StorageError(errors.New("repository not initialized"))
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			return errors.Join(err, repositories.StorageError(rollbackErr))
+		}
+		return err
+	}
+
+	return repositories.StorageError(tx.Commit())
+}
+
+// updateTodo writes all fields of the passed todo to the row with passed id
+func updateTodo(tx *sql.Tx, id string, todoUpdate todo.Todo, notFoundErr error) error {
+	// all columns except the id
+	assignments := strings.Join(todoColumnNames[1:], " = ?, ") + " = ?"
+	todoUpdate.Id = id
+	values := todoValues(todoUpdate)[1:]
+	result, err := tx.Exec(`UPDATE todos SET `+assignments+` WHERE id = ?`, append(values, id)...)
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	return ensureRowAffected(result, notFoundErr)
+}
+
+// readTodoById reads a single todo using the passed query function (either of *sql.DB or *sql.Tx)
+func readTodoById(queryRow func(string, ...any) *sql.Row, id string) (todo.Todo, error) {
+	todoRead, err := scanTodo(queryRow(`SELECT `+todoColumns+` FROM todos WHERE id = ?`, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return todo.Todo{}, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+	if err != nil {
+		return todo.Todo{}, repositories.StorageError(err)
+	}
+
+	return todoRead, nil
+}
+
+// scanTodo scans the todoColumns using the passed scan function (either of *sql.Row or *sql.Rows)
+func scanTodo(scan func(...any) error) (todo.Todo, error) {
+	var todoRead todo.Todo
+	var priority, tags, blockedBy string
+	var deletedAt, dueAt, createdAt, updatedAt, completedAt sql.NullString
+	err := scan(&todoRead.Id, &todoRead.Title, &todoRead.Description, &todoRead.Terminated, &deletedAt,
+		&todoRead.Version, &dueAt, &priority, &createdAt, &updatedAt, &completedAt, &tags, &todoRead.ListId, &todoRead.ParentId, &blockedBy,
+		&todoRead.Recurrence)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	// rows migrated from before versions existed have the column default 0, they count as first version
+	todoRead.Version = max(todoRead.Version, 1)
+	todoRead.Priority = todo.Priority(priority)
+	todoRead.Tags, err = todo.ParseTags(tags)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	todoRead.BlockedBy, err = todo.ParseIds(blockedBy)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+
+	timeColumns := []struct {
+		target **time.Time
+		value  sql.NullString
+	}{
+		{&todoRead.DeletedAt, deletedAt}, {&todoRead.DueAt, dueAt}, {&todoRead.CreatedAt, createdAt},
+		{&todoRead.UpdatedAt, updatedAt}, {&todoRead.CompletedAt, completedAt},
+	}
+	for _, timeColumn := range timeColumns {
+		*timeColumn.target, err = todo.ParseTime(timeColumn.value.String)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+	}
+
+	return todoRead, nil
+}
+
+// todoValues returns the database values of the todo in the order of todoColumnNames
+func todoValues(todoToStore todo.Todo) []any {
+	return []any{todoToStore.Id, todoToStore.Title, todoToStore.Description, todoToStore.Terminated,
+		nullableTime(todoToStore.DeletedAt), todoToStore.Version, nullableTime(todoToStore.DueAt),
+		string(todoToStore.Priority), nullableTime(todoToStore.CreatedAt), nullableTime(todoToStore.UpdatedAt),
+		nullableTime(todoToStore.CompletedAt), todo.FormatTags(todoToStore.Tags), todoToStore.ListId,
+		todoToStore.ParentId, todo.FormatIds(todoToStore.BlockedBy), todoToStore.Recurrence}
+}
+
+// nullableTime converts an optional point in time into a database value
+func nullableTime(value *time.Time) sql.NullString {
+	return sql.NullString{String: todo.FormatTime(value), Valid: value != nil}
+}
+
+// ensureColumn adds the column to the table when not existing yet
+func ensureColumn(db *sql.DB, table string, column string, definition string) (err error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+
+	columnExists := false
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if name == column {
+			columnExists = true
+		}
+	}
+	err = rows.Err()
+	closeRowsAndHandleError(rows, &err)
+	if err != nil || columnExists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+func ensureRowAffected(result sql.Result, notFoundErr error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return repositories.StorageError(err)
+	}
+	if affected == 0 {
+		return notFoundErr
+	}
+	return nil
+}
+
+func closeRowsAndHandleError(rows *sql.Rows, err *error) {
+	closeErr := rows.Close()
+	if closeErr != nil {
+		if *err == nil { // Only overwrite if no prior error
+			*err = closeErr
+		}
+	}
+}
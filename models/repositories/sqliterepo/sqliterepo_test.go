@@ -0,0 +1,203 @@
+package sqliterepo
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// newRepository returns an initialized repository with its database in a temporary directory, closed when the test
+// ends
+func newRepository(t *testing.T) *SqliteTodoRepository {
+	t.Helper()
+	repository := &SqliteTodoRepository{DatabasePath: filepath.Join(t.TempDir(), "todos.db")}
+	initialize(t, repository)
+	t.Cleanup(func() { _ = repository.Close() })
+	return repository
+}
+
+// initialize (re)opens the database, e.g. like a restart
+func initialize(t *testing.T, repository *SqliteTodoRepository) {
+	t.Helper()
+	err := repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repository.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func create(t *testing.T, repository *SqliteTodoRepository, title string) todo.Todo {
+	t.Helper()
+	created, err := repository.CreateTodo(todo.Todo{Title: title, Description: "stored", Version: 1,
+		Priority: todo.PriorityNormal, Tags: []string{"tag"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func readTitles(t *testing.T, repository *SqliteTodoRepository) []string {
+	t.Helper()
+	todos, err := repository.ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, currentTodo := range todos {
+		titles = append(titles, currentTodo.Title)
+	}
+	slices.Sort(titles)
+	return titles
+}
+
+// sequence returns the stored value of the id sequence
+func sequence(t *testing.T, repository *SqliteTodoRepository) uint64 {
+	t.Helper()
+	var value uint64
+	err := repository.db.QueryRow(`SELECT value FROM sequences WHERE name = 'todos'`).Scan(&value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// fixedIdGenerator generator taking a sequence value and handing out the same id every time
+type fixedIdGenerator struct {
+	id string
+}
+
+func (g fixedIdGenerator) NewId(nextSequence func() (uint64, error)) (string, error) {
+	_, err := nextSequence()
+	return g.id, err
+}
+
+func TestTodosAreCreatedReadUpdatedAndDeleted(t *testing.T) {
+	repository := newRepository(t)
+	created := create(t, repository, "created")
+	if created.Id != "1" {
+		t.Errorf("created todo %s, want 1", created.Id)
+	}
+
+	read, err := repository.ReadTodoById(created.Id)
+	if err != nil || read.Title != "created" || !slices.Equal(read.Tags, []string{"tag"}) {
+		t.Errorf("read %+v (%v), want the created todo", read, err)
+	}
+
+	read.Title = "updated"
+	read.Version++
+	_, err = repository.UpdateTodoById(created.Id, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := repository.PatchTodoById(created.Id, func(currentTodo todo.Todo) (todo.Todo, error) {
+		currentTodo.Terminated = true
+		currentTodo.Version++
+		return currentTodo, nil
+	})
+	if err != nil || patched.Title != "updated" || !patched.Terminated || patched.Version != 3 {
+		t.Errorf("patched %+v (%v), want the updated todo terminated in version 3", patched, err)
+	}
+	terminated := true
+	filtered, err := repository.ReadTodosFiltered(repositories.TodoFilter{Terminated: &terminated, Tags: []string{"tag"}})
+	if err != nil || len(filtered) != 1 {
+		t.Errorf("filtered %+v (%v), want the patched todo", filtered, err)
+	}
+
+	_, err = repository.DeleteTodoById(created.Id, todo.Todo{Version: 1})
+	if !errors.Is(err, repositories.ErrVersionMismatch) {
+		t.Errorf("got %v deleting an old version, want a version mismatch", err)
+	}
+	_, err = repository.DeleteTodoById(created.Id, todo.Todo{Version: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repository.ReadTodoById(created.Id)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v reading a deleted todo, want not found", err)
+	}
+	_, err = repository.UpdateTodoById(created.Id, read)
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v updating a deleted todo, want not found", err)
+	}
+}
+
+func TestFailedBatchOperationIsRolledBack(t *testing.T) {
+	repository := newRepository(t)
+	repository.IdGenerator = fixedIdGenerator{id: "fixed"}
+
+	// the second create takes a sequence value before its insert fails, the savepoint rolls it back
+	results, err := repository.ApplyBatch([]repositories.BatchOperation{
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "first", Version: 1}},
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "duplicate", Version: 1}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err == nil {
+		t.Fatalf("results %+v, want the first create to succeed and the second to fail", results)
+	}
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"first"}) {
+		t.Errorf("todos %v, want [first]", got)
+	}
+	if value := sequence(t, repository); value != 1 {
+		t.Errorf("sequence %d after the failed create, want 1", value)
+	}
+}
+
+func TestFailedAtomicBatchIsRolledBack(t *testing.T) {
+	repository := newRepository(t)
+	existing := create(t, repository, "existing")
+
+	results, err := repository.ApplyBatch([]repositories.BatchOperation{
+		{Kind: repositories.BatchCreate, Todo: todo.Todo{Title: "batch", Version: 1}},
+		{Kind: repositories.BatchPatch, Id: existing.Id, Patch: func(currentTodo todo.Todo) (todo.Todo, error) {
+			currentTodo.Title = "patched"
+			return currentTodo, nil
+		}},
+		{Kind: repositories.BatchDelete, Id: "unknown"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(results[0].Err, repositories.ErrBatchAborted) || !errors.Is(results[1].Err, repositories.ErrBatchAborted) ||
+		!errors.Is(results[2].Err, repositories.ErrNotFound) {
+		t.Errorf("results %+v, want the first two aborted and the last not found", results)
+	}
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"existing"}) {
+		t.Errorf("todos %v after the aborted batch, want [existing]", got)
+	}
+	if created := create(t, repository, "next"); created.Id != "2" {
+		t.Errorf("created todo %s after the aborted batch, want 2", created.Id)
+	}
+}
+
+func TestTodosArePersistedAcrossInitialize(t *testing.T) {
+	repository := newRepository(t)
+	create(t, repository, "first")
+	second := create(t, repository, "second")
+	_, err := repository.DeleteTodoById(second.Id, todo.Todo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initialize(t, repository)
+	if got := readTitles(t, repository); !slices.Equal(got, []string{"first"}) {
+		t.Errorf("todos %v after the restart, want [first]", got)
+	}
+	// the id of the deleted todo isn't handed out again
+	if created := create(t, repository, "third"); created.Id != "3" {
+		t.Errorf("created todo %s after the restart, want 3", created.Id)
+	}
+	var indexes int
+	err = repository.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'todos_id_idx'`).
+		Scan(&indexes)
+	if err != nil || indexes != 0 {
+		t.Errorf("%d redundant id indexes (%v), want none", indexes, err)
+	}
+}
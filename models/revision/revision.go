@@ -0,0 +1,157 @@
+// Package revision contains the revision model parts
+package revision
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// SystemActor actor of the changes made by the backend itself, e.g. purging the trash
+const SystemActor = "system"
+
+// Origin origin of the changes made with a context: who made them, for which undo session and as part of which change
+type Origin struct {
+	Actor    string // attribution only, not authenticated
+	Session  string // key of the undo session (SessionKey), empty for changes which can't be undone
+	ChangeId string // shared by all revisions of one change, e.g. a request together with its side effects
+}
+
+// originKey key of the origin in a context
+type originKey struct{}
+
+// NewContext returns a copy of the passed context carrying the origin of the changes made with it
+func NewContext(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// FromContext returns the origin carried by the passed context, the changes of contexts without origin are made by
+// the backend itself (SystemActor)
+func FromContext(ctx context.Context) Origin {
+	origin, ok := ctx.Value(originKey{}).(Origin)
+	if !ok {
+		return Origin{Actor: SystemActor}
+	}
+	return origin
+}
+
+// NewChangeId returns a new random change id
+func NewChangeId() string {
+	return randomHex()
+}
+
+// NewSessionToken returns a new random undo session token
+func NewSessionToken() string {
+	return randomHex()
+}
+
+// IsSessionToken checks whether the passed value has the form of an undo session token
+func IsSessionToken(value string) bool {
+	if len(value) != 2*randomSize {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil && strings.ToLower(value) == value
+}
+
+// SessionKey returns the key of the undo session with passed token. Only the key is stored with the revisions, so that
+// the stored revisions don't reveal the tokens.
+func SessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomSize number of random bytes of the change ids and session tokens
+const randomSize = 16
+
+// randomHex returns randomSize random bytes hex encoded
+func randomHex() string {
+	value := make([]byte, randomSize)
+	// never fails, see rand.Read
+	_, _ = rand.Read(value)
+	return hex.EncodeToString(value)
+}
+
+// Action type of the change recorded by a revision
+type Action string
+
+// Actions of the revisions
+const (
+	ActionCreated  Action = "created"
+	ActionUpdated  Action = "updated"
+	ActionTrashed  Action = "trashed"
+	ActionRestored Action = "restored" // from the trash
+	ActionDeleted  Action = "deleted"
+)
+
+// bookkeepingFields json names of the fields changing with every revision, left out of the diffs
+var bookkeepingFields = []string{"id", "version", "updatedAt"}
+
+// Revision recorded change of a todo with json tags
+type Revision struct {
+	TodoId   string        `json:"todoId"`
+	Number   int           `json:"number"` // counts the revisions of the todo, starting at 1
+	Actor    string        `json:"actor"`
+	Time     time.Time     `json:"time"`
+	Action   Action        `json:"action"`
+	Changes  []FieldChange `json:"changes"`
+	Todo     todo.Todo     `json:"todo"`               // todo after the change, the deleted todo for deletions
+	ChangeId string        `json:"changeId,omitempty"` // see Origin
+	Session  string        `json:"-"`                  // key of the undo session, see Origin
+}
+
+// FieldChange changed field of a todo with json tags, a missing value is null
+type FieldChange struct {
+	Field string      `json:"field"` // json name
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Diff returns the fields (json names, sorted) which differ between the passed todos, without the bookkeeping fields
+func Diff(before todo.Todo, after todo.Todo) ([]FieldChange, error) {
+	beforeFields, err := fieldsOf(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fieldsOf(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if slices.Contains(bookkeepingFields, name) || reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Old: beforeFields[name], New: afterFields[name]})
+	}
+	return changes, nil
+}
+
+func fieldsOf(todoToMap todo.Todo) (map[string]interface{}, error) {
+	content, err := json.Marshal(todoToMap)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(content, &fields)
+	return fields, err
+}
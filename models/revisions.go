@@ -0,0 +1,259 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"todo-rest-backend/models/audit"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/todo"
+)
+
+var revisionRepository repositories.RevisionRepository
+
+// revisionRecorder records the revisions of the changes of the todo repository, once the revision repository is set
+var revisionRecorder = audit.NewRecorder()
+
+// SetRevisionRepository allows to set the revision repositories type.
+// From then on every change of a todo is recorded as revision.
+func SetRevisionRepository(revisionRepositoryNew repositories.RevisionRepository) error {
+	if revisionRepositoryNew == nil {
+		return errors.New("revision repositories must not be nil")
+	}
+	revisionRepository = revisionRepositoryNew
+	revisionRecorder.SetRevisionRepository(revisionRepositoryNew)
+	return nil
+}
+
+// InitializeRevisions initializes the revision repository (abstracted by repository pattern)
+func InitializeRevisions() error {
+	if revisionRepository == nil {
+		return errors.New("revision repositories must not be nil")
+	}
+	return revisionRepository.Initialize()
+}
+
+// UndoMeta meta information of an undo with the reverted revisions
+type UndoMeta struct {
+	ChangeId        string              `json:"changeId"`
+	UndoneRevisions []revision.Revision `json:"undoneRevisions"`
+}
+
+// ReadRevisions returns the revisions of the todo with passed id, oldest first, also of trashed and deleted todos
+// (abstracted by repository pattern)
+func ReadRevisions(id string) ([]revision.Revision, error) {
+	if revisionRepository == nil {
+		return nil, errors.New("revision repositories must not be nil")
+	}
+	revisions, err := revisionRepository.ReadRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, fmt.Errorf("id %w", repositories.ErrNotFound)
+	}
+	return revisions, nil
+}
+
+// ReadRevision returns the revision with passed number of the todo with passed id (abstracted by repository pattern)
+func ReadRevision(id string, number int) (revision.Revision, error) {
+	if revisionRepository == nil {
+		return revision.Revision{}, errors.New("revision repositories must not be nil")
+	}
+	return revisionRepository.ReadRevision(id, number)
+}
+
+// RestoreRevision updates the todo with passed id to its state after the revision with passed number and returns
+// the updated todo. The trash state of the todo isn't changed, trashed todos have to be restored from the trash first.
+// ifMatch is the value of an If-Match precondition, empty for unconditional restores.
+func RestoreRevision(ctx context.Context, id string, number int, ifMatch string) (todo.Todo, error) {
+	revisionToRestore, err := ReadRevision(id, number)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	return UpdateTodoById(ctx, id, revisionToRestore.Todo, ifMatch)
+}
+
+// UndoLastChange reverts all revisions of the change recorded last for the undo session of the passed context and
+// returns the todos afterward, in the order of their first revision, together with the reverted revisions. Todos
+// created by the change are deleted (moved to the trash in trash mode), the others get their state before the change
+// back. The undo is applied atomically and is a change itself, so undoing twice redoes the change. A change isn't
+// undone when one of its todos was changed afterward or deleted permanently, neither when the state of one of its
+// todos before the change is unknown.
+func UndoLastChange(ctx context.Context) ([]todo.Todo, []revision.Revision, error) {
+	if revisionRepository == nil {
+		return nil, nil, errors.New("revision repositories must not be nil")
+	}
+	if todoRepository == nil {
+		return nil, nil, errors.New("todo repositories must not be nil")
+	}
+	session := revision.FromContext(ctx).Session
+	if session == "" {
+		return nil, nil, fmt.Errorf("change to undo %w", repositories.ErrNotFound)
+	}
+	changeRevisions, err := revisionRepository.ReadLastChange(session)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, fmt.Errorf("change to undo %w", repositories.ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	referenceMutex.Lock()
+	defer referenceMutex.Unlock()
+	targets, err := undoTargets(changeRevisions)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the todos are only read when a reverted todo refers to other todos
+	var todos []todo.Todo
+	if slices.ContainsFunc(targets, func(target undoTarget) bool {
+		return target.previous != nil && hasReferences(*target.previous)
+	}) {
+		todos, err = readTodosForValidation()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	results, err := todoRepository.WithContext(ctx).ApplyBatch(undoOperations(todos, targets), true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var todosUndone []todo.Todo
+	for index, result := range results {
+		switch {
+		case errors.Is(result.Err, repositories.ErrBatchAborted):
+			continue
+		case errors.Is(result.Err, repositories.ErrVersionMismatch) || errors.Is(result.Err, ErrPreconditionFailed):
+			return nil, nil, fmt.Errorf("%w: todo %s was changed after revision %d", repositories.ErrConflict,
+				targets[index].last.TodoId, targets[index].last.Number)
+		case result.Err != nil:
+			return nil, nil, result.Err
+		}
+		todosUndone = append(todosUndone, result.Todo)
+	}
+	for _, target := range targets {
+		if target.previous != nil {
+			continue
+		}
+		// the todo is deleted already, a failed detach leaves references to it behind but doesn't fail the undo
+		err = detachReferences(ctx, target.last.TodoId)
+		if err != nil {
+			log.Println("detaching the references to todo", target.last.TodoId, "deleted by undo failed:", err)
+		}
+	}
+	return todosUndone, changeRevisions, nil
+}
+
+// undoTarget todo reverted by an undo
+type undoTarget struct {
+	last     revision.Revision // last revision of the todo in the undone change, its todo is the expected current state
+	previous *todo.Todo        // state before the change, nil for todos created by the change
+}
+
+// undoTargets returns the todos to revert for undoing the change with passed revisions, in the order of their first
+// revision, checking them against the current todos
+func undoTargets(changeRevisions []revision.Revision) ([]undoTarget, error) {
+	var targets []undoTarget
+	positions := map[string]int{}
+	for _, changeRevision := range changeRevisions {
+		position, ok := positions[changeRevision.TodoId]
+		if ok {
+			targets[position].last = changeRevision
+			continue
+		}
+		positions[changeRevision.TodoId] = len(targets)
+		target := undoTarget{last: changeRevision}
+		if changeRevision.Action != revision.ActionCreated {
+			previousRevision, err := revisionRepository.ReadRevision(changeRevision.TodoId, changeRevision.Number-1)
+			if errors.Is(err, repositories.ErrNotFound) {
+				return nil, fmt.Errorf("%w: the state of todo %s before revision %d is unknown",
+					repositories.ErrConflict, changeRevision.TodoId, changeRevision.Number)
+			}
+			if err != nil {
+				return nil, err
+			}
+			target.previous = &previousRevision.Todo
+		}
+		targets = append(targets, target)
+	}
+
+	for _, target := range targets {
+		if target.last.Action == revision.ActionDeleted {
+			return nil, fmt.Errorf("%w: todo %s was deleted permanently", repositories.ErrConflict, target.last.TodoId)
+		}
+		currentTodo, err := todoRepository.ReadTodoById(target.last.TodoId)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, fmt.Errorf("%w: todo %s was deleted permanently", repositories.ErrConflict, target.last.TodoId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if currentTodo.Version != target.last.Todo.Version {
+			return nil, fmt.Errorf("%w: todo %s was changed after revision %d", repositories.ErrConflict,
+				target.last.TodoId, target.last.Number)
+		}
+	}
+	return targets, nil
+}
+
+// undoOperations returns the batch operations reverting the targets. The references of the reverted todos are
+// validated against the todos after the undo, dropping the ones which aren't valid anymore. The todos are nil when
+// no reverted todo refers to other todos.
+func undoOperations(todos []todo.Todo, targets []undoTarget) []repositories.BatchOperation {
+	todosUndone := slices.Clone(todos)
+	for _, target := range targets {
+		index := slices.IndexFunc(todosUndone, func(currentTodo todo.Todo) bool {
+			return currentTodo.Id == target.last.TodoId
+		})
+		switch {
+		case index < 0:
+			continue
+		case target.previous != nil:
+			todosUndone[index] = *target.previous
+		case trashEnabled:
+			now := time.Now().UTC()
+			todosUndone[index].DeletedAt = &now
+		default:
+			todosUndone = slices.Delete(todosUndone, index, index+1)
+		}
+	}
+
+	var operations []repositories.BatchOperation
+	for _, target := range targets {
+		// the todo has to be unchanged since the revision
+		ifMatch := ETag(target.last.Todo)
+		switch {
+		case target.previous != nil:
+			operations = append(operations, repositories.BatchOperation{Kind: repositories.BatchPatch,
+				Id: target.last.TodoId, Patch: revertChange(todosUndone, *target.previous, ifMatch)})
+		case trashEnabled:
+			operations = append(operations, repositories.BatchOperation{Kind: repositories.BatchPatch,
+				Id: target.last.TodoId, Patch: trashChange(ifMatch)})
+		default:
+			operations = append(operations, repositories.BatchOperation{Kind: repositories.BatchDelete,
+				Id: target.last.TodoId, Todo: todo.Todo{Version: target.last.Todo.Version}})
+		}
+	}
+	return operations
+}
+
+// revertChange returns the change setting a todo back to the passed previous state, including its trash state,
+// dropping the references which aren't valid according to the passed todos
+func revertChange(todos []todo.Todo, previousTodo todo.Todo, ifMatch string) func(todo.Todo) (todo.Todo, error) {
+	return func(currentTodo todo.Todo) (todo.Todo, error) {
+		err := CheckIfMatch(ifMatch, currentTodo)
+		if err != nil {
+			return todo.Todo{}, err
+		}
+		todoReverted := applyChange(currentTodo, previousTodo)
+		todoReverted.CompletedAt = previousTodo.CompletedAt
+		todoReverted.DeletedAt = previousTodo.DeletedAt
+		return dropInvalidReferences(todos, todoReverted), nil
+	}
+}
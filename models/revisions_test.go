@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/revision"
+	"todo-rest-backend/models/todo"
+)
+
+// setUpRevisions sets memory repositories for the todos and their revisions with the trash enabled
+func setUpRevisions(t *testing.T) {
+	t.Helper()
+	err := SetTodoRepository(&memrepo.MemoryTodoRepository{})
+	if err == nil {
+		err = Initialize()
+	}
+	if err == nil {
+		err = SetRevisionRepository(&memrepo.MemoryRevisionRepository{})
+	}
+	if err == nil {
+		err = InitializeRevisions()
+	}
+	if err == nil {
+		err = SetTrashMode(true, time.Hour)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		err := SetTrashMode(false, 0)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// inSession returns the context of a new change of the passed actor in the undo session with passed token
+func inSession(actor string, token string) context.Context {
+	origin := revision.Origin{Actor: actor, ChangeId: revision.NewChangeId()}
+	if token != "" {
+		origin.Session = revision.SessionKey(token)
+	}
+	return revision.NewContext(context.Background(), origin)
+}
+
+// readTodo returns the stored todo with passed id, also when it is in the trash
+func readTodo(t *testing.T, id string) todo.Todo {
+	t.Helper()
+	storedTodo, err := todoRepository.ReadTodoById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storedTodo
+}
+
+// actors returns the actors of the revisions of the todo with passed id, oldest first
+func actors(t *testing.T, id string) []string {
+	t.Helper()
+	revisions, err := ReadRevisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revisionActors []string
+	for _, currentRevision := range revisions {
+		revisionActors = append(revisionActors, currentRevision.Actor)
+	}
+	return revisionActors
+}
+
+func TestChangesAreAttributedToActorOfContext(t *testing.T) {
+	setUpRevisions(t)
+	alice := inSession("alice", "")
+	bob := inSession("bob", "")
+
+	created, err := CreateTodo(alice, todo.Todo{Title: "attributed", Description: "created by alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Title = "changed by bob"
+	_, err = UpdateTodoById(bob, created.Id, created, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DeleteTodoById(alice, created.Id, todo.Todo{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// purging the trash is done by the backend itself
+	_, err = PurgeTrash(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := actors(t, created.Id)
+	want := []string{"alice", "bob", "alice", revision.SystemActor}
+	if len(got) != len(want) {
+		t.Fatalf("revisions by %v, want %v", got, want)
+	}
+	for index := range want {
+		if got[index] != want[index] {
+			t.Errorf("revision %d by %s, want %s", index+1, got[index], want[index])
+		}
+	}
+}
+
+func TestUndoRevertsAllTodosOfTheLastChange(t *testing.T) {
+	setUpRevisions(t)
+	session := revision.NewSessionToken()
+	first, err := CreateTodo(inSession("alice", session), todo.Todo{Title: "first", Description: "tagged",
+		Tags: []string{"old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CreateTodo(inSession("alice", session), todo.Todo{Title: "second", Description: "tagged",
+		Tags: []string{"old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = RenameTag(inSession("alice", session), "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	todosUndone, revisionsUndone, err := UndoLastChange(inSession("alice", session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todosUndone) != 2 || len(revisionsUndone) != 2 {
+		t.Fatalf("%d todos and %d revisions undone, want the 2 of the rename", len(todosUndone), len(revisionsUndone))
+	}
+	for _, id := range []string{first.Id, second.Id} {
+		if tags := readTodo(t, id).Tags; len(tags) != 1 || tags[0] != "old" {
+			t.Errorf("todo %s tagged %v after the undo, want [old]", id, tags)
+		}
+	}
+
+	// the undo is a change itself, undoing it again redoes the rename
+	_, _, err = UndoLastChange(inSession("alice", session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := readTodo(t, first.Id).Tags; len(tags) != 1 || tags[0] != "new" {
+		t.Errorf("todo tagged %v after the second undo, want [new]", tags)
+	}
+}
+
+func TestUndoRevertsSideEffects(t *testing.T) {
+	setUpRevisions(t)
+	session := revision.NewSessionToken()
+	parent, err := CreateTodo(inSession("alice", session), todo.Todo{Title: "parent", Description: "deleted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := CreateTodo(inSession("alice", session), todo.Todo{Title: "child", Description: "detached",
+		ParentId: parent.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := CreateTodo(inSession("alice", session), todo.Todo{Title: "series", Description: "recurring",
+		Recurrence: "FREQ=DAILY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// moving the parent to the trash detaches the child
+	_, err = DeleteTodoById(inSession("alice", session), parent.Id, todo.Todo{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = UndoLastChange(inSession("alice", session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readTodo(t, parent.Id).IsDeleted() || readTodo(t, child.Id).ParentId != parent.Id {
+		t.Errorf("parent %+v and child %+v after the undo, want both restored", readTodo(t, parent.Id),
+			readTodo(t, child.Id))
+	}
+
+	// terminating a recurring todo creates the next occurrence
+	series.Terminated = true
+	_, err = UpdateTodoById(inSession("alice", session), series.Id, series, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	todosBefore, err := ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = UndoLastChange(inSession("alice", session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	todosAfter, err := ReadTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seriesAfter := readTodo(t, series.Id)
+	if len(todosAfter) != len(todosBefore)-1 || seriesAfter.Terminated || seriesAfter.Recurrence == "" {
+		t.Errorf("%d todos and series %+v after the undo, want the next occurrence deleted and the series continued",
+			len(todosAfter), seriesAfter)
+	}
+}
+
+func TestUndoIsLimitedToTheSession(t *testing.T) {
+	setUpRevisions(t)
+	aliceSession, bobSession := revision.NewSessionToken(), revision.NewSessionToken()
+	created, err := CreateTodo(inSession("alice", aliceSession), todo.Todo{Title: "alice's", Description: "created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another session claiming the same actor can't undo the change
+	_, _, err = UndoLastChange(inSession("alice", bobSession))
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v undoing in another session, want not found", err)
+	}
+	_, _, err = UndoLastChange(inSession("alice", ""))
+	if !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("got %v undoing without session, want not found", err)
+	}
+
+	// a change made afterward by another session prevents the undo, nothing is reverted
+	created.Title = "changed by bob"
+	_, err = UpdateTodoById(inSession("bob", bobSession), created.Id, created, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = UndoLastChange(inSession("alice", aliceSession))
+	if !errors.Is(err, repositories.ErrConflict) {
+		t.Errorf("got %v undoing a todo changed afterward, want a conflict", err)
+	}
+	if readTodo(t, created.Id).Title != "changed by bob" {
+		t.Errorf("todo %+v after the failed undo, want it unchanged", readTodo(t, created.Id))
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"todo-rest-backend/models/search"
+	"todo-rest-backend/models/todo"
+)
+
+// SearchDefaultLimit number of search results returned when no limit is passed
+const SearchDefaultLimit = 20
+
+// SearchMaxLimit maximum number of search results returned at once
+const SearchMaxLimit = 100
+
+// searchIndex index of the todos outside the trash, kept up to date by the decorated todo repository
+var searchIndex *search.Index
+
+// SearchMeta meta information of the search results
+type SearchMeta struct {
+	Query      string      `json:"query"`
+	TotalCount int         `json:"totalCount"` // todos matching the query, including those beyond the limit
+	Hits       []SearchHit `json:"hits"`       // in the order of the returned todos
+}
+
+// SearchHit ranking and highlighted snippets of a todo matching the search query
+type SearchHit struct {
+	Id         string                  `json:"id"`
+	Score      float64                 `json:"score"`
+	Highlights map[search.Field]string `json:"highlights"`
+}
+
+// SearchTodos returns up to limit todos (without trashed ones) matching the query, best matches first
+func SearchTodos(query string, limit int) ([]todo.Todo, SearchMeta, error) {
+	if searchIndex == nil {
+		return nil, SearchMeta{}, errors.New("todo repositories must not be nil")
+	}
+	results, err := searchIndex.Search(query)
+	if err != nil {
+		return nil, SearchMeta{}, err
+	}
+
+	meta := SearchMeta{Query: query, TotalCount: len(results), Hits: []SearchHit{}}
+	todos := []todo.Todo{}
+	for _, result := range results[:min(limit, len(results))] {
+		todos = append(todos, result.Todo)
+		meta.Hits = append(meta.Hits, SearchHit{Id: result.Todo.Id, Score: result.Score, Highlights: result.Highlights})
+	}
+	return todos, meta, nil
+}
@@ -0,0 +1,398 @@
+// Package search contains an in-process inverted index over the title, description and tags of the todos
+package search
+
+import (
+	"html"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"todo-rest-backend/models/todo"
+	"unicode"
+)
+
+// Field indexed field of a todo
+type Field string
+
+// Indexed fields
+const (
+	FieldTitle       Field = "title"
+	FieldDescription Field = "description"
+	FieldTags        Field = "tags"
+)
+
+// fieldWeights weights of the fields for ranking, matches in the title count most
+var fieldWeights = map[Field]float64{FieldTitle: 3, FieldTags: 2, FieldDescription: 1}
+
+// tagPositionGap gap between the positions of two tags, so that phrases don't match across tags
+const tagPositionGap = 100
+
+// prefixPenalty factor applied to the score of terms only matching by prefix
+const prefixPenalty = 0.8
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// SnippetContext number of tokens shown before the first match in a description snippet
+const SnippetContext = 5
+
+// SnippetLength maximum number of tokens of a description snippet
+const SnippetLength = 20
+
+// HighlightStart and HighlightEnd enclose matching terms in the snippets
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// Result of a search
+type Result struct {
+	Todo       todo.Todo
+	Score      float64
+	Highlights map[Field]string // snippets of the fields with matches, HTML escaped and with marked matches
+}
+
+// token of a text with its byte offsets
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+// posting positions of a term in the fields of a todo
+type posting map[Field][]int
+
+// Index inverted index of the todos not in the trash, safe for concurrent use
+type Index struct {
+	mutex        sync.RWMutex
+	postings     map[string]map[string]posting // term -> todo id -> positions
+	documents    map[string]todo.Todo
+	fieldLengths map[string]map[Field]int // todo id -> number of tokens per field
+	totalLengths map[Field]int
+	terms        []string // sorted vocabulary for prefix matching, nil when outdated
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings:     map[string]map[string]posting{},
+		documents:    map[string]todo.Todo{},
+		fieldLengths: map[string]map[Field]int{},
+		totalLengths: map[Field]int{},
+	}
+}
+
+// Rebuild replaces the content of the index by the passed todos
+func (i *Index) Rebuild(todos []todo.Todo) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.postings = map[string]map[string]posting{}
+	i.documents = map[string]todo.Todo{}
+	i.fieldLengths = map[string]map[Field]int{}
+	i.totalLengths = map[Field]int{}
+	i.terms = nil
+	for _, currentTodo := range todos {
+		i.add(currentTodo)
+	}
+}
+
+// Put adds the passed todo to the index or replaces its previous state. Todos in the trash are removed.
+func (i *Index) Put(changedTodo todo.Todo) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.remove(changedTodo.Id)
+	i.add(changedTodo)
+}
+
+// Remove removes the todo with passed id from the index
+func (i *Index) Remove(id string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.remove(id)
+}
+
+// Size returns the number of indexed todos
+func (i *Index) Size() int {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	return len(i.documents)
+}
+
+func (i *Index) add(todoToAdd todo.Todo) {
+	if todoToAdd.IsDeleted() {
+		return
+	}
+	i.documents[todoToAdd.Id] = todoToAdd
+	lengths := map[Field]int{}
+	for field, tokens := range fieldTokens(todoToAdd) {
+		for _, fieldToken := range tokens {
+			postings := i.postings[fieldToken.term]
+			if postings == nil {
+				postings = map[string]posting{}
+				i.postings[fieldToken.term] = postings
+				i.terms = nil
+			}
+			if postings[todoToAdd.Id] == nil {
+				postings[todoToAdd.Id] = posting{}
+			}
+			postings[todoToAdd.Id][field] = append(postings[todoToAdd.Id][field], fieldToken.start)
+		}
+		lengths[field] = len(tokens)
+		i.totalLengths[field] += len(tokens)
+	}
+	i.fieldLengths[todoToAdd.Id] = lengths
+}
+
+func (i *Index) remove(id string) {
+	indexedTodo, ok := i.documents[id]
+	if !ok {
+		return
+	}
+	for _, tokens := range fieldTokens(indexedTodo) {
+		for _, fieldToken := range tokens {
+			postings := i.postings[fieldToken.term]
+			delete(postings, id)
+			if len(postings) == 0 {
+				delete(i.postings, fieldToken.term)
+				i.terms = nil
+			}
+		}
+	}
+	for field, length := range i.fieldLengths[id] {
+		i.totalLengths[field] -= length
+	}
+	delete(i.fieldLengths, id)
+	delete(i.documents, id)
+}
+
+// fieldTokens returns the tokens of the indexed fields of the todo; for tags the start of a token is its position
+func fieldTokens(indexedTodo todo.Todo) map[Field][]token {
+	tokens := map[Field][]token{
+		FieldTitle:       positions(tokenize(indexedTodo.Title), 0),
+		FieldDescription: positions(tokenize(indexedTodo.Description), 0),
+	}
+	var tagTokens []token
+	for number, tag := range indexedTodo.Tags {
+		tagTokens = append(tagTokens, positions(tokenize(tag), number*tagPositionGap)...)
+	}
+	tokens[FieldTags] = tagTokens
+	return tokens
+}
+
+// positions replaces the byte offsets of the tokens by their positions, starting at offset
+func positions(tokens []token, offset int) []token {
+	for position := range tokens {
+		tokens[position].start = offset + position
+		tokens[position].end = offset + position
+	}
+	return tokens
+}
+
+// tokenize splits the text into lower case terms of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for offset, character := range text + " " {
+		isTermCharacter := unicode.IsLetter(character) || unicode.IsDigit(character)
+		switch {
+		case isTermCharacter && start < 0:
+			start = offset
+		case !isTermCharacter && start >= 0:
+			tokens = append(tokens, token{term: strings.ToLower(text[start:offset]), start: start, end: offset})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// Search returns the todos matching the query ordered by descending score (ties by id).
+// See Parse for the query syntax.
+func (i *Index) Search(query string) ([]Result, error) {
+	parsedQuery, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	vocabulary := i.vocabulary()
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	current := evaluation{index: i, vocabulary: vocabulary}
+	scores := parsedQuery.evaluate(current)
+	highlightTerms := map[string]bool{}
+	parsedQuery.collectTerms(current, highlightTerms)
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		indexedTodo := i.documents[id]
+		results = append(results, Result{Todo: indexedTodo, Score: math.Round(score*1000) / 1000,
+			Highlights: highlights(indexedTodo, highlightTerms)})
+	}
+	slices.SortFunc(results, func(left Result, right Result) int {
+		if left.Score != right.Score {
+			if left.Score > right.Score {
+				return -1
+			}
+			return 1
+		}
+		return todo.CompareIds(left.Todo.Id, right.Todo.Id)
+	})
+	return results, nil
+}
+
+// vocabulary returns the sorted indexed terms, sorting them again only after terms were added or removed.
+// The returned slice isn't changed afterwards, changes of the index replace it.
+func (i *Index) vocabulary() []string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.terms == nil {
+		i.terms = make([]string, 0, len(i.postings))
+		for term := range i.postings {
+			i.terms = append(i.terms, term)
+		}
+		sort.Strings(i.terms)
+	}
+	return i.terms
+}
+
+// scoreTerm returns the BM25F scores of the todos containing the term
+func (i *Index) scoreTerm(term string) map[string]float64 {
+	postings := i.postings[term]
+	scores := map[string]float64{}
+	idf := i.idf(len(postings))
+	for id, termPositions := range postings {
+		frequencies := map[Field]int{}
+		for field, fieldPositions := range termPositions {
+			frequencies[field] = len(fieldPositions)
+		}
+		scores[id] = i.score(id, frequencies, idf)
+	}
+	return scores
+}
+
+// scorePhrase returns the scores of the todos containing the terms as consecutive tokens of one field
+func (i *Index) scorePhrase(terms []string) map[string]float64 {
+	scores := map[string]float64{}
+	if len(terms) == 0 {
+		return scores
+	}
+	idf := 0.0
+	for _, term := range terms {
+		idf += i.idf(len(i.postings[term]))
+	}
+	for id, firstPositions := range i.postings[terms[0]] {
+		frequencies := map[Field]int{}
+		for field, starts := range firstPositions {
+			for _, start := range starts {
+				if i.containsPhraseAt(id, field, terms, start) {
+					frequencies[field]++
+				}
+			}
+		}
+		if len(frequencies) > 0 {
+			scores[id] = i.score(id, frequencies, idf)
+		}
+	}
+	return scores
+}
+
+func (i *Index) containsPhraseAt(id string, field Field, terms []string, start int) bool {
+	for offset, term := range terms[1:] {
+		if !slices.Contains(i.postings[term][id][field], start+offset+1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Index) idf(documentFrequency int) float64 {
+	count := float64(len(i.documents))
+	return math.Log(1 + (count-float64(documentFrequency)+0.5)/(float64(documentFrequency)+0.5))
+}
+
+// score combines the term frequencies of the fields, weighted and normalized by the field lengths (BM25F)
+func (i *Index) score(id string, frequencies map[Field]int, idf float64) float64 {
+	weightedFrequency := 0.0
+	for field, frequency := range frequencies {
+		averageLength := float64(i.totalLengths[field]) / math.Max(float64(len(i.documents)), 1)
+		normalization := 1.0
+		if averageLength > 0 {
+			normalization = 1 - bm25B + bm25B*float64(i.fieldLengths[id][field])/averageLength
+		}
+		weightedFrequency += fieldWeights[field] * float64(frequency) / normalization
+	}
+	return idf * weightedFrequency * (bm25K1 + 1) / (weightedFrequency + bm25K1)
+}
+
+// allDocuments returns a zero score for every indexed todo, the base of negations
+func (i *Index) allDocuments() map[string]float64 {
+	scores := make(map[string]float64, len(i.documents))
+	for id := range i.documents {
+		scores[id] = 0
+	}
+	return scores
+}
+
+// highlights returns the snippets of the fields containing one of the terms
+func highlights(indexedTodo todo.Todo, terms map[string]bool) map[Field]string {
+	snippets := map[Field]string{}
+	if snippet, ok := snippet(indexedTodo.Title, terms, 0); ok {
+		snippets[FieldTitle] = snippet
+	}
+	if snippet, ok := snippet(indexedTodo.Description, terms, SnippetLength); ok {
+		snippets[FieldDescription] = snippet
+	}
+	var matchingTags []string
+	for _, tag := range indexedTodo.Tags {
+		if snippet, ok := snippet(tag, terms, 0); ok {
+			matchingTags = append(matchingTags, snippet)
+		}
+	}
+	if len(matchingTags) > 0 {
+		snippets[FieldTags] = strings.Join(matchingTags, ", ")
+	}
+	return snippets
+}
+
+// snippet marks the terms in the text; with a positive length only that many tokens around the first match are kept
+func snippet(text string, terms map[string]bool, length int) (string, bool) {
+	tokens := tokenize(text)
+	first := slices.IndexFunc(tokens, func(textToken token) bool {
+		return terms[textToken.term]
+	})
+	if first < 0 {
+		return "", false
+	}
+
+	from, to := 0, len(tokens)
+	if length > 0 && len(tokens) > length {
+		from = max(0, first-SnippetContext)
+		to = min(len(tokens), from+length)
+	}
+	start, end := 0, len(text)
+	var builder strings.Builder
+	if from > 0 {
+		start = tokens[from].start
+		builder.WriteString("…")
+	}
+	if to < len(tokens) {
+		end = tokens[to-1].end
+	}
+	position := start
+	for _, textToken := range tokens[from:to] {
+		if !terms[textToken.term] {
+			continue
+		}
+		builder.WriteString(html.EscapeString(text[position:textToken.start]))
+		builder.WriteString(HighlightStart + html.EscapeString(text[textToken.start:textToken.end]) + HighlightEnd)
+		position = textToken.end
+	}
+	builder.WriteString(html.EscapeString(text[position:end]))
+	if end < len(text) {
+		builder.WriteString("…")
+	}
+	return builder.String(), true
+}
@@ -0,0 +1,15 @@
+package search
+
+import (
+	"context"
+	"todo-rest-backend/models/repositories"
+)
+
+// TodoChanged keeps the index up to date with the changes of the observed todo repository
+func (i *Index) TodoChanged(_ context.Context, mutation repositories.Mutation) {
+	if mutation.Kind == repositories.MutationDeleted {
+		i.Remove(mutation.After.Id)
+		return
+	}
+	i.Put(mutation.After)
+}
@@ -0,0 +1,394 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxQueryTerms maximum number of terms of a search query
+const MaxQueryTerms = 32
+
+// ErrInvalidQuery is returned for search queries which can't be parsed
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Operators of the query syntax (only recognized in upper case)
+const (
+	operatorAnd = "AND"
+	operatorOr  = "OR"
+	operatorNot = "NOT"
+)
+
+// Query parsed search query
+type Query struct {
+	root node
+}
+
+// evaluation state of a query against an index
+type evaluation struct {
+	index      *Index
+	vocabulary []string
+}
+
+// expand returns the indexed terms starting with prefix
+func (e evaluation) expand(prefix string) []string {
+	first := sort.SearchStrings(e.vocabulary, prefix)
+	var expanded []string
+	for _, term := range e.vocabulary[first:] {
+		if !strings.HasPrefix(term, prefix) {
+			break
+		}
+		expanded = append(expanded, term)
+	}
+	return expanded
+}
+
+func (q Query) evaluate(current evaluation) map[string]float64 {
+	return q.root.evaluate(current)
+}
+
+func (q Query) collectTerms(current evaluation, terms map[string]bool) {
+	q.root.collectTerms(current, terms)
+}
+
+// node of the query syntax tree
+type node interface {
+	// evaluate returns the scores of the matching todos by id
+	evaluate(current evaluation) map[string]float64
+	// collectTerms adds the terms to highlight (those not negated)
+	collectTerms(current evaluation, terms map[string]bool)
+}
+
+// termNode single term, optionally matching all terms starting with it
+type termNode struct {
+	term   string
+	prefix bool
+}
+
+func (n termNode) evaluate(current evaluation) map[string]float64 {
+	if !n.prefix {
+		return current.index.scoreTerm(n.term)
+	}
+	// a todo matching several expansions counts with its best one
+	scores := map[string]float64{}
+	for _, term := range current.expand(n.term) {
+		factor := prefixPenalty
+		if term == n.term {
+			factor = 1
+		}
+		for id, score := range current.index.scoreTerm(term) {
+			scores[id] = max(scores[id], score*factor)
+		}
+	}
+	return scores
+}
+
+func (n termNode) collectTerms(current evaluation, terms map[string]bool) {
+	if !n.prefix {
+		terms[n.term] = true
+		return
+	}
+	for _, term := range current.expand(n.term) {
+		terms[term] = true
+	}
+}
+
+// phraseNode terms which have to follow each other in one field
+type phraseNode struct {
+	terms []string
+}
+
+func (n phraseNode) evaluate(current evaluation) map[string]float64 {
+	return current.index.scorePhrase(n.terms)
+}
+
+func (n phraseNode) collectTerms(_ evaluation, terms map[string]bool) {
+	for _, term := range n.terms {
+		terms[term] = true
+	}
+}
+
+// andNode matches todos matching all children, negated children exclude todos
+type andNode struct {
+	children []node
+}
+
+func (n andNode) evaluate(current evaluation) map[string]float64 {
+	var scores map[string]float64
+	var excluded []map[string]float64
+	for _, child := range n.children {
+		if negation, ok := child.(notNode); ok {
+			excluded = append(excluded, negation.child.evaluate(current))
+			continue
+		}
+		childScores := child.evaluate(current)
+		if scores == nil {
+			scores = childScores
+			continue
+		}
+		for id, score := range scores {
+			childScore, ok := childScores[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] = score + childScore
+		}
+	}
+	if scores == nil {
+		// only negations
+		scores = current.index.allDocuments()
+	}
+	for _, excludedScores := range excluded {
+		for id := range excludedScores {
+			delete(scores, id)
+		}
+	}
+	return scores
+}
+
+func (n andNode) collectTerms(current evaluation, terms map[string]bool) {
+	for _, child := range n.children {
+		child.collectTerms(current, terms)
+	}
+}
+
+// orNode matches todos matching any child, scores of several matching children add up
+type orNode struct {
+	children []node
+}
+
+func (n orNode) evaluate(current evaluation) map[string]float64 {
+	scores := map[string]float64{}
+	for _, child := range n.children {
+		for id, score := range child.evaluate(current) {
+			scores[id] += score
+		}
+	}
+	return scores
+}
+
+func (n orNode) collectTerms(current evaluation, terms map[string]bool) {
+	for _, child := range n.children {
+		child.collectTerms(current, terms)
+	}
+}
+
+// notNode matches todos not matching the child
+type notNode struct {
+	child node
+}
+
+func (n notNode) evaluate(current evaluation) map[string]float64 {
+	scores := current.index.allDocuments()
+	for id := range n.child.evaluate(current) {
+		delete(scores, id)
+	}
+	return scores
+}
+
+func (n notNode) collectTerms(evaluation, map[string]bool) {
+	// negated terms aren't highlighted
+}
+
+// item lexical element of a query
+type item struct {
+	kind string // "word", "phrase", "minus", "(" or ")"
+	text string
+}
+
+// Parse parses a search query. Terms are matched case-insensitively against the words of the title, description and
+// tags; a term ending with "*" matches all words starting with it and terms in double quotes have to follow each
+// other as phrase. Terms are combined with AND by default, OR and NOT (or a leading "-") are supported as well as
+// parentheses. AND binds stronger than OR.
+func Parse(query string) (Query, error) {
+	items, err := lex(query)
+	if err != nil {
+		return Query{}, err
+	}
+	currentParser := parser{items: items}
+	root, err := currentParser.parseOr()
+	if err != nil {
+		return Query{}, err
+	}
+	if currentParser.position < len(items) {
+		return Query{}, fmt.Errorf("%w: unexpected %q", ErrInvalidQuery, items[currentParser.position].kind)
+	}
+	if currentParser.termCount > MaxQueryTerms {
+		return Query{}, fmt.Errorf("%w: more than %d terms", ErrInvalidQuery, MaxQueryTerms)
+	}
+	return Query{root: root}, nil
+}
+
+func lex(query string) ([]item, error) {
+	var items []item
+	runes := []rune(query)
+	for position := 0; position < len(runes); {
+		character := runes[position]
+		switch {
+		case character == ' ' || character == '\t' || character == '\n':
+			position++
+		case character == '(' || character == ')':
+			items = append(items, item{kind: string(character)})
+			position++
+		case character == '"':
+			end := position + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated phrase", ErrInvalidQuery)
+			}
+			items = append(items, item{kind: "phrase", text: string(runes[position+1 : end])})
+			position = end + 1
+		case character == '-' && (position == 0 || strings.ContainsRune(" \t\n(", runes[position-1])):
+			items = append(items, item{kind: "minus"})
+			position++
+		default:
+			end := position
+			for end < len(runes) && !strings.ContainsRune(" \t\n()\"", runes[end]) {
+				end++
+			}
+			items = append(items, item{kind: "word", text: string(runes[position:end])})
+			position = end
+		}
+	}
+	return items, nil
+}
+
+// parser recursive descent parser of the query items
+type parser struct {
+	items     []item
+	position  int
+	termCount int
+}
+
+func (p *parser) peek() (item, bool) {
+	if p.position >= len(p.items) {
+		return item{}, false
+	}
+	return p.items[p.position], true
+}
+
+func (p *parser) peekOperator(operator string) bool {
+	next, ok := p.peek()
+	return ok && next.kind == "word" && next.text == operator
+}
+
+// parseOr parses: and ("OR" and)*
+func (p *parser) parseOr() (node, error) {
+	var children []node
+	for {
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if !p.peekOperator(operatorOr) {
+			break
+		}
+		p.position++
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return orNode{children: children}, nil
+}
+
+// parseAnd parses: unary (["AND"] unary)*
+func (p *parser) parseAnd() (node, error) {
+	var children []node
+	for {
+		next, ok := p.peek()
+		if !ok || next.kind == ")" || p.peekOperator(operatorOr) {
+			break
+		}
+		if p.peekOperator(operatorAnd) {
+			if len(children) == 0 {
+				return nil, fmt.Errorf("%w: %s without operand", ErrInvalidQuery, operatorAnd)
+			}
+			p.position++
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+	switch len(children) {
+	case 0:
+		return nil, fmt.Errorf("%w: no searchable terms", ErrInvalidQuery)
+	case 1:
+		if _, ok := children[0].(notNode); !ok {
+			return children[0], nil
+		}
+	}
+	return andNode{children: children}, nil
+}
+
+// parseUnary parses: ("NOT" | "-") unary | primary; nil is returned for terms without searchable characters
+func (p *parser) parseUnary() (node, error) {
+	next, ok := p.peek()
+	if ok && (next.kind == "minus" || p.peekOperator(operatorNot)) {
+		p.position++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return nil, fmt.Errorf("%w: %s without operand", ErrInvalidQuery, operatorNot)
+		}
+		return notNode{child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses: "(" or ")" | phrase | word
+func (p *parser) parsePrimary() (node, error) {
+	next, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: operand missing at the end", ErrInvalidQuery)
+	}
+	p.position++
+	switch next.kind {
+	case "(":
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := p.peek()
+		if !ok || closing.kind != ")" {
+			return nil, fmt.Errorf("%w: missing %q", ErrInvalidQuery, ")")
+		}
+		p.position++
+		return child, nil
+	case "phrase":
+		return p.termsNode(next.text, false), nil
+	case "word":
+		if next.text == operatorAnd || next.text == operatorOr || next.text == operatorNot {
+			return nil, fmt.Errorf("%w: %s without operand", ErrInvalidQuery, next.text)
+		}
+		return p.termsNode(strings.TrimSuffix(next.text, "*"), strings.HasSuffix(next.text, "*")), nil
+	}
+	return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidQuery, next.kind)
+}
+
+// termsNode returns the node for the words of text: a term for a single word and a phrase for several words
+func (p *parser) termsNode(text string, prefix bool) node {
+	tokens := tokenize(text)
+	p.termCount += len(tokens)
+	switch len(tokens) {
+	case 0:
+		return nil
+	case 1:
+		return termNode{term: tokens[0].term, prefix: prefix}
+	}
+	terms := make([]string, 0, len(tokens))
+	for _, termToken := range tokens {
+		terms = append(terms, termToken.term)
+	}
+	return phraseNode{terms: terms}
+}
@@ -0,0 +1,184 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  node
+	}{
+		{"milk", termNode{term: "milk"}},
+		{"  MiLk  ", termNode{term: "milk"}},
+		{"mil*", termNode{term: "mil", prefix: true}},
+		{`"buy fresh milk"`, phraseNode{terms: []string{"buy", "fresh", "milk"}}},
+		{`"milk"`, termNode{term: "milk"}},
+		{"buy-milk", phraseNode{terms: []string{"buy", "milk"}}},
+		{"buy milk", andNode{children: []node{termNode{term: "buy"}, termNode{term: "milk"}}}},
+		{"buy AND milk", andNode{children: []node{termNode{term: "buy"}, termNode{term: "milk"}}}},
+		{"buy OR milk", orNode{children: []node{termNode{term: "buy"}, termNode{term: "milk"}}}},
+		// operators are only recognized in upper case
+		{"buy or milk", andNode{children: []node{termNode{term: "buy"}, termNode{term: "or"}, termNode{term: "milk"}}}},
+		// AND binds stronger than OR
+		{"a b OR c", orNode{children: []node{
+			andNode{children: []node{termNode{term: "a"}, termNode{term: "b"}}}, termNode{term: "c"}}}},
+		{"a (b OR c)", andNode{children: []node{
+			termNode{term: "a"}, orNode{children: []node{termNode{term: "b"}, termNode{term: "c"}}}}}},
+		{"-milk", andNode{children: []node{notNode{child: termNode{term: "milk"}}}}},
+		{"NOT milk", andNode{children: []node{notNode{child: termNode{term: "milk"}}}}},
+		{"buy -milk", andNode{children: []node{termNode{term: "buy"}, notNode{child: termNode{term: "milk"}}}}},
+		{"NOT NOT milk", andNode{children: []node{notNode{child: notNode{child: termNode{term: "milk"}}}}}},
+		// terms without searchable characters are dropped
+		{"milk !!", termNode{term: "milk"}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.root, test.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", test.query, got.root, test.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidQueries(t *testing.T) {
+	tooManyTerms := ""
+	for index := 0; index <= MaxQueryTerms; index++ {
+		tooManyTerms += "term "
+	}
+	for _, query := range []string{"", "   ", "!!", `"unterminated`, "(milk", "milk)", "AND milk", "milk AND",
+		"milk OR", "OR milk", "NOT", "-", "milk NOT", "()", tooManyTerms} {
+		_, err := Parse(query)
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Parse(%q) = %v, want an invalid query", query, err)
+		}
+	}
+}
+
+// newTestIndex returns an index of the passed todos
+func newTestIndex(todos ...todo.Todo) *Index {
+	index := NewIndex()
+	index.Rebuild(todos)
+	return index
+}
+
+// ids returns the ids of the results in their order
+func ids(results []Result) []string {
+	var resultIds []string
+	for _, result := range results {
+		resultIds = append(resultIds, result.Todo.Id)
+	}
+	return resultIds
+}
+
+func TestSearch(t *testing.T) {
+	trashedAt := time.Now()
+	index := newTestIndex(
+		todo.Todo{Id: "1", Title: "Buy milk", Description: "fresh milk from the farm", Tags: []string{"shopping"}},
+		todo.Todo{Id: "2", Title: "Call mom", Description: "ask about the milk delivery"},
+		todo.Todo{Id: "3", Title: "Milkshake recipe", Description: "banana and milk blended with ice and honey"},
+		todo.Todo{Id: "4", Title: "Shopping list", Description: "bread, eggs", Tags: []string{"milk"}},
+		todo.Todo{Id: "5", Title: "Trashed milk", DeletedAt: &trashedAt},
+	)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// title matches rank before tag matches before description matches, matches in shorter fields first;
+		// trashed todos aren't indexed
+		{"milk", []string{"1", "4", "2", "3"}},
+		// the rare prefix expansion milkshake in a title outweighs the frequent milk
+		{"milk*", []string{"3", "1", "4", "2"}},
+		{`"milk delivery"`, []string{"2"}},
+		{`"delivery milk"`, nil},
+		{"milk -farm", []string{"4", "2", "3"}},
+		{"milk NOT (farm OR banana)", []string{"4", "2"}},
+		{"mom OR banana", []string{"2", "3"}},
+		{"shopping", []string{"4", "1"}},
+		{"unknown", nil},
+		{"-milk", nil},
+	}
+	for _, test := range tests {
+		results, err := index.Search(test.query)
+		if err != nil {
+			t.Errorf("Search(%q): %v", test.query, err)
+			continue
+		}
+		if got := ids(results); !slices.Equal(got, test.want) {
+			t.Errorf("Search(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSearchOrdersEqualScoresById(t *testing.T) {
+	// the same order as the todo list: numeric ids by value before other ids
+	index := newTestIndex(
+		todo.Todo{Id: "b", Title: "same"}, todo.Todo{Id: "10", Title: "same"}, todo.Todo{Id: "9", Title: "same"},
+		todo.Todo{Id: "a", Title: "same"},
+	)
+	results, err := index.Search("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"9", "10", "a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+}
+
+func TestSearchFollowsChanges(t *testing.T) {
+	index := newTestIndex(todo.Todo{Id: "1", Title: "old title"})
+	index.Put(todo.Todo{Id: "1", Title: "new title"})
+	index.Put(todo.Todo{Id: "2", Title: "other title"})
+
+	for query, want := range map[string][]string{"old": nil, "new": {"1"}, "tit*": {"1", "2"}} {
+		results, err := index.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(results); !slices.Equal(got, want) {
+			t.Errorf("Search(%q) = %v, want %v", query, got, want)
+		}
+	}
+	index.Remove("1")
+	if index.Size() != 1 {
+		t.Errorf("%d indexed todos after the removal, want 1", index.Size())
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	index := newTestIndex(todo.Todo{Id: "1", Title: "Buy <milk>",
+		Description: "one two three four five six seven eight nine ten milk eleven twelve thirteen fourteen fifteen " +
+			"sixteen seventeen eighteen nineteen twenty twentyone twentytwo", Tags: []string{"dairy", "milk products"}})
+
+	results, err := index.Search("milk -seven")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("results %v for a negated description term, want none", ids(results))
+	}
+	results, err = index.Search("milk -banana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d results, want 1", len(results))
+	}
+	want := map[Field]string{
+		FieldTitle: "Buy &lt;<mark>milk</mark>&gt;",
+		FieldDescription: "…six seven eight nine ten <mark>milk</mark> eleven twelve thirteen fourteen fifteen sixteen " +
+			"seventeen eighteen nineteen twenty twentyone twentytwo",
+		FieldTags: "<mark>milk</mark> products",
+	}
+	if !reflect.DeepEqual(results[0].Highlights, want) {
+		t.Errorf("highlights %q, want %q", results[0].Highlights, want)
+	}
+}
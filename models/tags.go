@@ -0,0 +1,146 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"todo-rest-backend/models/todo"
+	"unicode"
+)
+
+// TagMaxLength maximum length of a tag
+const TagMaxLength = 64
+
+// ErrInvalidTag is returned for blank or too long tags and tags with control characters
+var ErrInvalidTag = errors.New("invalid tag")
+
+// TagUsage type definition with json tags
+type TagUsage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"` // number of todos (not in the trash) having the tag
+}
+
+// TagChangeMeta meta information of a tag rename or merge
+type TagChangeMeta struct {
+	UpdatedTodos int `json:"updatedTodos"`
+}
+
+// normalizeTags trims the tags and removes duplicates, keeping the order of the first occurrences
+func normalizeTags(tags []string) []string {
+	normalizedTags := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !slices.Contains(normalizedTags, tag) {
+			normalizedTags = append(normalizedTags, tag)
+		}
+	}
+	return normalizedTags
+}
+
+// validateTag checks a single (normalized) tag
+func validateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("%w: tags must not be blank", ErrInvalidTag)
+	}
+	if len(tag) > TagMaxLength {
+		return fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidTag, tag, TagMaxLength)
+	}
+	if strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: tag %q must not contain control characters", ErrInvalidTag, tag)
+	}
+	return nil
+}
+
+// ReadTagUsage returns all tags of the todo's not in the trash with their usage count, most used first
+func ReadTagUsage() ([]TagUsage, error) {
+	todos, err := ReadTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, currentTodo := range todos {
+		for _, tag := range currentTodo.Tags {
+			counts[tag]++
+		}
+	}
+
+	tagUsages := []TagUsage{}
+	for name, count := range counts {
+		tagUsages = append(tagUsages, TagUsage{Name: name, Count: count})
+	}
+	slices.SortFunc(tagUsages, func(left TagUsage, right TagUsage) int {
+		return cmp.Or(cmp.Compare(right.Count, left.Count), strings.Compare(left.Name, right.Name))
+	})
+	return tagUsages, nil
+}
+
+// RenameTag renames the tag on all todo's (including the trash). When a todo already has the new tag,
+// both are merged. Returns the number of updated todo's.
+func RenameTag(ctx context.Context, oldName string, newName string) (int, error) {
+	return MergeTags(ctx, []string{oldName}, newName)
+}
+
+// MergeTags replaces the source tags by the target tag on all todo's (including the trash).
+// Returns the number of updated todo's.
+func MergeTags(ctx context.Context, sourceNames []string, targetName string) (int, error) {
+	if todoRepository == nil {
+		return 0, errors.New("todo repositories must not be nil")
+	}
+
+	targetName = strings.TrimSpace(targetName)
+	err := validateTag(targetName)
+	if err != nil {
+		return 0, err
+	}
+	sourceNames = normalizeTags(sourceNames)
+	if len(sourceNames) == 0 {
+		return 0, fmt.Errorf("%w: no tags to rename", ErrInvalidTag)
+	}
+	for _, sourceName := range sourceNames {
+		err = validateTag(sourceName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	todos, err := todoRepository.ReadTodos()
+	if err != nil {
+		return 0, err
+	}
+
+	updatedCount := 0
+	for _, currentTodo := range todos {
+		if !matchesAnyTag(currentTodo, sourceNames) {
+			continue
+		}
+		_, err = todoRepository.WithContext(ctx).PatchTodoById(currentTodo.Id, func(storedTodo todo.Todo) (todo.Todo, error) {
+			todoRenamed := storedTodo
+			todoRenamed.Tags = nil
+			for _, tag := range storedTodo.Tags {
+				if slices.Contains(sourceNames, tag) {
+					tag = targetName
+				}
+				todoRenamed.Tags = append(todoRenamed.Tags, tag)
+			}
+			return applyChange(storedTodo, todoRenamed), nil
+		})
+		if err != nil {
+			return updatedCount, err
+		}
+		updatedCount++
+	}
+	return updatedCount, nil
+}
+
+func matchesAnyTag(currentTodo todo.Todo, tags []string) bool {
+	for _, tag := range tags {
+		if slices.Contains(currentTodo.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,146 @@
 // Package todo contains the model parts
 package todo
 
-import "strconv"
+import (
+	"cmp"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Priority of a todo
+type Priority string
+
+// Priorities in ascending order
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// Priorities all valid priorities in ascending order
+var Priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityUrgent}
+
+// IsValid checks whether the priority is one of the defined priorities
+func (p Priority) IsValid() bool {
+	return p.Rank() >= 0
+}
+
+// Rank returns the position of the priority in ascending order or -1 for an invalid priority
+func (p Priority) Rank() int {
+	for rank, priority := range Priorities {
+		if p == priority {
+			return rank
+		}
+	}
+	return -1
+}
+
+// TimeLayout used for serializing points in time. In contrast to time.RFC3339Nano the fraction has a fixed width,
+// so that serialized values in UTC sort like the points in time they represent.
+const TimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
 
 // Todo type definition with json tags
 type Todo struct {
-	Id          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Terminated  bool   `json:"terminated"`
+	Id          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Terminated  bool       `json:"terminated"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"` // Set when the todo was moved to the trash
+	Version     int64      `json:"version"`             // Incremented on every change, used as ETag
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Priority    Priority   `json:"priority"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`   // Set by the models layer
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`   // Set by the models layer
+	CompletedAt *time.Time `json:"completedAt,omitempty"` // Set by the models layer while terminated
+	Tags        []string   `json:"tags"`
+	ListId      string     `json:"listId,omitempty"`     // Id of the list containing the todo, empty when in no list
+	ParentId    string     `json:"parentId,omitempty"`   // Id of the parent todo of a subtask, empty for top level todos
+	BlockedBy   []string   `json:"blockedBy,omitempty"`  // Ids of the todos which have to be terminated before this one
+	Recurrence  string     `json:"recurrence,omitempty"` // Recurrence rule (subset of iCalendar RRULE), empty for one-off todos
+}
+
+// IsDeleted returns whether the todo is in the trash
+func (t Todo) IsDeleted() bool {
+	return t.DeletedAt != nil
 }
 
 // Serialize serializes the passed to todo into a slice form
 func (t Todo) Serialize() []string {
-	todoSerialized := []string{t.Id, t.Title, t.Description, strconv.FormatBool(t.Terminated)}
+	todoSerialized := []string{t.Id, t.Title, t.Description, strconv.FormatBool(t.Terminated), FormatTime(t.DeletedAt),
+		strconv.FormatInt(t.Version, 10), FormatTime(t.DueAt), string(t.Priority), FormatTime(t.CreatedAt),
+		FormatTime(t.UpdatedAt), FormatTime(t.CompletedAt), FormatTags(t.Tags), t.ListId, t.ParentId, FormatIds(t.BlockedBy),
+		t.Recurrence}
 	return todoSerialized
 }
+
+// FormatTags formats the tags for serialization into a single value (a JSON array, so tags may contain any character)
+func FormatTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	formatted, _ := json.Marshal(tags)
+	return string(formatted)
+}
+
+// ParseTags parses tags formatted by FormatTags
+func ParseTags(value string) ([]string, error) {
+	tags := []string{}
+	if value == "" {
+		return tags, nil
+	}
+	err := json.Unmarshal([]byte(value), &tags)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// FormatIds formats todo ids for serialization into a single value (encoded like tags)
+func FormatIds(ids []string) string {
+	return FormatTags(ids)
+}
+
+// ParseIds parses todo ids formatted by FormatIds
+func ParseIds(value string) ([]string, error) {
+	return ParseTags(value)
+}
+
+// CompareIds compares two ids, returning a negative value when left sorts before right. Numeric ids sort by value
+// before all other ids, which sort lexicographically.
+func CompareIds(left string, right string) int {
+	leftValueAsInt, leftErr := strconv.ParseUint(left, 10, 64)
+	rightValueAsInt, rightErr := strconv.ParseUint(right, 10, 64)
+	switch {
+	case leftErr == nil && rightErr == nil:
+		return cmp.Compare(leftValueAsInt, rightValueAsInt)
+	case leftErr == nil:
+		return -1
+	case rightErr == nil:
+		return 1
+	default:
+		return strings.Compare(left, right)
+	}
+}
+
+// FormatTime formats an optional point in time for serialization (empty when nil)
+func FormatTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(TimeLayout)
+}
+
+// ParseTime parses an optional point in time formatted by FormatTime
+func ParseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
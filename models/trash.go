@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+// TrashPurgeInterval interval in which the trash is checked for todos to purge
+const TrashPurgeInterval = time.Hour
+
+var trashEnabled bool
+var trashRetention time.Duration
+
+// SetTrashMode enables or disables the trash and sets the retention period after which trashed todos are purged
+func SetTrashMode(enabled bool, retention time.Duration) error {
+	if enabled && retention <= 0 {
+		return errors.New("trash retention must be positive")
+	}
+	trashEnabled = enabled
+	trashRetention = retention
+	return nil
+}
+
+// ReadTrashedTodos returns the todo's in the trash from repository (abstracted by repository pattern)
+func ReadTrashedTodos() ([]todo.Todo, error) {
+	if todoRepository == nil {
+		return nil, errors.New("todo repositories must not be nil")
+	}
+	return readTodosFiltered(repositories.TodoFilter{Trashed: true})
+}
+
+// RestoreTodoById moves the todo with passed id out of the trash and returns the restored todo
+func RestoreTodoById(ctx context.Context, id string) (todo.Todo, error) {
+	if todoRepository == nil {
+		return todo.Todo{}, errors.New("todo repositories must not be nil")
+	}
+	// the parent or blockers may have been deleted or moved to the trash while the todo was in the trash
+	return withReferencesLocked(func(todos []todo.Todo) (todo.Todo, error) {
+		return todoRepository.WithContext(ctx).PatchTodoById(id, restoreChange(todos))
+	})
+}
+
+// restoreChange returns the change moving a todo out of the trash, dropping the references to
+// todos which aren't valid anymore according to the passed todos
+func restoreChange(todos []todo.Todo) func(todo.Todo) (todo.Todo, error) {
+	return func(currentTodo todo.Todo) (todo.Todo, error) {
+		if !currentTodo.IsDeleted() {
+			return todo.Todo{}, fmt.Errorf("id %w in trash", repositories.ErrNotFound)
+		}
+		todoRestored := applyChange(currentTodo, currentTodo)
+		todoRestored.DeletedAt = nil
+		if todos == nil && hasReferences(todoRestored) {
+			return todo.Todo{}, errReferencesNeeded
+		}
+		return dropInvalidReferences(todos, todoRestored), nil
+	}
+}
+
+// dropInvalidReferences returns the passed todo without the references which aren't valid anymore according to the
+// passed todos, e.g. to a list deleted while the todo was in the trash
+func dropInvalidReferences(todos []todo.Todo, referringTodo todo.Todo) todo.Todo {
+	if referringTodo.ListId != "" && !listExists(referringTodo.ListId) {
+		referringTodo.ListId = ""
+	}
+	if validateParent(todos, referringTodo.Id, referringTodo.ParentId) != nil {
+		referringTodo.ParentId = ""
+	}
+	// the slice is shared with the stored todo until the change is stored
+	referringTodo.BlockedBy = slices.DeleteFunc(slices.Clone(referringTodo.BlockedBy), func(blockerId string) bool {
+		return validateBlockers(todos, referringTodo.Id, []string{blockerId}) != nil
+	})
+	return referringTodo
+}
+
+// PurgeTrash deletes the todo's which are in the trash for longer than the retention period and returns their count
+func PurgeTrash(now time.Time) (int, error) {
+	trashedTodos, err := ReadTrashedTodos()
+	if err != nil {
+		return 0, err
+	}
+
+	purgedCount := 0
+	for _, trashedTodo := range trashedTodos {
+		if now.Sub(*trashedTodo.DeletedAt) < trashRetention {
+			continue
+		}
+		err = purgeTodo(trashedTodo)
+		if errors.Is(err, repositories.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return purgedCount, err
+		}
+		purgedCount++
+	}
+	return purgedCount, nil
+}
+
+// purgeTodo deletes the trashed todo and the references to it left behind, e.g. by a failed detach when it was moved
+// to the trash
+func purgeTodo(trashedTodo todo.Todo) error {
+	referenceMutex.Lock()
+	defer referenceMutex.Unlock()
+	// passing the read todo lets the repository skip todos restored in the meantime; without actor in its
+	// context the deletion is attributed to revision.SystemActor
+	_, err := todoRepository.DeleteTodoById(trashedTodo.Id, trashedTodo)
+	if err != nil {
+		return err
+	}
+	// the todo is deleted already, a failed detach leaves references to it behind but doesn't fail the purge
+	err = detachReferences(context.Background(), trashedTodo.Id)
+	if err != nil {
+		log.Println("detaching the references to purged todo", trashedTodo.Id, "failed:", err)
+	}
+	return nil
+}
+
+// StartTrashPurge purges the trash now and then periodically in the background until stop is closed
+func StartTrashPurge(stop <-chan struct{}) {
+	purge := func() {
+		_, err := PurgeTrash(time.Now())
+		if err != nil {
+			log.Println("purging trash failed:", err)
+		}
+	}
+
+	purge()
+	go func() {
+		ticker := time.NewTicker(TrashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				purge()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
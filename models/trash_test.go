@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+func TestPurgeTrashDetachesReferencesLeftBehind(t *testing.T) {
+	setUpRevisions(t)
+	ctx := context.Background()
+	trashed := createTodo(t, "trashed", "")
+	other := createTodo(t, "other", "")
+	referring := createTodo(t, "referring", "", other.Id)
+	_, err := DeleteTodoById(ctx, trashed.Id, todo.Todo{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// like a detach which failed when the todo was moved to the trash
+	_, err = todoRepository.PatchTodoById(referring.Id, func(currentTodo todo.Todo) (todo.Todo, error) {
+		currentTodo.ParentId = trashed.Id
+		currentTodo.BlockedBy = []string{other.Id, trashed.Id}
+		return currentTodo, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purgedCount, err := PurgeTrash(time.Now().Add(2 * time.Hour))
+	if err != nil || purgedCount != 1 {
+		t.Fatalf("purged %d todos (%v), want 1", purgedCount, err)
+	}
+	detached := readTodo(t, referring.Id)
+	if detached.ParentId != "" || !slices.Equal(detached.BlockedBy, []string{other.Id}) {
+		t.Errorf("todo with parent %q blocked by %v after the purge, want no parent blocked by [%s]", detached.ParentId,
+			detached.BlockedBy, other.Id)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package utils
+
+import "os"
+
+// LockFile opens the passed lock file. Advisory file locks are not supported on this platform,
+// so only the in-process locking of the callers applies.
+func LockFile(lockFileName string) (*os.File, error) {
+	return os.OpenFile(lockFileName, os.O_CREATE|os.O_RDWR, 0644)
+}
+
+// UnlockFile closes the file opened by LockFile
+func UnlockFile(file *os.File) error {
+	return file.Close()
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// LockFile takes an exclusive advisory lock on the passed lock file (created when not existing),
+// blocking until the lock is available. The returned file must be passed to UnlockFile.
+func LockFile(lockFileName string) (*os.File, error) {
+	file, err := os.OpenFile(lockFileName, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// UnlockFile releases the lock taken by LockFile
+func UnlockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	CloseFileAndHandleError(file, &err)
+	return err
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// The logs of the journal and the event-sourced repositories consist of entries "<crc32 of json in hex> <json>\n".
+// A crash during a write leaves at most the last entry incomplete, which is ignored and cut off when the log is
+// opened for appending again.
+
+// OpenLogForAppending opens the log for appending after its valid part (see ReadLogEntries), cutting off a truncated
+// last entry left behind by a crash during a write
+func OpenLogForAppending(path string, validSize int64) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = file.Truncate(validSize)
+	if err == nil {
+		_, err = file.Seek(validSize, io.SeekStart)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// AppendLogEntry durably appends the value as json entry to the log of passed size and adds the entry to the size.
+// A failed write is cut off again, so that later entries don't follow a corrupt one.
+func AppendLogEntry(logFile *os.File, size *int64, value interface{}) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)
+
+	_, err = logFile.WriteString(line)
+	if err == nil {
+		err = logFile.Sync()
+	}
+	if err != nil {
+		truncateErr := logFile.Truncate(*size)
+		if truncateErr == nil {
+			_, truncateErr = logFile.Seek(*size, io.SeekStart)
+		}
+		return errors.Join(err, truncateErr)
+	}
+	*size += int64(len(line))
+	return nil
+}
+
+// ReadLogEntries passes the json payloads of the entries of the log between offset and end (-1 for the end of the
+// file) with their positions to handle, until it returns false. It returns the offset after the last complete entry.
+// An incomplete or corrupt last entry is ignored, a corrupt entry followed by others is an error. A missing log is
+// empty.
+func ReadLogEntries(path string, offset int64, end int64, handle func([]byte, int64) (bool, error)) (validSize int64, err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer CloseFileAndHandleError(file, &err)
+
+	if end < 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return 0, err
+		}
+		end = info.Size()
+	}
+	if offset > end {
+		return offset, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, end-offset))
+	validSize = offset
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr == io.EOF {
+			// partial entry without newline: the write was interrupted
+			return validSize, nil
+		}
+		if readErr != nil {
+			return 0, readErr
+		}
+
+		payload, decodeErr := decodeLogEntry(line)
+		if decodeErr != nil {
+			_, peekErr := reader.Peek(1)
+			if peekErr == io.EOF {
+				return validSize, nil
+			}
+			return 0, fmt.Errorf("corrupt entry of %s at offset %d: %w", path, validSize, decodeErr)
+		}
+
+		proceed, handleErr := handle(payload, validSize)
+		if handleErr != nil {
+			return 0, fmt.Errorf("invalid entry of %s at offset %d: %w", path, validSize, handleErr)
+		}
+		validSize += int64(len(line))
+		if !proceed {
+			return validSize, nil
+		}
+	}
+}
+
+// ReadLogEntriesAt passes the json payloads of the complete entries of the log at the passed offsets to handle
+func ReadLogEntriesAt(path string, offsets []int64, handle func([]byte) error) (err error) {
+	if len(offsets) == 0 {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer CloseFileAndHandleError(file, &err)
+
+	for _, offset := range offsets {
+		line, err := bufio.NewReader(io.NewSectionReader(file, offset, math.MaxInt64-offset)).ReadBytes('\n')
+		if err != nil {
+			return fmt.Errorf("missing entry of %s at offset %d: %w", path, offset, err)
+		}
+		payload, err := decodeLogEntry(line)
+		if err != nil {
+			return fmt.Errorf("corrupt entry of %s at offset %d: %w", path, offset, err)
+		}
+		err = handle(payload)
+		if err != nil {
+			return fmt.Errorf("invalid entry of %s at offset %d: %w", path, offset, err)
+		}
+	}
+	return nil
+}
+
+// decodeLogEntry returns the json payload of the entry after checking its checksum
+func decodeLogEntry(line []byte) ([]byte, error) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	checksum, payload, found := bytes.Cut(line, []byte(" "))
+	if !found {
+		return nil, errors.New("missing checksum")
+	}
+
+	expectedChecksum, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(expectedChecksum) != crc32.ChecksumIEEE(payload) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return payload, nil
+}
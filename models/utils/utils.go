@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
@@ -68,6 +69,53 @@ func ClearFile(fileName string) error {
 	return os.Truncate(fileName, 0)
 }
 
+// WriteFileAtomically replaces the content of the passed file by writing a temporary file,
+// syncing it to disk and renaming it over the original file
+func WriteFileAtomically(fileName string, content []byte) (err error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+
+	_, err = tempFile.Write(content)
+	if err != nil {
+		_ = tempFile.Close()
+		return err
+	}
+	err = tempFile.Sync()
+	if err != nil {
+		_ = tempFile.Close()
+		return err
+	}
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempFile.Name(), fileName)
+	if err != nil {
+		return err
+	}
+
+	return syncDirectory(filepath.Dir(fileName))
+}
+
+// syncDirectory makes a rename in the passed directory durable
+func syncDirectory(directory string) (err error) {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer CloseFileAndHandleError(dir, &err)
+
+	return dir.Sync()
+}
+
 // ToBool converts a string to a boolean value
 func ToBool(info string) bool {
 	aBool, _ := strconv.ParseBool(info)
@@ -0,0 +1,429 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TitleMaxLengthDefault default maximum number of characters of a todo title
+const TitleMaxLengthDefault = 200
+
+// DescriptionMaxLengthDefault default maximum number of characters of a todo description
+const DescriptionMaxLengthDefault = 10000
+
+// MaxBodySizeDefault default maximum size of a request body in bytes
+const MaxBodySizeDefault = 1024 * 1024
+
+// ErrInvalidBody is returned for request bodies which aren't exactly one JSON value
+var ErrInvalidBody = errors.New("invalid body")
+
+// ErrBodyTooLarge is returned for request bodies exceeding the maximum body size
+var ErrBodyTooLarge = errors.New("body too large")
+
+// ValidationRules configurable rules of the validation of request bodies and todos
+type ValidationRules struct {
+	TitleMaxLength       int            // maximum number of characters of a title
+	DescriptionMaxLength int            // maximum number of characters of a description
+	TitlePattern         *regexp.Regexp // pattern the whole title has to match, nil to allow any printable title
+	MaxBodySize          int64          // maximum size of a request body in bytes
+	RejectUnknownFields  bool           // whether fields unknown to the target of a request body are violations
+}
+
+// DefaultValidationRules returns the rules used unless others are set by SetValidationRules
+func DefaultValidationRules() ValidationRules {
+	return ValidationRules{
+		TitleMaxLength:       TitleMaxLengthDefault,
+		DescriptionMaxLength: DescriptionMaxLengthDefault,
+		MaxBodySize:          MaxBodySizeDefault,
+		RejectUnknownFields:  true,
+	}
+}
+
+var validationRules = DefaultValidationRules()
+var todoFieldRules = newTodoFieldRules(validationRules)
+
+// SetValidationRules sets the rules of the validation of request bodies and todos
+func SetValidationRules(rules ValidationRules) error {
+	if rules.TitleMaxLength <= 0 || rules.DescriptionMaxLength <= 0 {
+		return errors.New("maximum lengths must be positive")
+	}
+	if rules.MaxBodySize <= 0 {
+		return errors.New("maximum body size must be positive")
+	}
+	validationRules = rules
+	todoFieldRules = newTodoFieldRules(rules)
+	return nil
+}
+
+// MaxBodySize returns the maximum size of a request body (or message) in bytes
+func MaxBodySize() int64 {
+	return validationRules.MaxBodySize
+}
+
+// check returns the violation of the passed value, empty when the value is valid
+type check func(value string) string
+
+// fieldRule declares the checks of a single text field
+type fieldRule struct {
+	field  string
+	value  func(todo.Todo) string
+	checks []check
+}
+
+// newTodoFieldRules returns the rules of the text fields of a todo. The values are checked trimmed,
+// as they are stored trimmed.
+func newTodoFieldRules(rules ValidationRules) []fieldRule {
+	titleChecks := []check{notBlank(), maxLength(rules.TitleMaxLength), printable(false)}
+	if rules.TitlePattern != nil {
+		titleChecks = append(titleChecks, matches(rules.TitlePattern))
+	}
+	return []fieldRule{
+		{
+			field:  "title",
+			value:  func(t todo.Todo) string { return strings.TrimSpace(t.Title) },
+			checks: titleChecks,
+		},
+		{
+			field:  "description",
+			value:  func(t todo.Todo) string { return strings.TrimSpace(t.Description) },
+			checks: []check{notBlank(), maxLength(rules.DescriptionMaxLength), printable(true)},
+		},
+	}
+}
+
+// notBlank requires a value (blank values are reported as missing)
+func notBlank() check {
+	return func(value string) string {
+		if value == "" {
+			return "required"
+		}
+		return ""
+	}
+}
+
+// maxLength limits the number of characters
+func maxLength(length int) check {
+	return func(value string) string {
+		if utf8.RuneCountInString(value) > length {
+			return fmt.Sprintf("must not be longer than %d characters", length)
+		}
+		return ""
+	}
+}
+
+// printable rejects control characters, optionally except line breaks and tabs
+func printable(allowLineBreaks bool) check {
+	return func(value string) string {
+		for _, character := range value {
+			if allowLineBreaks && (character == '\n' || character == '\r' || character == '\t') {
+				continue
+			}
+			if unicode.IsControl(character) {
+				return "must not contain control characters"
+			}
+		}
+		return ""
+	}
+}
+
+// matches requires the whole value to match the pattern
+func matches(pattern *regexp.Regexp) check {
+	return func(value string) string {
+		if !pattern.MatchString(value) {
+			return fmt.Sprintf("must match %s", pattern.String())
+		}
+		return ""
+	}
+}
+
+// validateFields applies the field rules to the passed todo and returns all violations
+func validateFields(rules []fieldRule, todoToValidate todo.Todo) []repositories.FieldError {
+	var fieldErrors []repositories.FieldError
+	for _, rule := range rules {
+		value := rule.value(todoToValidate)
+		for _, currentCheck := range rule.checks {
+			message := currentCheck(value)
+			if message != "" {
+				fieldErrors = append(fieldErrors, repositories.FieldError{Field: rule.field, Message: message})
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// joinValidationErrors joins the violations of the passed validation errors (nil ones are skipped) into one.
+// Violations of a field already reported by a previous error are dropped, e.g. a title of the wrong type
+// isn't reported as missing as well. Other errors than validation errors are returned as they are.
+func joinValidationErrors(errs ...error) error {
+	var fieldErrors []repositories.FieldError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var validationError *repositories.ValidationError
+		if !errors.As(err, &validationError) {
+			return err
+		}
+		reported := slices.Clone(fieldErrors)
+		for _, fieldError := range validationError.Fields {
+			alreadyReported := slices.ContainsFunc(reported, func(reportedError repositories.FieldError) bool {
+				return reportedError.Field == fieldError.Field
+			})
+			if !alreadyReported {
+				fieldErrors = append(fieldErrors, fieldError)
+			}
+		}
+	}
+	return repositories.NewValidationError(fieldErrors)
+}
+
+// ReadBody reads the passed request body, limited to the maximum body size
+func ReadBody(body io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(body, validationRules.MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	if int64(len(content)) > validationRules.MaxBodySize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, validationRules.MaxBodySize)
+	}
+	return content, nil
+}
+
+// DecodeBody decodes the passed request body, which has to be exactly one JSON value, into target.
+// Unknown fields (unless allowed) and values of the wrong type are returned together as repositories.ValidationError;
+// target is decoded as far as possible in this case.
+func DecodeBody(body io.Reader, target interface{}) error {
+	content, err := ReadBody(body)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	var document interface{}
+	err = decoder.Decode(&document)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: body is empty", ErrInvalidBody)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	_, err = decoder.Token()
+	if !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: unexpected data after the JSON value", ErrInvalidBody)
+	}
+
+	var fieldErrors []repositories.FieldError
+	if validationRules.RejectUnknownFields {
+		for _, field := range unknownFields(document, reflect.TypeOf(target), "") {
+			fieldErrors = append(fieldErrors, repositories.FieldError{Field: field, Message: "unknown field"})
+		}
+	}
+	typeErrors, err := typeErrors(content, reflect.TypeOf(target), "")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	fieldErrors = append(fieldErrors, typeErrors...)
+	// Unmarshal skips values of the wrong type (all reported above) and decodes the remaining ones
+	err = json.Unmarshal(content, target)
+	var typeError *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeError) {
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	return repositories.NewValidationError(fieldErrors)
+}
+
+// DecodeTodo decodes the passed request body into a todo and validates it,
+// reporting the violations of both steps at once
+func DecodeTodo(body io.Reader) (todo.Todo, error) {
+	var decodedTodo todo.Todo
+	err := DecodeBody(body, &decodedTodo)
+	if err != nil && !errors.Is(err, repositories.ErrValidation) {
+		return todo.Todo{}, err
+	}
+	err = joinValidationErrors(err, ValidateTodo(decodedTodo))
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	return decodedTodo, nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields returns the paths of the object keys in the decoded JSON value, which don't match a field of
+// the passed type (matched case-insensitively like encoding/json does)
+func unknownFields(value interface{}, valueType reflect.Type, path string) []string {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+	if reflect.PointerTo(valueType).Implements(unmarshalerType) {
+		return nil
+	}
+
+	var fields []string
+	switch valueType.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fieldTypes := jsonFieldTypes(valueType)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			fieldType, found := lookupJsonField(fieldTypes, key)
+			if !found {
+				fields = append(fields, fieldPath)
+				continue
+			}
+			fields = append(fields, unknownFields(object[key], fieldType, fieldPath)...)
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for index, element := range array {
+			fields = append(fields, unknownFields(element, valueType.Elem(), fmt.Sprintf("%s[%d]", path, index))...)
+		}
+	}
+	return fields
+}
+
+// typeErrors returns the values of the passed JSON value, which don't match the passed type, as field errors.
+// Unlike Unmarshal, which reports the first one only, every object field and array element is checked.
+func typeErrors(value json.RawMessage, valueType reflect.Type, path string) ([]repositories.FieldError, error) {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+	if string(value) == "null" {
+		return nil, nil
+	}
+
+	var fieldErrors []repositories.FieldError
+	switch {
+	case reflect.PointerTo(valueType).Implements(unmarshalerType):
+		// checked as a whole below
+	case valueType.Kind() == reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(value, &object) != nil {
+			return []repositories.FieldError{{Field: path, Message: "must be " + jsonTypeName(valueType)}}, nil
+		}
+		fieldTypes := jsonFieldTypes(valueType)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			fieldType, found := lookupJsonField(fieldTypes, key)
+			if !found {
+				continue
+			}
+			elementErrors, err := typeErrors(object[key], fieldType, fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			fieldErrors = append(fieldErrors, elementErrors...)
+		}
+		return fieldErrors, nil
+	case valueType.Kind() == reflect.Slice && valueType.Elem().Kind() != reflect.Uint8,
+		valueType.Kind() == reflect.Array:
+		var array []json.RawMessage
+		if json.Unmarshal(value, &array) != nil {
+			return []repositories.FieldError{{Field: path, Message: "must be " + jsonTypeName(valueType)}}, nil
+		}
+		for index, element := range array {
+			elementErrors, err := typeErrors(element, valueType.Elem(), fmt.Sprintf("%s[%d]", path, index))
+			if err != nil {
+				return nil, err
+			}
+			fieldErrors = append(fieldErrors, elementErrors...)
+		}
+		return fieldErrors, nil
+	}
+
+	err := json.Unmarshal(value, reflect.New(valueType).Interface())
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []repositories.FieldError{{Field: path, Message: "must be " + jsonTypeName(typeError.Type)}}, nil
+	}
+	return nil, err
+}
+
+// jsonFieldTypes returns the types of the exported fields of the struct type by their JSON name
+func jsonFieldTypes(structType reflect.Type) map[string]reflect.Type {
+	fieldTypes := map[string]reflect.Type{}
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldTypes[name] = field.Type
+	}
+	return fieldTypes
+}
+
+// lookupJsonField returns the type of the field with the passed JSON name, preferring an exact match
+func lookupJsonField(fieldTypes map[string]reflect.Type, key string) (reflect.Type, bool) {
+	fieldType, found := fieldTypes[key]
+	if found {
+		return fieldType, true
+	}
+	for name, fieldType := range fieldTypes {
+		if strings.EqualFold(name, key) {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+// jsonTypeName returns the JSON type expected for values of the passed type
+func jsonTypeName(valueType reflect.Type) string {
+	for valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+	switch valueType.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+)
+
+func TestDecodeBodyReportsAllTypeErrors(t *testing.T) {
+	var decodedTodo todo.Todo
+	err := DecodeBody(strings.NewReader(`{"title": 1, "description": "kept", "terminated": "yes", "tags": ["a", 2]}`),
+		&decodedTodo)
+
+	var validationError *repositories.ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("got %v, want a validation error", err)
+	}
+	want := []repositories.FieldError{
+		{Field: "tags[1]", Message: "must be a string"},
+		{Field: "terminated", Message: "must be a boolean"},
+		{Field: "title", Message: "must be a string"},
+	}
+	if len(validationError.Fields) != len(want) {
+		t.Fatalf("got %v, want %v", validationError.Fields, want)
+	}
+	for index, fieldError := range validationError.Fields {
+		if fieldError != want[index] {
+			t.Errorf("got %v, want %v", fieldError, want[index])
+		}
+	}
+	if decodedTodo.Description != "kept" {
+		t.Errorf("description %q not decoded", decodedTodo.Description)
+	}
+}
+
+func TestPrintableAllowsReplacementCharacter(t *testing.T) {
+	if message := printable(false)("broken � character"); message != "" {
+		t.Errorf("U+FFFD rejected: %s", message)
+	}
+	if message := printable(false)("bell \a"); message == "" {
+		t.Error("control character accepted")
+	}
+}
@@ -0,0 +1,80 @@
+// Package webhook contains the webhook model parts
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"todo-rest-backend/models/todo"
+)
+
+// DeadLetterSize number of dead letters kept, the oldest ones are dropped beyond
+const DeadLetterSize = 1000
+
+// SignaturePrefix prefix of the signature header value, followed by the hex encoded HMAC-SHA256
+const SignaturePrefix = "sha256="
+
+// Webhook type definition with json tags. A webhook is called with the change events of the todos.
+type Webhook struct {
+	Id        string     `json:"id"`
+	Url       string     `json:"url"`
+	Secret    string     `json:"secret,omitempty"`    // Key of the signatures, only returned on creation
+	Events    []string   `json:"events"`              // Types of the events to deliver, all when empty
+	CreatedAt *time.Time `json:"createdAt,omitempty"` // Set by the models layer
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"` // Set by the models layer
+}
+
+// DeadLetter delivery of an event to a webhook which failed for good, with json tags
+type DeadLetter struct {
+	Id         string          `json:"id"`
+	WebhookId  string          `json:"webhookId"`
+	Url        string          `json:"url"`
+	Event      json.RawMessage `json:"event"` // body of the calls, the events.Event as JSON
+	Attempts   int             `json:"attempts"`
+	LastStatus int             `json:"lastStatus,omitempty"` // HTTP status of the last call, omitted when there was no response
+	LastError  string          `json:"lastError"`
+	FailedAt   time.Time       `json:"failedAt"`
+}
+
+// Matches checks whether the events of the passed type are delivered to the webhook
+func (w Webhook) Matches(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// Serialize serializes the passed webhook into a slice form
+func (w Webhook) Serialize() []string {
+	webhookSerialized := []string{w.Id, w.Url, w.Secret, todo.FormatTags(w.Events), todo.FormatTime(w.CreatedAt),
+		todo.FormatTime(w.UpdatedAt)}
+	return webhookSerialized
+}
+
+// Serialize serializes the passed dead letter into a slice form
+func (d DeadLetter) Serialize() []string {
+	deadLetterSerialized := []string{d.Id, d.WebhookId, d.Url, string(d.Event), strconv.Itoa(d.Attempts),
+		strconv.Itoa(d.LastStatus), d.LastError, todo.FormatTime(&d.FailedAt)}
+	return deadLetterSerialized
+}
+
+// Sign returns the signature of a delivery: the HMAC-SHA256 of the timestamp (unix seconds), a dot and the body
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature of a delivery, for receivers of the webhook calls.
+// The timestamp is the value of the timestamp header, receivers should reject old ones against replays.
+func VerifySignature(secret string, signature string, timestamp string, body []byte) bool {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return false
+	}
+	expected := Sign(secret, time.Unix(seconds, 0), body)
+	return hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature)))
+}
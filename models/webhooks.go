@@ -0,0 +1,293 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"todo-rest-backend/models/delivery"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/repositories"
+	"todo-rest-backend/models/todo"
+	"todo-rest-backend/models/webhook"
+)
+
+// webhookSecretSize number of random bytes of a generated webhook secret
+const webhookSecretSize = 32
+
+var webhookRepository repositories.WebhookRepository
+
+// webhookDispatcher delivers the change events of the todos to the webhooks
+var webhookDispatcher = delivery.NewDispatcher(delivery.MaxAttemptsDefault, delivery.BackoffDefault, delivery.TimeoutDefault)
+
+// SetWebhookRepository allows to set the webhook repositories type
+func SetWebhookRepository(webhookRepositoryNew repositories.WebhookRepository) error {
+	if webhookRepositoryNew == nil {
+		return errors.New("webhook repositories must not be nil")
+	}
+	webhookRepository = webhookRepositoryNew
+	webhookDispatcher.SetStore(webhookRepositoryNew)
+	return nil
+}
+
+// InitializeWebhooks initializes the webhook repository (abstracted by repository pattern)
+func InitializeWebhooks() error {
+	if webhookRepository == nil {
+		return errors.New("webhook repositories must not be nil")
+	}
+	return webhookRepository.Initialize()
+}
+
+// SetWebhookDelivery sets the number of calls of a webhook per event, the wait time after the first failed call
+// (doubled after each further one) and the time a call may take
+func SetWebhookDelivery(maxAttempts int, backoff time.Duration, timeout time.Duration) error {
+	if maxAttempts <= 0 || backoff <= 0 || timeout <= 0 {
+		return errors.New("webhook attempts, backoff and timeout must be positive")
+	}
+	webhookDispatcher.SetPolicy(maxAttempts, backoff, timeout)
+	return nil
+}
+
+// StartWebhookDelivery delivers the change events of the todos to the matching webhooks in the background
+// until stop is closed
+func StartWebhookDelivery(stop <-chan struct{}) {
+	// subscribed before returning, so that no change made afterward is missed
+	subscription := eventBroker.Subscribe("")
+	go func() {
+		for {
+			lastEventId, stopped := dispatchEvents(subscription, stop)
+			subscription.Close()
+			if stopped {
+				return
+			}
+			// dropped by the broker as too slow, resuming after the last dispatched event
+			subscription = eventBroker.Subscribe(lastEventId)
+			if subscription.Reset {
+				log.Println("webhook deliveries lost: events after", lastEventId, "aren't buffered anymore")
+			}
+		}
+	}()
+}
+
+// dispatchEvents passes the events of the subscription to the webhook dispatcher until the subscription is closed
+// or stop is closed, returning the id of the last dispatched event
+func dispatchEvents(subscription *events.Subscription, stop <-chan struct{}) (lastEventId string, stopped bool) {
+	lastEventId = subscription.LastId
+	for _, event := range subscription.Replay {
+		dispatchEvent(event)
+		lastEventId = event.Id
+	}
+	for {
+		select {
+		case <-stop:
+			return lastEventId, true
+		case event, open := <-subscription.Events:
+			if !open {
+				return lastEventId, false
+			}
+			dispatchEvent(event)
+			lastEventId = event.Id
+		}
+	}
+}
+
+// dispatchEvent starts the delivery of the event to the webhooks subscribed to its type
+func dispatchEvent(event events.Event) {
+	if webhookRepository == nil {
+		return
+	}
+	webhooks, err := webhookRepository.ReadWebhooks()
+	if err != nil {
+		log.Println("reading webhooks failed, event", event.Id, "not delivered:", err)
+		return
+	}
+	for _, hook := range webhooks {
+		if hook.Matches(string(event.Type)) {
+			webhookDispatcher.Deliver(hook, event)
+		}
+	}
+}
+
+// WaitForWebhookDeliveries waits until the queued webhook deliveries are done (delivered, skipped or dead letters)
+func WaitForWebhookDeliveries() {
+	webhookDispatcher.Wait()
+}
+
+// ValidateWebhook checks the passed webhook against the business rules
+func ValidateWebhook(webhookToValidate webhook.Webhook) error {
+	var violations []repositories.FieldError
+	target, err := url.Parse(webhookToValidate.Url)
+	switch {
+	case strings.TrimSpace(webhookToValidate.Url) == "":
+		violations = append(violations, repositories.FieldError{Field: "url", Message: "required"})
+	case err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "":
+		violations = append(violations, repositories.FieldError{Field: "url", Message: "must be an absolute http or https url"})
+	}
+	eventTypes := []string{string(events.TypeCreated), string(events.TypeUpdated), string(events.TypeDeleted)}
+	for index, eventType := range webhookToValidate.Events {
+		if !slices.Contains(eventTypes, eventType) {
+			violations = append(violations, repositories.FieldError{Field: fmt.Sprintf("events[%d]", index),
+				Message: "must be one of " + strings.Join(eventTypes, ", ")})
+		}
+	}
+	return repositories.NewValidationError(violations)
+}
+
+// DecodeWebhook decodes the passed request body into a webhook and validates it, reporting the violations of both steps at once
+func DecodeWebhook(body io.Reader) (webhook.Webhook, error) {
+	var decodedWebhook webhook.Webhook
+	err := DecodeBody(body, &decodedWebhook)
+	if err != nil && !errors.Is(err, repositories.ErrValidation) {
+		return webhook.Webhook{}, err
+	}
+	err = joinValidationErrors(err, ValidateWebhook(decodedWebhook))
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+	decodedWebhook.Url = strings.TrimSpace(decodedWebhook.Url)
+	decodedWebhook.Events = slices.Compact(slices.Sorted(slices.Values(decodedWebhook.Events)))
+	if decodedWebhook.Events == nil {
+		decodedWebhook.Events = []string{}
+	}
+	return decodedWebhook, nil
+}
+
+// ReadWebhooks returns the webhooks sorted by id, without their secrets, from repository (abstracted by repository pattern)
+func ReadWebhooks() ([]webhook.Webhook, error) {
+	if webhookRepository == nil {
+		return nil, errors.New("webhook repositories must not be nil")
+	}
+	webhooks, err := webhookRepository.ReadWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(webhooks, func(left webhook.Webhook, right webhook.Webhook) int {
+		return todo.CompareIds(left.Id, right.Id)
+	})
+	for index := range webhooks {
+		webhooks[index].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// ReadWebhookById returns webhook with passed id, without its secret, when existing from repository
+// (abstracted by repository pattern)
+func ReadWebhookById(id string) (webhook.Webhook, error) {
+	if webhookRepository == nil {
+		return webhook.Webhook{}, errors.New("webhook repositories must not be nil")
+	}
+	webhookRead, err := webhookRepository.ReadWebhookById(id)
+	webhookRead.Secret = ""
+	return webhookRead, err
+}
+
+// CreateWebhook stores the passed webhook in the repository and returns the stored webhook with its secret,
+// which is generated when not passed (abstracted by repository pattern)
+func CreateWebhook(webhookToCreate webhook.Webhook) (webhook.Webhook, error) {
+	if webhookRepository == nil {
+		return webhook.Webhook{}, errors.New("webhook repositories must not be nil")
+	}
+	if webhookToCreate.Secret == "" {
+		secret := make([]byte, webhookSecretSize)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return webhook.Webhook{}, err
+		}
+		webhookToCreate.Secret = hex.EncodeToString(secret)
+	}
+	now := time.Now().UTC()
+	webhookToCreate.CreatedAt = &now
+	webhookToCreate.UpdatedAt = &now
+	return webhookRepository.CreateWebhook(webhookToCreate)
+}
+
+// UpdateWebhookById returns updated webhook, without its secret, from repository (abstracted by repository pattern).
+// The secret is kept when none is passed.
+func UpdateWebhookById(id string, webhookUpdate webhook.Webhook) (webhook.Webhook, error) {
+	if webhookRepository == nil {
+		return webhook.Webhook{}, errors.New("webhook repositories must not be nil")
+	}
+	currentWebhook, err := webhookRepository.ReadWebhookById(id)
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+	if webhookUpdate.Secret == "" {
+		webhookUpdate.Secret = currentWebhook.Secret
+	}
+	now := time.Now().UTC()
+	webhookUpdate.CreatedAt = currentWebhook.CreatedAt
+	webhookUpdate.UpdatedAt = &now
+	webhookUpdated, err := webhookRepository.UpdateWebhookById(id, webhookUpdate)
+	webhookUpdated.Secret = ""
+	return webhookUpdated, err
+}
+
+// DeleteWebhookById deletes the webhook from repository and returns it without its secret (abstracted by repository pattern)
+func DeleteWebhookById(id string) (webhook.Webhook, error) {
+	if webhookRepository == nil {
+		return webhook.Webhook{}, errors.New("webhook repositories must not be nil")
+	}
+	webhookDeleted, err := webhookRepository.DeleteWebhookById(id)
+	webhookDeleted.Secret = ""
+	return webhookDeleted, err
+}
+
+// ReadDeadLetters returns the webhook deliveries which failed for good, oldest first, from repository
+// (abstracted by repository pattern)
+func ReadDeadLetters() ([]webhook.DeadLetter, error) {
+	if webhookRepository == nil {
+		return nil, errors.New("webhook repositories must not be nil")
+	}
+	deadLetters, err := webhookRepository.ReadDeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+// RedeliverDeadLetter delivers the event of the dead letter with passed id again to its webhook in the background,
+// taking the current url and secret of the webhook, and returns the removed dead letter
+func RedeliverDeadLetter(id string) (webhook.DeadLetter, error) {
+	if webhookRepository == nil {
+		return webhook.DeadLetter{}, errors.New("webhook repositories must not be nil")
+	}
+	deadLetter, err := webhookRepository.ReadDeadLetterById(id)
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+	var event events.Event
+	err = json.Unmarshal(deadLetter.Event, &event)
+	if err != nil {
+		return webhook.DeadLetter{}, fmt.Errorf("event of dead letter %s: %w", id, err)
+	}
+	hook, err := webhookRepository.ReadWebhookById(deadLetter.WebhookId)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return webhook.DeadLetter{}, fmt.Errorf("%w: webhook %s of the dead letter was deleted", repositories.ErrConflict, deadLetter.WebhookId)
+	}
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+	// removed first, so that concurrent redeliveries don't deliver the event twice
+	deadLetter, err = webhookRepository.DeleteDeadLetterById(id)
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+	webhookDispatcher.Deliver(hook, event)
+	return deadLetter, nil
+}
+
+// DiscardDeadLetter removes the dead letter with passed id from repository and returns it
+// (abstracted by repository pattern)
+func DiscardDeadLetter(id string) (webhook.DeadLetter, error) {
+	if webhookRepository == nil {
+		return webhook.DeadLetter{}, errors.New("webhook repositories must not be nil")
+	}
+	return webhookRepository.DeleteDeadLetterById(id)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"todo-rest-backend/models/delivery"
+	"todo-rest-backend/models/events"
+	"todo-rest-backend/models/repositories/memrepo"
+	"todo-rest-backend/models/webhook"
+)
+
+func TestRedeliverDeadLetterTakesCurrentWebhook(t *testing.T) {
+	err := SetWebhookRepository(&memrepo.MemoryWebhookRepository{})
+	if err == nil {
+		err = InitializeWebhooks()
+	}
+	if err == nil {
+		err = SetWebhookDelivery(1, time.Millisecond, time.Second)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejecting := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusGone)
+	}))
+	defer rejecting.Close()
+	var mutex sync.Mutex
+	var verified []bool
+	accepting := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		mutex.Lock()
+		verified = append(verified, webhook.VerifySignature("new secret", request.Header.Get(delivery.HeaderSignature),
+			request.Header.Get(delivery.HeaderTimestamp), body))
+		mutex.Unlock()
+	}))
+	defer accepting.Close()
+
+	hook, err := CreateWebhook(webhook.Webhook{Url: rejecting.URL, Secret: "old secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhookDispatcher.Deliver(hook, events.Event{Id: "redelivered", Type: events.TypeCreated})
+	WaitForWebhookDeliveries()
+	deadLetters, err := ReadDeadLetters()
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("dead letters %+v (%v), want 1", deadLetters, err)
+	}
+
+	_, err = UpdateWebhookById(hook.Id, webhook.Webhook{Url: accepting.URL, Secret: "new secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = RedeliverDeadLetter(deadLetters[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WaitForWebhookDeliveries()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(verified) != 1 || !verified[0] {
+		t.Errorf("redelivery calls with verified signature %v, want one at the current url with the current secret",
+			verified)
+	}
+	deadLetters, err = ReadDeadLetters()
+	if err != nil || len(deadLetters) != 0 {
+		t.Errorf("dead letters %+v (%v) left after the redelivery", deadLetters, err)
+	}
+}